@@ -20,6 +20,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -46,7 +47,10 @@ var (
 
 # DESCRIPTION
 
-{app_name} is a program that converts OPML's XML to JSON.
+{app_name} is a program that converts OPML's XML to JSON. The outline
+elements are kept in document order along with comments, processing
+instructions and unknown elements so json2opml can convert the JSON
+back to the same OPML. Use -sort to sort the outline elements.
 
 # OPTIONS
 
@@ -71,6 +75,8 @@ var (
 -pretty
 : pretty print JSON output
 
+-sort
+: sort the outline elements by their text attribute
 
 # EXAMPLES
 
@@ -93,6 +99,7 @@ Convert *myfeeds.ompl* to *myfeeds.json*.
 
 	// Application options
 	prettyPrint bool
+	sortText    bool
 )
 
 func main() {
@@ -113,7 +120,8 @@ func main() {
 	flag.StringVar(&outputFName, "o", "", "set output filename")
 
 	// Application Options
-	flag.BoolVar(&prettyPrint, "pretty", false, "pretty print XML output")
+	flag.BoolVar(&prettyPrint, "pretty", false, "pretty print JSON output")
+	flag.BoolVar(&sortText, "sort", false, "sort outline elements by text")
 
 	// Process environment and options
 	flag.Parse()
@@ -135,15 +143,15 @@ func main() {
 
 	// Handle options
 	if showHelp {
-		fmt.Fprint(out, "%s\n", fmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		fmt.Fprintf(out, "%s\n", fmtHelp(helpText, appName, version, releaseDate, releaseHash))
 		os.Exit(0)
 	}
 	if showLicense {
-		fmt.Fprintln(out, "%s\n", opml.LicenseText)
+		fmt.Fprintf(out, "%s\n", opml.LicenseText)
 		os.Exit(0)
 	}
 	if showVersion {
-		fmt.Fprintln(out, "%s %s %s\n", appName, version, releaseHash)
+		fmt.Fprintf(out, "%s %s %s\n", appName, version, releaseHash)
 		os.Exit(0)
 	}
 
@@ -166,40 +174,32 @@ func main() {
 	}
 
 
-	o := opml.New()
-	if len(inputFName) > 0 {
-		if err := o.ReadFile(inputFName); err != nil {
-			fmt.Fprintf(eout, "%s\n", err)
-			os.Exit(1)
-		}
-	} else {
-		src, err := ioutil.ReadAll(in)
-		if err != nil {
-			fmt.Fprintf(eout, "%s\n", err)
-			os.Exit(1)
-		}
-		o, err = opml.Parse(src)
-		if err != nil {
-			fmt.Fprintf(eout, "%s\n", err)
-			os.Exit(1)
-		}
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		os.Exit(1)
+	}
+	o, err := opml.ParseWith(src, opml.ParseOptions{Filename: inputFName, Preserve: true})
+	if err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		os.Exit(1)
+	}
+	if sortText {
+		o.Sort()
 	}
-	o.Sort()
 
-	var src []byte
+	// Write the XML kept in the Extra fields without escaping "<" and ">"
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
 	if prettyPrint {
-		src, err = json.MarshalIndent(o, "", "    ")
-		if err != nil {
-			fmt.Fprintf(eout, "%s\n", err)
-			os.Exit(1)
-		}
-	} else {
-		src, err = json.Marshal(o)
-		if err != nil {
-			fmt.Fprintf(eout, "%s\n", err)
-			os.Exit(1)
-		}
+		enc.SetIndent("", "    ")
+	}
+	if err := enc.Encode(o); err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		os.Exit(1)
 	}
+	src = bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
 
 	fmt.Fprintf(out, "%s", src)
 	if newLine {
@@ -131,7 +131,7 @@ func main() {
 	}
 
 	if outputFName != "" {
-		out, err = os.Create(inputFName)
+		out, err = os.Create(outputFName)
 		if err != nil {
 			fmt.Fprintf(eout, "%s\n", err)
 			os.Exit(1)
@@ -139,21 +139,17 @@ func main() {
 		defer out.Close()
 	}
 
-	src, err := io.ReadAll(in)
-	if err != nil {
-		fmt.Fprintf(eout, "%s\n", err)
-		os.Exit(1)
-	}
-
-	o, err := opml.Parse(src)
-	if err != nil {
-		fmt.Fprintf(eout, "%s\n", err)
-		os.Exit(1)
-	}
-
-	if err := o.Walk(func(elem *opml.Outline) bool {
-		if elem == nil {
-			return false
+	// Read the outline one element at a time so large files run in
+	// constant memory
+	dec := opml.NewDecoder(in)
+	for {
+		elem, _, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			os.Exit(1)
 		}
 		if newsboat {
 			if elem.XMLURL != "" {
@@ -174,9 +170,5 @@ func main() {
 				fmt.Fprintf(out, "%s\n", elem.HTMLURL)
 			}
 		}
-		return true
-	}); err != nil {
-		fmt.Fprintf(eout, "%s\n", err)
-		os.Exit(1)
 	}
 }
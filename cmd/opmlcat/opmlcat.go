@@ -20,7 +20,6 @@
 package main
 
 import (
-	"encoding/xml"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -69,7 +68,16 @@ var (
 : add trailing newline
 
 -pretty
-: pretty print JSON output
+: pretty print XML output
+
+-canonical
+: write canonical XML, indented with a stable attribute order, for
+keeping outlines in version control
+
+-resolve
+: replace type="include" outline elements with the outline they
+include, relative urls are resolved against the including file. A
+file included over http or https can only include http and https urls
 
 # EXAMPLES
 
@@ -95,6 +103,8 @@ create a combined sorted opml file.
 
 	// Application options
 	prettyPrint bool
+	canonical   bool
+	resolve     bool
 )
 
 
@@ -118,6 +128,8 @@ func main() {
 
 	// Application Options
 	flag.BoolVar(&prettyPrint, "pretty", false, "pretty print XML output")
+	flag.BoolVar(&canonical, "canonical", false, "write canonical XML output")
+	flag.BoolVar(&resolve, "resolve", false, "resolve included outlines")
 
 	// Process environment and options
 	flag.Parse()
@@ -162,7 +174,7 @@ func main() {
 	}
 
 	if outputFName != "" {
-		out, err = os.Create(inputFName)
+		out, err = os.Create(outputFName)
 		if err != nil {
 			fmt.Fprintf(eout, "%s\n", err)
 			os.Exit(1)
@@ -183,6 +195,14 @@ func main() {
 			fmt.Fprintf(eout, "%s\n", err)
 			os.Exit(1)
 		}
+		if resolve {
+			// Includes are relative to the -i file, or the
+			// current directory for standard input
+			if err := o.Resolve(opml.ResolveOptions{Base: inputFName}); err != nil {
+				fmt.Fprintf(eout, "%s\n", err)
+				os.Exit(1)
+			}
+		}
 	}
 
 	for _, inputFName := range args {
@@ -191,6 +211,12 @@ func main() {
 			fmt.Fprintf(eout, "%s\n", err)
 			os.Exit(1)
 		}
+		if resolve {
+			if err := next.Resolve(opml.ResolveOptions{Base: inputFName}); err != nil {
+				fmt.Fprintf(eout, "%s: %s\n", inputFName, err)
+				os.Exit(1)
+			}
+		}
 
 		err = o.Append(next)
 		if err != nil {
@@ -199,20 +225,14 @@ func main() {
 		}
 	}
 
-	var src []byte
-
-	if prettyPrint == true {
-		src, err = xml.MarshalIndent(o, "", "    ")
-		if err != nil {
-			fmt.Fprintf(eout, "%s\n", err)
-			os.Exit(1)
-		}
-	} else {
-		src = []byte(o.String())
+	opts := opml.EncodeOptions{Declaration: true, Canonical: canonical}
+	if prettyPrint {
+		opts.Indent = "    "
+	}
+	if err := opml.NewEncoderWith(out, opts).Encode(o); err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		os.Exit(1)
 	}
-
-	fmt.Fprintln(out, `<?xml version="1.0" encoding="UTF-8"?>`)
-	fmt.Fprintf(out, "%s", src)
 	if newLine {
 		fmt.Fprintln(out)
 	}
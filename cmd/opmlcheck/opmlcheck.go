@@ -0,0 +1,600 @@
+//
+// opmlcheck is a command line utility that checks the links in an OPML file and reports
+// dead feeds, redirects and broken links.
+//
+// @author R. S. Doiel, <rsdoiel@gmail.com>
+//
+// Copyright (c) 2021, R. S. Doiel
+// All rights not granted herein are expressly reserved by R. S. Doiel.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	// My Packages
+	"github.com/rsdoiel/opml"
+	"github.com/rsdoiel/opml/feed"
+	"github.com/rsdoiel/opml/fetch"
+)
+
+var (
+	helpText = `%{app_name}(1) | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] [INPUT_FILENAME]
+
+# DESCRIPTION
+
+{app_name} checks the xmlUrl, htmlUrl and url links of every outline
+element in an OPML file. Links are checked concurrently with at most
+one request at a time to each host. Each link is classified as
+
+ok
+: the link works, an xmlUrl is a feed
+
+redirected
+: the link works after following redirects
+
+gone
+: the server answered 404 Not Found or 410 Gone, or the host doesn't
+exist
+
+timeout
+: the server didn't answer in time
+
+not-a-feed
+: an xmlUrl that doesn't return an RSS, Atom or JSON feed
+
+error
+: any other error
+
+A report listing each link is written unless -rewrite is used.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-i
+: read from filename
+
+-o
+: write to filename
+
+-format
+: the report format, "text" (default) or "json"
+
+-all
+: include ok links in the report, by default only problems and
+redirects are listed
+
+-workers
+: the number of links checked at once (default 4)
+
+-timeout
+: the time allowed for each link, e.g. "10s" (default 30s)
+
+-delay
+: the time between requests to the same host (default 1s)
+
+-rewrite
+: write the OPML with permanent redirects (301 and 308) applied and
+outline elements with dead feeds moved to a "Dead" folder at the end
+of the outline. The URL replaced is kept in a custom attribute, e.g.
+previousXmlUrl.
+
+-dry-run
+: with -rewrite, list the URLs that would be rewritten and the outline
+elements that would be moved to the Dead folder instead of writing the
+OPML, the list is JSON if -format is "json"
+
+-previous
+: the prefix of the custom attribute that records a URL replaced by
+-rewrite, e.g. "previousXmlUrl" (default "previous")
+
+-dead
+: a comma separated list of the results that make a feed dead when
+rewriting (default "gone")
+
+-pretty
+: pretty print XML output
+
+-quiet
+: suppress error messages
+
+# EXIT STATUS
+
+0
+: all links are ok or redirected, or -rewrite wrote the OPML
+
+1
+: a link isn't ok or redirected, when reporting or with -dry-run
+
+2
+: an error stopped the check, e.g. the OPML can't be read or an
+option is wrong
+
+# EXAMPLES
+
+List the broken links in a subscription list.
+
+~~~
+    {app_name} myfeeds.opml
+~~~
+
+Apply permanent redirects and move feeds that are gone or no longer
+feeds to the Dead folder.
+
+~~~
+    {app_name} -rewrite -dead gone,not-a-feed -o checked.opml myfeeds.opml
+~~~
+
+List what the rewrite would change.
+
+~~~
+    {app_name} -rewrite -dry-run myfeeds.opml
+~~~
+
+`
+
+	// Standard options
+	showHelp     bool
+	showVersion  bool
+	showLicense  bool
+	showExamples bool
+	inputFName   string
+	outputFName  string
+	quiet        bool
+
+	// Application options
+	format      string
+	showAll     bool
+	workers     int
+	timeout     time.Duration
+	delay       time.Duration
+	rewrite     bool
+	dryRun      bool
+	previous    string
+	deadKinds   string
+	prettyPrint bool
+)
+
+// Classes of check results
+const (
+	OK         = "ok"
+	Redirected = "redirected"
+	Gone       = "gone"
+	Timeout    = "timeout"
+	NotAFeed   = "not-a-feed"
+	Error      = "error"
+)
+
+// link is an outline element's link to check
+type link struct {
+	Path    opml.OutlinePath `json:"path"`
+	Attr    string           `json:"attr"`
+	URL     string           `json:"url"`
+	outline *opml.Outline
+}
+
+// check is the result of checking a URL
+type check struct {
+	Status     string           `json:"status"`
+	StatusCode int              `json:"status_code,omitempty"`
+	FinalURL   string           `json:"final_url,omitempty"`
+	Permanent  string           `json:"permanent_url,omitempty"`
+	Redirects  []fetch.Redirect `json:"redirects,omitempty"`
+	Message    string           `json:"message,omitempty"`
+	// feedErr is set when the response isn't a feed
+	feedErr error
+}
+
+// result is a link and its check for the report
+type result struct {
+	link
+	*check
+}
+
+// hostLimiter allows one request at a time to each host with a delay
+// between them
+type hostLimiter struct {
+	delay time.Duration
+	mu    sync.Mutex
+	hosts map[string]*hostSlot
+}
+
+type hostSlot struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// do calls fn when host is free
+func (l *hostLimiter) do(host string, fn func()) {
+	l.mu.Lock()
+	slot, ok := l.hosts[host]
+	if !ok {
+		slot = new(hostSlot)
+		l.hosts[host] = slot
+	}
+	l.mu.Unlock()
+
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+	if wait := time.Until(slot.last.Add(l.delay)); wait > 0 {
+		time.Sleep(wait)
+	}
+	fn()
+	slot.last = time.Now()
+}
+
+// classify checks the result of fetching a URL, isFeed is set for
+// xmlUrl links
+func classify(res *fetch.Result, isFeed bool) *check {
+	c := &check{
+		StatusCode: res.StatusCode,
+		FinalURL:   res.FinalURL,
+		Permanent:  res.Permanent(),
+		Redirects:  res.Redirects,
+	}
+	var (
+		netErr net.Error
+		dnsErr *net.DNSError
+	)
+	switch {
+	case res.Err == nil:
+		c.Status = OK
+		if len(res.Redirects) > 0 {
+			c.Status = Redirected
+		}
+		if isFeed {
+			_, c.feedErr = feed.Parse(res.Body)
+		}
+		return c
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+		c.Status = Gone
+	case errors.As(res.Err, &dnsErr) && dnsErr.IsNotFound:
+		c.Status = Gone
+	case errors.Is(res.Err, context.DeadlineExceeded), errors.As(res.Err, &netErr) && netErr.Timeout():
+		c.Status = Timeout
+	default:
+		c.Status = Error
+	}
+	c.Message = res.Err.Error()
+	return c
+}
+
+// linkCheck returns the check of l, a working xmlUrl is not-a-feed if
+// the response isn't a feed
+func linkCheck(l link, checks map[string]*check) *check {
+	c := checks[l.URL]
+	if l.Attr == "xmlUrl" && c.feedErr != nil {
+		cp := *c
+		cp.Status, cp.Message = NotAFeed, c.feedErr.Error()
+		return &cp
+	}
+	return c
+}
+
+// checkLinks checks each distinct URL once
+func checkLinks(links []link, fetcher *fetch.Fetcher) map[string]*check {
+	// A URL used as an xmlUrl is checked as a feed
+	urls, isFeed := []string{}, map[string]bool{}
+	for _, l := range links {
+		if _, ok := isFeed[l.URL]; !ok {
+			urls = append(urls, l.URL)
+		}
+		isFeed[l.URL] = isFeed[l.URL] || l.Attr == "xmlUrl"
+	}
+	limiter := &hostLimiter{delay: delay, hosts: map[string]*hostSlot{}}
+	checks := map[string]*check{}
+	mu := new(sync.Mutex)
+	jobs := make(chan string)
+	wg := new(sync.WaitGroup)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range jobs {
+				host := ""
+				if pu, err := url.Parse(u); err == nil {
+					host = strings.ToLower(pu.Host)
+				}
+				var c *check
+				limiter.do(host, func() {
+					c = classify(fetcher.Fetch(context.Background(), u), isFeed[u])
+				})
+				mu.Lock()
+				checks[u] = c
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, u := range urls {
+		jobs <- u
+	}
+	close(jobs)
+	wg.Wait()
+	return checks
+}
+
+// rewriteOutline applies permanent redirects and moves outline elements
+// whose feed is dead to a "Dead" folder at the end of the body. It
+// returns the URLs rewritten and the paths of the dead outline elements,
+// when dryRun is set the outline isn't changed.
+func rewriteOutline(o *opml.OPML, links []link, checks map[string]*check, dead map[string]bool, dryRun bool) (opml.URLChanges, []opml.OutlinePath) {
+	changes := o.RewriteRedirects(opml.RedirectOptions{
+		Resolver: opml.RedirectResolverFunc(func(ref string) (string, error) {
+			if c, ok := checks[ref]; ok {
+				return c.Permanent, nil
+			}
+			return "", nil
+		}),
+		Prefix: previous,
+		DryRun: dryRun,
+	})
+	isDead := map[*opml.Outline]bool{}
+	paths := []opml.OutlinePath{}
+	for _, l := range links {
+		if l.Attr == "xmlUrl" && dead[linkCheck(l, checks).Status] && !isDead[l.outline] {
+			isDead[l.outline] = true
+			paths = append(paths, l.Path)
+		}
+	}
+	if len(isDead) == 0 || dryRun {
+		return changes, paths
+	}
+	var folder *opml.Outline
+	for _, ol := range o.Body.Outline {
+		if ol.Text == "Dead" && ol.XMLURL == "" {
+			folder = ol
+			break
+		}
+	}
+	var prune func(list []*opml.Outline) []*opml.Outline
+	prune = func(list []*opml.Outline) []*opml.Outline {
+		kept := []*opml.Outline{}
+		for _, ol := range list {
+			if isDead[ol] {
+				folder.Outline = append(folder.Outline, ol)
+				continue
+			}
+			if ol != folder {
+				ol.Outline = prune(ol.Outline)
+			}
+			kept = append(kept, ol)
+		}
+		return kept
+	}
+	if folder == nil {
+		folder = &opml.Outline{Text: "Dead"}
+		o.Body.Outline = append(prune(o.Body.Outline), folder)
+	} else {
+		o.Body.Outline = prune(o.Body.Outline)
+	}
+	return changes, paths
+}
+
+func main() {
+	appName := path.Base(os.Args[0])
+	// NOTE: the following are set when version.go is generated
+	version := opml.Version
+	releaseDate := opml.ReleaseDate
+	releaseHash := opml.ReleaseHash
+	fmtHelp := opml.FmtHelp
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.BoolVar(&showExamples, "examples", false, "display examples")
+	flag.BoolVar(&quiet, "quiet", false, "suppress error messages")
+	flag.StringVar(&inputFName, "i", "", "set input filename")
+	flag.StringVar(&outputFName, "o", "", "set output filename")
+
+	// Application Options
+	flag.StringVar(&format, "format", "text", "report format, text or json")
+	flag.BoolVar(&showAll, "all", false, "include ok links in the report")
+	flag.IntVar(&workers, "workers", fetch.DefaultWorkers, "number of links checked at once")
+	flag.DurationVar(&timeout, "timeout", fetch.DefaultTimeout, "time allowed for each link")
+	flag.DurationVar(&delay, "delay", time.Second, "time between requests to the same host")
+	flag.BoolVar(&rewrite, "rewrite", false, "write the OPML with redirects applied and dead feeds moved")
+	flag.BoolVar(&dryRun, "dry-run", false, "list the changes -rewrite would make")
+	flag.StringVar(&previous, "previous", opml.DefaultPreviousPrefix, "prefix of the attribute recording a rewritten URL")
+	flag.StringVar(&deadKinds, "dead", Gone, "results that make a feed dead when rewriting")
+	flag.BoolVar(&prettyPrint, "pretty", false, "pretty print XML output")
+
+	// Process environment and options
+	flag.Parse()
+	args := flag.Args()
+	if len(args) > 0 {
+		inputFName = args[0]
+	}
+
+	// Setup I/O
+	var err error
+
+	in := os.Stdin
+	out := os.Stdout
+	eout := os.Stderr
+
+	// Handle options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", fmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", opml.LicenseText)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "%s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+	exit := func(err error) {
+		if !quiet {
+			fmt.Fprintf(eout, "%s\n", err)
+		}
+		os.Exit(2)
+	}
+	if format != "text" && format != "json" {
+		exit(fmt.Errorf("unknown format %q, expected text or json", format))
+	}
+	if workers <= 0 {
+		workers = fetch.DefaultWorkers
+	}
+	dead := map[string]bool{}
+	for _, s := range strings.Split(deadKinds, ",") {
+		switch s = strings.TrimSpace(s); s {
+		case Gone, Timeout, NotAFeed, Error:
+			dead[s] = true
+		case "":
+		default:
+			exit(fmt.Errorf("%q can't make a feed dead, expected gone, timeout, not-a-feed or error", s))
+		}
+	}
+
+	if inputFName != "" {
+		in, err = os.Open(inputFName)
+		if err != nil {
+			exit(err)
+		}
+		defer in.Close()
+	}
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		exit(err)
+	}
+	o, err := opml.ParseWith(src, opml.ParseOptions{Filename: inputFName, Preserve: true})
+	if err != nil {
+		exit(err)
+	}
+
+	links := []link{}
+	o.WalkWithPath(func(ol *opml.Outline, p opml.OutlinePath, depth int, parent *opml.Outline) error {
+		for _, attr := range []string{"xmlUrl", "htmlUrl", "url"} {
+			s, _ := ol.Attr(attr)
+			if u, err := url.Parse(s); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+				links = append(links, link{Path: p, Attr: attr, URL: s, outline: ol})
+			}
+		}
+		return nil
+	})
+	fetcher := fetch.New(fetch.Options{
+		Timeout:   timeout,
+		UserAgent: fmt.Sprintf("%s/%s", appName, version),
+	})
+	checks := checkLinks(links, fetcher)
+
+	if outputFName != "" {
+		out, err = os.Create(outputFName)
+		if err != nil {
+			exit(err)
+		}
+		defer out.Close()
+	}
+
+	problems := 0
+	results := []result{}
+	for _, l := range links {
+		c := linkCheck(l, checks)
+		if c.Status != OK && c.Status != Redirected {
+			problems++
+		}
+		if showAll || c.Status != OK {
+			results = append(results, result{link: l, check: c})
+		}
+	}
+
+	if rewrite && dryRun {
+		changes, paths := rewriteOutline(o, links, checks, dead, true)
+		if format == "json" {
+			src, err := json.MarshalIndent(map[string]interface{}{
+				"changes": changes,
+				"dead":    paths,
+			}, "", "    ")
+			if err != nil {
+				exit(err)
+			}
+			fmt.Fprintf(out, "%s\n", src)
+		} else {
+			fmt.Fprint(out, changes)
+			for _, p := range paths {
+				fmt.Fprintf(out, "%s moved to Dead\n", p)
+			}
+		}
+	} else if rewrite {
+		rewriteOutline(o, links, checks, dead, false)
+		opts := opml.EncodeOptions{Declaration: true}
+		if prettyPrint {
+			opts.Indent = "    "
+		}
+		if err := opml.NewEncoderWith(out, opts).Encode(o); err != nil {
+			exit(err)
+		}
+		fmt.Fprintln(out)
+	} else if format == "json" {
+		src, err := json.MarshalIndent(results, "", "    ")
+		if err != nil {
+			exit(err)
+		}
+		fmt.Fprintf(out, "%s\n", src)
+	} else {
+		for _, r := range results {
+			s := fmt.Sprintf("%s\t%s\t%s\t%s", r.Status, r.Path, r.Attr, r.URL)
+			if r.FinalURL != "" && r.FinalURL != r.URL {
+				s += " -> " + r.FinalURL
+			}
+			if r.Message != "" {
+				s += "\t" + r.Message
+			}
+			fmt.Fprintln(out, s)
+		}
+	}
+	if problems > 0 {
+		if !quiet && rewrite {
+			fmt.Fprintf(eout, "%d links with problems\n", problems)
+		}
+		// A successful rewrite has dealt with the problems
+		if rewrite && !dryRun {
+			return
+		}
+		out.Close()
+		os.Exit(1)
+	}
+}
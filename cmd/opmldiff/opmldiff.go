@@ -0,0 +1,199 @@
+//
+// opmldiff is a command line utility that compares two OPML files and reports the outline
+// elements added, removed, moved and changed.
+//
+// @author R. S. Doiel, <rsdoiel@gmail.com>
+//
+// Copyright (c) 2021, R. S. Doiel
+// All rights not granted herein are expressly reserved by R. S. Doiel.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	// My Packages
+	"github.com/rsdoiel/opml"
+)
+
+var (
+	helpText = `%{app_name}(1) | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] OLD_FILE NEW_FILE
+
+# DESCRIPTION
+
+{app_name} compares two opml files and reports the outline elements
+added, removed, moved and changed. Outline elements are matched by
+their xmlUrl, url or text, see -keys. The exit status is 0 if the
+files are the same, 1 if they differ and 2 if there was an error.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-o
+: write to filename
+
+-keys
+: a comma separated list of the keys used to match outline elements,
+the first key an element has is used. A key is an attribute name or
+"path" for the element's position. The default is "xmlUrl,url,text".
+
+-format
+: the report format, "text" (default), "json" or "unified"
+
+-quiet
+: suppress error messages
+
+# EXAMPLES
+
+Review the changes to a shared subscription list.
+
+~~~
+    git show HEAD~1:feeds.opml >old.opml
+    {app_name} -format unified old.opml feeds.opml
+~~~
+
+`
+
+	// Standard options
+	showHelp     bool
+	showVersion  bool
+	showLicense  bool
+	showExamples bool
+	outputFName  string
+	quiet        bool
+
+	// Application options
+	keys   string
+	format string
+)
+
+func main() {
+	appName := path.Base(os.Args[0])
+	// NOTE: the following are set when version.go is generated
+	version := opml.Version
+	releaseDate := opml.ReleaseDate
+	releaseHash := opml.ReleaseHash
+	fmtHelp := opml.FmtHelp
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.BoolVar(&showExamples, "examples", false, "display examples")
+	flag.BoolVar(&quiet, "quiet", false, "suppress error messages")
+	flag.StringVar(&outputFName, "o", "", "set output filename")
+
+	// Application Options
+	flag.StringVar(&keys, "keys", strings.Join(opml.DefaultDiffKeys, ","), "keys used to match outline elements")
+	flag.StringVar(&format, "format", "text", "report format, text, json or unified")
+
+	// Process environment and options
+	flag.Parse()
+	args := flag.Args()
+
+	// Setup I/O
+	var err error
+
+	out := os.Stdout
+	eout := os.Stderr
+
+	// Handle options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", fmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", opml.LicenseText)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "%s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+	exit := func(err error) {
+		if !quiet {
+			fmt.Fprintf(eout, "%s\n", err)
+		}
+		os.Exit(2)
+	}
+
+	if len(args) != 2 {
+		exit(fmt.Errorf("expected two OPML files, see %s -help", appName))
+	}
+	if format != "text" && format != "json" && format != "unified" {
+		exit(fmt.Errorf("unknown format %q, expected text, json or unified", format))
+	}
+	opts := opml.DiffOptions{}
+	for _, key := range strings.Split(keys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			opts.Keys = append(opts.Keys, key)
+		}
+	}
+
+	a, err := opml.ReadFile(args[0])
+	if err != nil {
+		exit(err)
+	}
+	b, err := opml.ReadFile(args[1])
+	if err != nil {
+		exit(err)
+	}
+	changes := opml.Diff(a, b, opts)
+
+	if outputFName != "" {
+		out, err = os.Create(outputFName)
+		if err != nil {
+			exit(err)
+		}
+		defer out.Close()
+	}
+	switch format {
+	case "json":
+		src, err := json.MarshalIndent(changes, "", "    ")
+		if err != nil {
+			exit(err)
+		}
+		fmt.Fprintf(out, "%s\n", src)
+	case "unified":
+		fmt.Fprintf(out, "%s", changes.Unified(args[0], args[1]))
+	default:
+		fmt.Fprintf(out, "%s", changes)
+	}
+	if len(changes) > 0 {
+		out.Close()
+		os.Exit(1)
+	}
+}
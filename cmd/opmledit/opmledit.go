@@ -0,0 +1,392 @@
+//
+// opmledit is a command line utility that reads an OPML file, applies one or more
+// editing verbs (insert, append, replace, delete, find) and writes the result.
+//
+// @author R. S. Doiel, <rsdoiel@gmail.com>
+//
+// Copyright (c) 2021, R. S. Doiel
+// All rights not granted herein are expressly reserved by R. S. Doiel.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	// My Packages
+	"github.com/rsdoiel/opml"
+)
+
+var (
+	helpText = `%{app_name}(1) | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] VERB [ARGS] [VERB [ARGS] ...]
+
+# DESCRIPTION
+
+{app_name} reads an OPML file, applies one or more editing verbs
+and writes the result. If the OPML was read with -i and no -o is
+given the file is updated in place, otherwise the result is written
+to standard out. Comments, processing instructions and elements that
+aren't part of OPML are kept.
+
+Outline elements are addressed by a path of one based positions,
+"/" is the root of the outline, "/3" the third outline element in
+the body and "/3/2" is the second child of the third element.
+
+An ITEM is either a URL (added as an rss outline element with the
+xmlUrl and text set to the URL), an outline element in XML
+(e.g. '<outline text="News" type="rss" xmlUrl="..."/>') or plain
+text used as the text attribute.
+
+# VERBS
+
+append ITEM [PATH]
+: append ITEM as the last child of PATH, defaults to the root
+
+insert ITEM [PATH]
+: insert ITEM before the element at PATH
+
+replace ITEM [PATH]
+: replace the element at PATH with ITEM
+
+delete [PATH]
+: delete the element at PATH, the root path "/" can't be deleted
+
+find [NAME=]VALUE
+: find the first element whose attribute NAME (default text) is VALUE,
+the path found becomes the default PATH for the verbs that follow.
+If find is the last verb the path is written to standard out.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-i
+: read from filename
+
+-o
+: write to filename
+
+-newline
+: add a trailing newline
+
+-pretty
+: pretty print XML output
+
+-canonical
+: write canonical XML, indented with a stable attribute order, for
+keeping outlines in version control
+
+# EXAMPLES
+
+Append a feed to the root of a subscription list in place.
+
+~~~
+    {app_name} -i feeds.opml append https://example.org/feed.xml
+~~~
+
+Append a feed to the second child of the third outline element.
+
+~~~
+    {app_name} -i feeds.opml append https://example.org/feed.xml /3/2
+~~~
+
+Find the "Podcasts" folder and append a feed to it.
+
+~~~
+    {app_name} -i feeds.opml find Podcasts append https://example.org/podcast.xml
+~~~
+
+Print the path of the outline with a given xmlUrl.
+
+~~~
+    {app_name} -i feeds.opml find xmlUrl=https://example.org/feed.xml
+~~~
+
+`
+
+	// Standard options
+	showHelp    bool
+	showVersion bool
+	showLicense bool
+	inputFName  string
+	outputFName string
+	newLine     bool
+
+	// Application options
+	prettyPrint bool
+	canonical   bool
+)
+
+// writeFile replaces fname with src by renaming a temporary file into
+// place so fname is never left partly written, an existing file keeps
+// its permissions
+func writeFile(fname string, src []byte) error {
+	mode := os.FileMode(0664)
+	if info, err := os.Stat(fname); err == nil {
+		mode = info.Mode().Perm()
+	}
+	fp, err := ioutil.TempFile(filepath.Dir(fname), ".opmledit-*")
+	if err != nil {
+		return err
+	}
+	if _, err := fp.Write(src); err != nil {
+		fp.Close()
+		os.Remove(fp.Name())
+		return err
+	}
+	if err := fp.Close(); err != nil {
+		os.Remove(fp.Name())
+		return err
+	}
+	if err := os.Chmod(fp.Name(), mode); err != nil {
+		os.Remove(fp.Name())
+		return err
+	}
+	return os.Rename(fp.Name(), fname)
+}
+
+// parseItem turns a command line ITEM into an outline element
+func parseItem(s string) (*opml.Outline, error) {
+	elem := new(opml.Outline)
+	switch {
+	case strings.HasPrefix(strings.TrimSpace(s), "<"):
+		if err := xml.Unmarshal([]byte(s), elem); err != nil {
+			return nil, err
+		}
+	case strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://"):
+		elem.Text = s
+		elem.Type = "rss"
+		elem.XMLURL = s
+	default:
+		elem.Text = s
+	}
+	return elem, nil
+}
+
+// isPath returns true if the argument looks like an outline path
+func isPath(s string) bool {
+	if !strings.HasPrefix(s, "/") {
+		return false
+	}
+	_, err := opml.ParsePath(s)
+	return err == nil
+}
+
+// edit applies the verbs in args to o, it returns true if the document
+// was modified.
+func edit(o *opml.OPML, args []string, out *os.File) (bool, error) {
+	var (
+		current  opml.OutlinePath
+		modified bool
+		found    bool
+	)
+	for i := 0; i < len(args); i++ {
+		verb := args[i]
+		found = false
+		switch verb {
+		case "append", "insert", "replace":
+			if i+1 >= len(args) {
+				return modified, fmt.Errorf("%s is missing an ITEM", verb)
+			}
+			i++
+			elem, err := parseItem(args[i])
+			if err != nil {
+				return modified, fmt.Errorf("%s %q, %s", verb, args[i], err)
+			}
+			p := current
+			if i+1 < len(args) && isPath(args[i+1]) {
+				i++
+				p, _ = opml.ParsePath(args[i])
+			}
+			switch verb {
+			case "append":
+				err = o.AppendAt(p, elem)
+			case "insert":
+				err = o.InsertAt(p, elem)
+			case "replace":
+				err = o.ReplaceAt(p, elem)
+			}
+			if err != nil {
+				return modified, err
+			}
+			modified = true
+		case "delete":
+			p := current
+			if i+1 < len(args) && isPath(args[i+1]) {
+				i++
+				p, _ = opml.ParsePath(args[i])
+			}
+			if p.IsRoot() {
+				return modified, fmt.Errorf("can't delete the root path %s", p)
+			}
+			if err := o.DeleteAt(p); err != nil {
+				return modified, err
+			}
+			modified = true
+		case "find":
+			if i+1 >= len(args) {
+				return modified, fmt.Errorf("find is missing a VALUE")
+			}
+			i++
+			name, value := "text", args[i]
+			if pos := strings.Index(value, "="); pos > 0 {
+				name, value = value[0:pos], value[pos+1:]
+			}
+			p, err := o.Find(name, value)
+			if err != nil {
+				return modified, err
+			}
+			current, found = p, true
+		default:
+			return modified, fmt.Errorf("unknown verb %q", verb)
+		}
+	}
+	if found {
+		fmt.Fprintf(out, "%s\n", current)
+	}
+	return modified, nil
+}
+
+func main() {
+	appName := path.Base(os.Args[0])
+	// NOTE: the following are set when version.go is generated
+	version := opml.Version
+	releaseDate := opml.ReleaseDate
+	releaseHash := opml.ReleaseHash
+	fmtHelp := opml.FmtHelp
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.BoolVar(&newLine, "newline", false, "add trailing newline")
+	flag.StringVar(&inputFName, "i", "", "set input filename")
+	flag.StringVar(&outputFName, "o", "", "set output filename")
+
+	// Application Options
+	flag.BoolVar(&prettyPrint, "pretty", false, "pretty print XML output")
+	flag.BoolVar(&canonical, "canonical", false, "write canonical XML output")
+
+	// Process environment and options
+	flag.Parse()
+	args := flag.Args()
+
+	// Setup I/O
+	var (
+		err error
+		src []byte
+	)
+
+	in := os.Stdin
+	out := os.Stdout
+	eout := os.Stderr
+
+	// Handle options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", fmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", opml.LicenseText)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "%s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+
+	if len(args) == 0 {
+		fmt.Fprintf(eout, "missing a verb, see %s -help\n", appName)
+		os.Exit(1)
+	}
+
+	if inputFName != "" {
+		in, err = os.Open(inputFName)
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			os.Exit(1)
+		}
+	}
+	src, err = ioutil.ReadAll(in)
+	if err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		os.Exit(1)
+	}
+	if inputFName != "" {
+		in.Close()
+	}
+	o, err := opml.ParseWith(src, opml.ParseOptions{Filename: inputFName, Preserve: true})
+	if err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		os.Exit(1)
+	}
+
+	modified, err := edit(o, args, out)
+	if err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		os.Exit(1)
+	}
+	if !modified {
+		os.Exit(0)
+	}
+
+	if outputFName == "" {
+		outputFName = inputFName
+	}
+
+	// Encode to a buffer so a failure doesn't truncate the file
+	buf := new(bytes.Buffer)
+	opts := opml.EncodeOptions{Declaration: true, Canonical: canonical}
+	if prettyPrint {
+		opts.Indent = "    "
+	}
+	if err := opml.NewEncoderWith(buf, opts).Encode(o); err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		os.Exit(1)
+	}
+	if newLine {
+		buf.WriteString("\n")
+	}
+	if outputFName == "" {
+		out.Write(buf.Bytes())
+		return
+	}
+	if err := writeFile(outputFName, buf.Bytes()); err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		os.Exit(1)
+	}
+}
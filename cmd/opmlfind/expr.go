@@ -0,0 +1,511 @@
+//
+// expr.go parses and evaluates the find expressions of opmlfind.
+//
+// @author R. S. Doiel, <rsdoiel@gmail.com>
+//
+// Copyright (c) 2021, R. S. Doiel
+// All rights not granted herein are expressly reserved by R. S. Doiel.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	// My Packages
+	"github.com/rsdoiel/opml"
+)
+
+// node holds the outline element being evaluated and its context
+type node struct {
+	outline  *opml.Outline
+	path     opml.OutlinePath
+	textPath string
+	depth    int
+	prune    bool
+}
+
+// expr is a compiled opmlfind expression
+type expr interface {
+	eval(n *node) bool
+}
+
+type andExpr struct {
+	left, right expr
+}
+
+func (e *andExpr) eval(n *node) bool {
+	return e.left.eval(n) && e.right.eval(n)
+}
+
+type orExpr struct {
+	left, right expr
+}
+
+func (e *orExpr) eval(n *node) bool {
+	return e.left.eval(n) || e.right.eval(n)
+}
+
+type notExpr struct {
+	e expr
+}
+
+func (e *notExpr) eval(n *node) bool {
+	return !e.e.eval(n)
+}
+
+// trueExpr is used for primaries that are always true, e.g. -maxdepth
+type trueExpr struct{}
+
+func (e *trueExpr) eval(n *node) bool {
+	return true
+}
+
+type pruneExpr struct{}
+
+func (e *pruneExpr) eval(n *node) bool {
+	n.prune = true
+	return true
+}
+
+type emptyExpr struct{}
+
+func (e *emptyExpr) eval(n *node) bool {
+	return len(n.outline.Outline) == 0
+}
+
+// attrExpr matches an attribute's value against a regular expression.
+// If each is true the value is split on commas and any part may match,
+// this is used for the category attribute.
+type attrExpr struct {
+	name string
+	re   *regexp.Regexp
+	each bool
+}
+
+func (e *attrExpr) eval(n *node) bool {
+	val, ok := n.outline.Attr(e.name)
+	if !ok {
+		return false
+	}
+	if e.each {
+		for _, s := range strings.Split(val, ",") {
+			if e.re.MatchString(strings.TrimSpace(s)) {
+				return true
+			}
+		}
+		return false
+	}
+	return e.re.MatchString(val)
+}
+
+// queryExpr evaluates an opml package query, see opml.CompileQuery
+type queryExpr struct {
+	q *opml.Query
+}
+
+func (e *queryExpr) eval(n *node) bool {
+	return e.q.Match(n.outline, n.path)
+}
+
+type hasExpr struct {
+	name string
+}
+
+func (e *hasExpr) eval(n *node) bool {
+	_, ok := n.outline.Attr(e.name)
+	return ok
+}
+
+// pathExpr matches the text path (e.g. "/Places of interest/Boston")
+type pathExpr struct {
+	re *regexp.Regexp
+}
+
+func (e *pathExpr) eval(n *node) bool {
+	return e.re.MatchString(n.textPath)
+}
+
+// comparison holds a numeric argument prefixed by an optional "+"
+// (more than n) or "-" (less than n)
+type comparison struct {
+	sign int
+	n    float64
+}
+
+func (c comparison) match(v float64) bool {
+	switch {
+	case c.sign > 0:
+		return v > c.n
+	case c.sign < 0:
+		return v < c.n
+	}
+	return v == c.n
+}
+
+type depthExpr struct {
+	cmp comparison
+}
+
+func (e *depthExpr) eval(n *node) bool {
+	return e.cmp.match(float64(n.depth))
+}
+
+// createdExpr compares the age of an outline's created attribute with
+// the time opmlfind started. The age is rounded up to the next unit.
+type createdExpr struct {
+	cmp  comparison
+	unit time.Duration
+	now  time.Time
+}
+
+func (e *createdExpr) eval(n *node) bool {
+	if n.outline.Created == "" {
+		return false
+	}
+	created, err := opml.ParseDate(n.outline.Created)
+	if err != nil {
+		return false
+	}
+	age := e.now.Sub(created)
+	return e.cmp.match(math.Ceil(float64(age) / float64(e.unit)))
+}
+
+// parser turns the command line arguments into an expression
+type parser struct {
+	args []string
+	pos  int
+	now  time.Time
+
+	postOrder bool
+	minDepth  int
+	maxDepth  int
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.args) {
+		return p.args[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) next() string {
+	s := p.peek()
+	p.pos++
+	return s
+}
+
+func (p *parser) arg(primary string) (string, error) {
+	if p.pos >= len(p.args) {
+		return "", fmt.Errorf("%s: requires additional arguments", primary)
+	}
+	return p.next(), nil
+}
+
+// parseExpr parses args returning a compiled expression. An empty
+// expression is always true.
+func parseExpr(args []string, now time.Time) (*parser, expr, error) {
+	p := &parser{args: args, now: now, maxDepth: -1}
+	if len(args) == 0 {
+		return p, &trueExpr{}, nil
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return p, nil, err
+	}
+	if p.pos < len(p.args) {
+		return p, nil, fmt.Errorf("%s: unexpected argument", p.peek())
+	}
+	return p, e, nil
+}
+
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "-o" || p.peek() == "-or" {
+		op := p.next()
+		if p.pos >= len(p.args) {
+			return nil, fmt.Errorf("%s: expression expected", op)
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.pos < len(p.args) {
+		tok := p.peek()
+		if tok == ")" || tok == "-o" || tok == "-or" {
+			break
+		}
+		if tok == "-a" || tok == "-and" {
+			p.next()
+			if p.pos >= len(p.args) {
+				return nil, fmt.Errorf("%s: expression expected", tok)
+			}
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if tok := p.peek(); tok == "!" || tok == "-not" {
+		p.next()
+		if p.pos >= len(p.args) {
+			return nil, fmt.Errorf("%s: expression expected", tok)
+		}
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{e}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	tok := p.next()
+	switch tok {
+	case "(":
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("(: missing closing ')'")
+		}
+		return e, nil
+	case ")":
+		return nil, fmt.Errorf("): no expression before ')'")
+	case "-name", "-text", "-iname", "-itext":
+		return p.attrPrimary(tok, "text", strings.HasPrefix(tok, "-i"), false)
+	case "-title", "-ititle":
+		return p.attrPrimary(tok, "title", tok == "-ititle", false)
+	case "-xmlurl", "-ixmlurl":
+		return p.attrPrimary(tok, "xmlUrl", tok == "-ixmlurl", false)
+	case "-htmlurl", "-ihtmlurl":
+		return p.attrPrimary(tok, "htmlUrl", tok == "-ihtmlurl", false)
+	case "-url", "-iurl":
+		return p.attrPrimary(tok, "url", tok == "-iurl", false)
+	case "-category", "-icategory":
+		return p.attrPrimary(tok, "category", tok == "-icategory", true)
+	case "-attr", "-iattr":
+		name, err := p.arg(tok)
+		if err != nil {
+			return nil, err
+		}
+		return p.attrPrimary(tok, name, tok == "-iattr", false)
+	case "-has":
+		name, err := p.arg(tok)
+		if err != nil {
+			return nil, err
+		}
+		return &hasExpr{name}, nil
+	case "-query":
+		src, err := p.arg(tok)
+		if err != nil {
+			return nil, err
+		}
+		q, err := opml.CompileQuery(src)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", tok, err)
+		}
+		return &queryExpr{q}, nil
+	case "-type":
+		t, err := p.arg(tok)
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile("(?i)^" + regexp.QuoteMeta(t) + "$")
+		if err != nil {
+			return nil, err
+		}
+		return &attrExpr{name: "type", re: re}, nil
+	case "-path", "-ipath":
+		pattern, err := p.arg(tok)
+		if err != nil {
+			return nil, err
+		}
+		re, err := opml.GlobRegexp(pattern, tok == "-ipath")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", tok, err)
+		}
+		return &pathExpr{re}, nil
+	case "-regex", "-iregex":
+		pattern, err := p.arg(tok)
+		if err != nil {
+			return nil, err
+		}
+		if tok == "-iregex" {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", tok, err)
+		}
+		return &pathExpr{re}, nil
+	case "-d", "-depth":
+		// -depth n tests the depth, -depth alone selects post-order
+		if s := p.peek(); s != "" && isNumeric(s) {
+			cmp, err := parseComparison(p.next())
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", tok, err)
+			}
+			return &depthExpr{cmp}, nil
+		}
+		p.postOrder = true
+		return &trueExpr{}, nil
+	case "-maxdepth", "-mindepth":
+		s, err := p.arg(tok)
+		if err != nil {
+			return nil, err
+		}
+		i, err := strconv.Atoi(s)
+		if err != nil || i < 0 {
+			return nil, fmt.Errorf("%s: %s: illegal numeric value", tok, s)
+		}
+		if tok == "-maxdepth" {
+			p.maxDepth = i
+		} else {
+			p.minDepth = i
+		}
+		return &trueExpr{}, nil
+	case "-empty":
+		return &emptyExpr{}, nil
+	case "-prune":
+		return &pruneExpr{}, nil
+	case "-print", "-true":
+		return &trueExpr{}, nil
+	case "-false":
+		return &notExpr{&trueExpr{}}, nil
+	case "-Bmin":
+		s, err := p.arg(tok)
+		if err != nil {
+			return nil, err
+		}
+		cmp, err := parseComparison(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", tok, err)
+		}
+		return &createdExpr{cmp: cmp, unit: time.Minute, now: p.now}, nil
+	case "-Btime":
+		s, err := p.arg(tok)
+		if err != nil {
+			return nil, err
+		}
+		return parseBtime(s, p.now)
+	}
+	if tok == "" {
+		return nil, fmt.Errorf("expression expected")
+	}
+	return nil, fmt.Errorf("%s: unknown primary or operator", tok)
+}
+
+func (p *parser) attrPrimary(primary string, name string, caseInsensitive bool, each bool) (expr, error) {
+	pattern, err := p.arg(primary)
+	if err != nil {
+		return nil, err
+	}
+	re, err := opml.GlobRegexp(pattern, caseInsensitive)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", primary, err)
+	}
+	return &attrExpr{name: name, re: re, each: each}, nil
+}
+
+func isNumeric(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	if s == "" {
+		return false
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+func parseComparison(s string) (comparison, error) {
+	cmp := comparison{}
+	if strings.HasPrefix(s, "+") {
+		cmp.sign, s = 1, s[1:]
+	} else if strings.HasPrefix(s, "-") {
+		cmp.sign, s = -1, s[1:]
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return cmp, fmt.Errorf("%s: illegal numeric value", s)
+	}
+	cmp.n = n
+	return cmp, nil
+}
+
+// parseBtime handles "-Btime n[smhdw]". Without units n is in 24 hour
+// periods, with units the age is compared in seconds.
+func parseBtime(s string, now time.Time) (expr, error) {
+	cmp := comparison{}
+	val := s
+	if strings.HasPrefix(val, "+") {
+		cmp.sign, val = 1, val[1:]
+	} else if strings.HasPrefix(val, "-") {
+		cmp.sign, val = -1, val[1:]
+	}
+	if n, err := strconv.Atoi(val); err == nil {
+		cmp.n = float64(n)
+		return &createdExpr{cmp: cmp, unit: 24 * time.Hour, now: now}, nil
+	}
+	units := map[byte]time.Duration{
+		's': time.Second,
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}
+	var total time.Duration
+	digits := ""
+	for i := 0; i < len(val); i++ {
+		c := val[i]
+		if c >= '0' && c <= '9' {
+			digits += string(c)
+			continue
+		}
+		unit, ok := units[c]
+		if !ok || digits == "" {
+			return nil, fmt.Errorf("-Btime: %s: illegal time value", s)
+		}
+		n, _ := strconv.Atoi(digits)
+		total += time.Duration(n) * unit
+		digits = ""
+	}
+	if digits != "" {
+		return nil, fmt.Errorf("-Btime: %s: illegal time value", s)
+	}
+	cmp.n = total.Seconds()
+	return &createdExpr{cmp: cmp, unit: time.Second, now: now}, nil
+}
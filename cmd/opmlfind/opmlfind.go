@@ -0,0 +1,428 @@
+//
+// opmlfind is a command line utility, inspired by find(1), that walks the outlines of
+// one or more OPML files evaluating an expression for each outline element.
+//
+// @author R. S. Doiel, <rsdoiel@gmail.com>
+//
+// Copyright (c) 2021, R. S. Doiel
+// All rights not granted herein are expressly reserved by R. S. Doiel.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	// My Packages
+	"github.com/rsdoiel/opml"
+)
+
+var (
+	helpText = `%{app_name}(1) | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] [FILE ...] [EXPRESSION]
+
+# DESCRIPTION
+
+{app_name} walks the outline of each OPML file listed, evaluating
+an EXPRESSION for each outline element. Elements where the expression
+is true are written to standard out. If no files are listed the OPML
+is read from standard input, use "--" before the expression in that case.
+
+Paths are one based positions in the outline, e.g. "/3/2" is the second
+child of the third outline element in the body.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-d
+: visit outlines in post-order, children before their parent
+
+-s
+: visit the children of each outline in lexicographical order of their text
+
+-E
+: accepted for compatibility with find(1), regular expressions are always
+Go's RE2 syntax
+
+-L
+: include the type and text of each element when printing paths
+
+-f
+: an OPML file to search, may be repeated
+
+-format
+: output "path" (default), "url" or "opml", the OPML output keeps
+the ancestors of matching elements
+
+-pretty
+: pretty print OPML output
+
+-canonical
+: write canonical OPML output, indented with a stable attribute order
+
+# PRIMARIES
+
+Numeric arguments may be preceded by "+" (more than n) or "-" (less than n),
+otherwise they mean exactly n. Patterns are shell patterns using "*", "?"
+and "[...]".
+
+-text pattern, -name pattern
+: the text attribute matches pattern, -itext and -iname ignore case
+
+-title pattern
+: the title attribute matches pattern, -ititle ignores case
+
+-type t
+: the type attribute is t, e.g. rss, link, include
+
+-xmlurl pattern, -htmlurl pattern, -url pattern
+: the url attribute matches pattern, -ixmlurl, -ihtmlurl and -iurl ignore case
+
+-category pattern
+: one of the comma separated categories matches pattern
+
+-attr name pattern
+: the attribute name, including custom attributes, matches pattern
+
+-has name
+: the attribute name is set
+
+-query expression
+: the element matches an opml package query expression, e.g.
+'type = rss and @priority >= 2'
+
+-path pattern, -ipath pattern
+: the path of text attributes (e.g. "/News/Tech") matches pattern
+
+-regex pattern, -iregex pattern
+: the path of text attributes matches the regular expression
+
+-Bmin n
+: the element was created n minutes ago, rounded up to the next minute
+
+-Btime n[smhdw]
+: the element was created n days ago or, with units, n seconds, minutes,
+hours, days or weeks ago
+
+-depth n
+: the element is at depth n, outline elements in the body are depth 1
+
+-depth, -d
+: always true, same as the -d option
+
+-maxdepth n
+: always true, descend at most n levels
+
+-mindepth n
+: always true, don't test elements at depths less than n
+
+-empty
+: the element has no children
+
+-prune
+: always true, don't descend into the element's children
+
+-print
+: always true
+
+# OPERATORS
+
+( expression )
+: grouping
+
+! expression, -not expression
+: negation
+
+expression -and expression, expression -a expression, expression expression
+: logical and
+
+expression -or expression, expression -o expression
+: logical or
+
+# EXAMPLES
+
+List the paths of all the rss outlines.
+
+~~~
+    {app_name} feeds.opml -type rss
+~~~
+
+List the feed urls for outlines under a "Tech" folder created in the
+last week.
+
+~~~
+    {app_name} -format url feeds.opml -path '/Tech/*' -Btime -1w
+~~~
+
+Search standard input for outlines with a custom priority attribute.
+
+~~~
+    cat feeds.opml | {app_name} -- -attr priority high
+~~~
+
+Write the rss outlines with a priority of two or more as OPML.
+
+~~~
+    {app_name} -format opml feeds.opml -query 'type = rss and @priority >= 2'
+~~~
+
+`
+
+	// Standard options
+	showHelp    bool
+	showVersion bool
+	showLicense bool
+
+	// Application options
+	postOrder   bool
+	lexical     bool
+	extendedRE  bool
+	longFormat  bool
+	outFormat   string
+	prettyPrint bool
+	canonical   bool
+	fileNames   fileList
+)
+
+// fileList collects repeated -f options
+type fileList []string
+
+func (l *fileList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *fileList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// isExprStart returns true if the argument starts an expression
+func isExprStart(s string) bool {
+	return strings.HasPrefix(s, "-") || s == "(" || s == "!"
+}
+
+// finder walks an outline evaluating an expression
+type finder struct {
+	e        expr
+	p        *parser
+	matches  []*node
+	fname    string
+	multiple bool
+}
+
+func (f *finder) visit(l []*opml.Outline, parent opml.OutlinePath, textPath string, depth int) {
+	order := make([]int, len(l))
+	for i := range order {
+		order[i] = i
+	}
+	if lexical {
+		sort.SliceStable(order, func(i, j int) bool {
+			return l[order[i]].Text < l[order[j]].Text
+		})
+	}
+	for _, i := range order {
+		elem := l[i]
+		if elem == nil {
+			continue
+		}
+		n := &node{
+			outline:  elem,
+			path:     parent.Child(i + 1),
+			textPath: textPath + "/" + elem.Text,
+			depth:    depth,
+		}
+		test := depth >= f.p.minDepth
+		if !f.p.postOrder && test && f.e.eval(n) {
+			f.matches = append(f.matches, n)
+		}
+		if (f.p.postOrder || !n.prune) && (f.p.maxDepth < 0 || depth < f.p.maxDepth) {
+			f.visit(elem.Outline, n.path, n.textPath, depth+1)
+		}
+		if f.p.postOrder && test && f.e.eval(n) {
+			f.matches = append(f.matches, n)
+		}
+	}
+}
+
+func main() {
+	appName := path.Base(os.Args[0])
+	// NOTE: the following are set when version.go is generated
+	version := opml.Version
+	releaseDate := opml.ReleaseDate
+	releaseHash := opml.ReleaseHash
+	fmtHelp := opml.FmtHelp
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+
+	// Application Options
+	flag.BoolVar(&postOrder, "d", false, "visit outlines in post-order")
+	flag.BoolVar(&lexical, "s", false, "visit children in lexicographical order")
+	flag.BoolVar(&extendedRE, "E", false, "use extended regular expressions (always true)")
+	flag.BoolVar(&longFormat, "L", false, "include type and text with paths")
+	flag.Var(&fileNames, "f", "OPML file to search")
+	flag.StringVar(&outFormat, "format", "path", "output format, path, url or opml")
+	flag.BoolVar(&prettyPrint, "pretty", false, "pretty print OPML output")
+	flag.BoolVar(&canonical, "canonical", false, "write canonical OPML output")
+
+	// Process environment and options
+	flag.Parse()
+	args := flag.Args()
+
+	out := os.Stdout
+	eout := os.Stderr
+
+	// Handle options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", fmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", opml.LicenseText)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "%s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+
+	switch outFormat {
+	case "path", "url", "opml":
+	default:
+		fmt.Fprintf(eout, "unknown format %q, expected path, url or opml\n", outFormat)
+		os.Exit(1)
+	}
+
+	// Files are listed before the expression
+	for len(args) > 0 && !isExprStart(args[0]) {
+		fileNames = append(fileNames, args[0])
+		args = args[1:]
+	}
+
+	p, e, err := parseExpr(args, time.Now())
+	if err != nil {
+		fmt.Fprintf(eout, "%s: %s\n", appName, err)
+		os.Exit(1)
+	}
+	if postOrder {
+		p.postOrder = true
+	}
+
+	type source struct {
+		fname string
+		doc   *opml.OPML
+	}
+	sources := []source{}
+	if len(fileNames) == 0 {
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			os.Exit(1)
+		}
+		doc, err := opml.Parse(src)
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			os.Exit(1)
+		}
+		sources = append(sources, source{"-", doc})
+	}
+	for _, fname := range fileNames {
+		doc, err := opml.ReadFile(fname)
+		if err != nil {
+			fmt.Fprintf(eout, "%s: %s\n", fname, err)
+			os.Exit(1)
+		}
+		sources = append(sources, source{fname, doc})
+	}
+
+	result := opml.New()
+	result.Head.Title = fmt.Sprintf("%s results", appName)
+	for _, s := range sources {
+		f := &finder{e: e, p: p, fname: s.fname, multiple: len(sources) > 1}
+		if s.doc.Body != nil {
+			f.visit(s.doc.Body.Outline, opml.OutlinePath{}, "", 1)
+		}
+		if outFormat == "opml" {
+			matched := map[string]bool{}
+			for _, n := range f.matches {
+				matched[n.path.String()] = true
+			}
+			subset := s.doc.FilterFunc(func(ol *opml.Outline, p opml.OutlinePath) bool {
+				return matched[p.String()]
+			})
+			result.Body.Outline = append(result.Body.Outline, subset.Body.Outline...)
+			continue
+		}
+		for _, n := range f.matches {
+			switch outFormat {
+			case "url":
+				for _, u := range []string{n.outline.XMLURL, n.outline.HTMLURL, n.outline.URL} {
+					if u != "" {
+						fmt.Fprintf(out, "%s\n", u)
+						break
+					}
+				}
+			default:
+				writePath(out, f, n)
+			}
+		}
+	}
+
+	if outFormat == "opml" {
+		opts := opml.EncodeOptions{Declaration: true, Canonical: canonical}
+		if prettyPrint {
+			opts.Indent = "    "
+		}
+		if err := opml.NewEncoderWith(out, opts).Encode(result); err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func writePath(out io.Writer, f *finder, n *node) {
+	if f.multiple {
+		fmt.Fprintf(out, "%s:", f.fname)
+	}
+	if longFormat {
+		fmt.Fprintf(out, "%s\t%s\t%s\n", n.path, n.outline.Type, n.outline.Text)
+	} else {
+		fmt.Fprintf(out, "%s\n", n.path)
+	}
+}
@@ -0,0 +1,327 @@
+//
+// opmlindex is a command line utility that indexes the items of the feeds in an OPML
+// subscription list and searches them offline.
+//
+// @author R. S. Doiel, <rsdoiel@gmail.com>
+//
+// Copyright (c) 2021, R. S. Doiel
+// All rights not granted herein are expressly reserved by R. S. Doiel.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	// My Packages
+	"github.com/rsdoiel/opml"
+	"github.com/rsdoiel/opml/fetch"
+	"github.com/rsdoiel/opml/index"
+	"github.com/rsdoiel/opml/river"
+)
+
+var (
+	helpText = `%{app_name}(1) | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] update [INPUT_FILENAME]
+
+{app_name} [OPTIONS] search QUERY
+
+# DESCRIPTION
+
+{app_name} keeps a full-text index of the items of the feeds in an
+OPML subscription list so they can be searched offline. Each item is
+indexed with its title, summary, link and date and the path of the
+outline element of its feed.
+
+update
+: polls the feeds of INPUT_FILENAME into the store directory, the same
+store opmlriver uses, then adds the new and changed items in the store
+to the index. Without INPUT_FILENAME the items already in the store
+are indexed. Items dropped from the store stay in the index.
+
+search
+: lists the items matching QUERY, the best matches first. A QUERY is
+words and "quoted phrases" that must all appear in an item's title or
+summary, a word or phrase starting with "-" must not appear. Matches
+in the title count twice.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-index
+: the index file (default "index.json")
+
+-store
+: the directory holding the harvested feeds (default "river-data")
+
+-format
+: the search results format, "text" (default) or "json"
+
+-limit
+: the number of search results, 0 lists all of them (default 20)
+
+-path
+: limit the search to the feeds at or under an outline path, e.g. "/2"
+
+-since
+: limit the search to items dated on or after a date, e.g. "2021-03-01"
+
+-workers
+: the number of feeds fetched at once (default 4)
+
+-timeout
+: the time allowed for fetching each feed, e.g. "10s" (default 30s)
+
+-quiet
+: suppress error messages
+
+# EXAMPLES
+
+Harvest the feeds in myfeeds.opml and index them.
+
+~~~
+    {app_name} update myfeeds.opml
+~~~
+
+Search for items about OPML that mention "river of news" but not
+RSS.
+
+~~~
+    {app_name} search 'opml "river of news" -rss'
+~~~
+
+List the items from the feeds in the second folder as JSON.
+
+~~~
+    {app_name} -path /2 -limit 0 -format json search
+~~~
+
+`
+
+	// Standard options
+	showHelp     bool
+	showVersion  bool
+	showLicense  bool
+	showExamples bool
+	quiet        bool
+
+	// Application options
+	indexFName string
+	storeDir   string
+	format     string
+	limit      int
+	pathLimit  string
+	since      string
+	workers    int
+	timeout    time.Duration
+)
+
+// update polls the feeds of the OPML file fname into store, if fname
+// isn't empty, then indexes the stored feeds
+func update(ix *index.Index, store *river.FileStore, fname string, userAgent string) error {
+	if fname != "" {
+		o, err := opml.ReadFile(fname)
+		if err != nil {
+			return err
+		}
+		cache, err := fetch.NewDiskCache(filepath.Join(store.Dir, "cache"))
+		if err != nil {
+			return err
+		}
+		a := river.New(store, river.Options{
+			Fetch: fetch.Options{
+				Workers:   workers,
+				Timeout:   timeout,
+				UserAgent: userAgent,
+				Cache:     cache,
+			},
+		})
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		updates, err := a.Poll(ctx, o)
+		if err != nil {
+			return err
+		}
+		if !quiet {
+			for _, u := range updates {
+				if u.Err != nil {
+					fmt.Fprintf(os.Stderr, "%s\n", u)
+				}
+			}
+		}
+	}
+	feeds, err := store.Feeds()
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, f := range feeds {
+		n += ix.AddFeed(f)
+	}
+	if err := ix.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "%d items added or changed, %d in the index\n", n, ix.Len())
+	return nil
+}
+
+// search writes the hits for query
+func search(ix *index.Index, query string) error {
+	opts := index.SearchOptions{Limit: limit}
+	if limit == 0 {
+		opts.Limit = -1
+	}
+	if pathLimit != "" {
+		p, err := opml.ParsePath(pathLimit)
+		if err != nil {
+			return err
+		}
+		opts.Path = p
+	}
+	if since != "" {
+		t, err := opml.ParseDate(since)
+		if err != nil {
+			return err
+		}
+		opts.Since = t
+	}
+	hits := ix.Search(query, opts)
+	if format == "json" {
+		src, err := json.MarshalIndent(hits, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "%s\n", src)
+		return nil
+	}
+	for _, hit := range hits {
+		date := ""
+		if !hit.Date.IsZero() {
+			date = hit.Date.Format("2006-01-02")
+		}
+		fmt.Fprintf(os.Stdout, "%s\t%s\t%s\t%s\t%s\n", date, hit.Path, hit.FeedTitle, hit.Title, hit.Link)
+	}
+	return nil
+}
+
+func main() {
+	appName := path.Base(os.Args[0])
+	// NOTE: the following are set when version.go is generated
+	version := opml.Version
+	releaseDate := opml.ReleaseDate
+	releaseHash := opml.ReleaseHash
+	fmtHelp := opml.FmtHelp
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.BoolVar(&showExamples, "examples", false, "display examples")
+	flag.BoolVar(&quiet, "quiet", false, "suppress error messages")
+
+	// Application Options
+	flag.StringVar(&indexFName, "index", "index.json", "index file")
+	flag.StringVar(&storeDir, "store", "river-data", "directory holding the harvested feeds")
+	flag.StringVar(&format, "format", "text", "search results format, text or json")
+	flag.IntVar(&limit, "limit", index.DefaultLimit, "number of search results, 0 lists all")
+	flag.StringVar(&pathLimit, "path", "", "limit the search to the feeds under an outline path")
+	flag.StringVar(&since, "since", "", "limit the search to items dated on or after a date")
+	flag.IntVar(&workers, "workers", fetch.DefaultWorkers, "number of feeds fetched at once")
+	flag.DurationVar(&timeout, "timeout", fetch.DefaultTimeout, "time allowed for fetching each feed")
+
+	// Process environment and options
+	flag.Parse()
+	args := flag.Args()
+
+	// Setup I/O
+	out := os.Stdout
+	eout := os.Stderr
+
+	// Handle options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", fmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", opml.LicenseText)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "%s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+	exit := func(err error) {
+		if !quiet {
+			fmt.Fprintf(eout, "%s\n", err)
+		}
+		os.Exit(1)
+	}
+	if len(args) == 0 {
+		exit(fmt.Errorf("missing a verb, see %s -help", appName))
+	}
+	if format != "text" && format != "json" {
+		exit(fmt.Errorf("unknown format %q, expected text or json", format))
+	}
+
+	ix, err := index.Open(indexFName)
+	if err != nil {
+		exit(err)
+	}
+	switch args[0] {
+	case "update":
+		if len(args) > 2 {
+			exit(fmt.Errorf("update takes one OPML filename"))
+		}
+		fname := ""
+		if len(args) == 2 {
+			fname = args[1]
+		}
+		store, err := river.NewFileStore(storeDir)
+		if err != nil {
+			exit(err)
+		}
+		err = update(ix, store, fname, fmt.Sprintf("%s/%s", appName, version))
+	case "search":
+		err = search(ix, strings.Join(args[1:], " "))
+	default:
+		err = fmt.Errorf("unknown verb %q", args[0])
+	}
+	if err != nil {
+		exit(err)
+	}
+}
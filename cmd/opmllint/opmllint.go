@@ -0,0 +1,210 @@
+//
+// opmllint is a command line utility that checks OPML files against the OPML 2.0 spec.
+//
+// @author R. S. Doiel, <rsdoiel@gmail.com>
+//
+// Copyright (c) 2021, R. S. Doiel
+// All rights not granted herein are expressly reserved by R. S. Doiel.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	// My Packages
+	"github.com/rsdoiel/opml"
+)
+
+var (
+	helpText = `%{app_name}(1) | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] [FILE ...]
+
+# DESCRIPTION
+
+{app_name} checks one or more OPML files against the OPML 2.0 spec
+and prints a diagnostic for each problem found. If no files are listed
+the OPML is read from standard input. It exits with a non-zero status
+if a file can't be parsed or has errors.
+
+Diagnostics are written as FILE: WHERE ATTRIBUTE: SEVERITY: MESSAGE
+where WHERE is "opml", "head" or the path of an outline element
+(e.g. "/3/2" is the second child of the third element in the body).
+Diagnostics for outline elements include the line and column,
+FILE:LINE:COLUMN: WHERE ATTRIBUTE: SEVERITY: MESSAGE. Parse errors
+are reported with the line, column and enclosing outline path.
+
+Checks include the version, required text attributes, xmlUrl on
+type="rss", url on type="link" and type="include", RFC 822 dates,
+expansionState line numbers and absolute URLs.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-json
+: write the diagnostics as a JSON object keyed by filename
+
+-quiet
+: only report errors, not warnings
+
+-strict
+: exit with a non-zero status for warnings as well as errors
+
+# EXAMPLES
+
+~~~
+    {app_name} feeds.opml
+~~~
+
+Check an export from a feed reader in a shell script.
+
+~~~
+    if {app_name} -quiet subscriptions.opml; then
+        opmlcat subscriptions.opml team.opml >merged.opml
+    fi
+~~~
+
+`
+
+	// Standard options
+	showHelp    bool
+	showVersion bool
+	showLicense bool
+
+	// Application options
+	asJSON bool
+	quiet  bool
+	strict bool
+)
+
+func main() {
+	appName := path.Base(os.Args[0])
+	// NOTE: the following are set when version.go is generated
+	version := opml.Version
+	releaseDate := opml.ReleaseDate
+	releaseHash := opml.ReleaseHash
+	fmtHelp := opml.FmtHelp
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+
+	// Application Options
+	flag.BoolVar(&asJSON, "json", false, "write diagnostics as JSON")
+	flag.BoolVar(&quiet, "quiet", false, "only report errors")
+	flag.BoolVar(&strict, "strict", false, "treat warnings as errors")
+
+	// Process environment and options
+	flag.Parse()
+	args := flag.Args()
+
+	out := os.Stdout
+	eout := os.Stderr
+
+	// Handle options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", fmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", opml.LicenseText)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "%s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+
+	failed := false
+	report := map[string][]opml.Diagnostic{}
+	for _, fname := range args {
+		var (
+			src []byte
+			err error
+		)
+		if fname == "-" {
+			src, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			src, err = ioutil.ReadFile(fname)
+		}
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			failed = true
+			continue
+		}
+		o, err := opml.ParseWith(src, opml.ParseOptions{Filename: fname, Positions: true})
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			failed = true
+			continue
+		}
+		diagnostics := []opml.Diagnostic{}
+		for _, d := range opml.Validate(o) {
+			if quiet && d.Severity != opml.SeverityError {
+				continue
+			}
+			if d.Severity == opml.SeverityError || strict {
+				failed = true
+			}
+			diagnostics = append(diagnostics, d)
+		}
+		if asJSON {
+			report[fname] = diagnostics
+			continue
+		}
+		for _, d := range diagnostics {
+			if d.Pos != nil {
+				fmt.Fprintf(out, "%s\n", d)
+			} else {
+				fmt.Fprintf(out, "%s: %s\n", fname, d)
+			}
+		}
+	}
+	if asJSON {
+		src, err := json.MarshalIndent(report, "", "    ")
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(out, "%s\n", src)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
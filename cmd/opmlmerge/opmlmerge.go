@@ -0,0 +1,273 @@
+//
+// opmlmerge is a command line utility that merges one or more OPML files removing duplicate
+// feeds and returns a single file as a result.
+//
+// @author R. S. Doiel, <rsdoiel@gmail.com>
+//
+// Copyright (c) 2021, R. S. Doiel
+// All rights not granted herein are expressly reserved by R. S. Doiel.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	// My Packages
+	"github.com/rsdoiel/opml"
+)
+
+var (
+	helpText = `%{app_name}(1) | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] FILE [FILE ...]
+
+# DESCRIPTION
+
+{app_name} merges one or more opml files removing duplicate feeds.
+Outline elements are duplicates if their xmlUrl (or url if there is
+no xmlUrl) are the same ignoring the difference between http and https,
+the case of the host, a port that is the scheme's default, a trailing
+slash and the fragment. The first element is
+kept where it is and the attributes of its duplicates are merged into
+it. Folders with the same text at the same level are merged. The head
+of the first file is kept.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-o
+: write to filename
+
+-newline
+: add trailing newline
+
+-pretty
+: pretty print XML output
+
+-canonical
+: write canonical XML, indented with a stable attribute order, for
+keeping outlines in version control
+
+-policy
+: the value kept when duplicates have different values for an
+attribute, "first" (default), "last" or "longest". Empty values are
+always filled in from duplicates.
+
+-feedburner
+: treat feeds.feedburner.com, feeds2.feedburner.com and
+feedproxy.google.com feeds with the same name as duplicates
+
+-alias
+: read URL aliases from filename, each line holds a URL and the URL
+it redirects to separated by spaces, lines starting with "#" are
+ignored
+
+-keep-scheme
+: http and https URLs are not duplicates
+
+-keep-host-case
+: compare the host of URLs case sensitively
+
+-keep-trailing-slash
+: URLs with and without a trailing slash are not duplicates
+
+-report
+: write what was merged to standard error
+
+-quiet
+: suppress error messages
+
+# EXAMPLES
+
+Merge the subscription lists of a team reporting the duplicates.
+
+~~~
+    {app_name} -report -o team.opml alice.opml bob.opml
+~~~
+
+`
+
+	// Standard options
+	showHelp     bool
+	showVersion  bool
+	showLicense  bool
+	showExamples bool
+	outputFName  string
+	quiet        bool
+	newLine      bool
+
+	// Application options
+	prettyPrint bool
+	canonical   bool
+	policy      string
+	feedBurner  bool
+	aliasFName  string
+	report      bool
+
+	keepScheme        bool
+	keepHostCase      bool
+	keepTrailingSlash bool
+)
+
+// readAliases reads a file of "URL ALIAS" lines
+func readAliases(fname string) (map[string]string, error) {
+	fp, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	aliases := map[string]string{}
+	scanner := bufio.NewScanner(fp)
+	for i := 1; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected a URL and its alias", fname, i)
+		}
+		aliases[fields[0]] = fields[1]
+	}
+	return aliases, scanner.Err()
+}
+
+func main() {
+	appName := path.Base(os.Args[0])
+	// NOTE: the following are set when version.go is generated
+	version := opml.Version
+	releaseDate := opml.ReleaseDate
+	releaseHash := opml.ReleaseHash
+	fmtHelp := opml.FmtHelp
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.BoolVar(&showExamples, "examples", false, "display examples")
+	flag.BoolVar(&quiet, "quiet", false, "suppress error messages")
+	flag.BoolVar(&newLine, "newline", false, "add a trailing newline")
+	flag.StringVar(&outputFName, "o", "", "set output filename")
+
+	// Application Options
+	flag.BoolVar(&prettyPrint, "pretty", false, "pretty print XML output")
+	flag.BoolVar(&canonical, "canonical", false, "write canonical XML output")
+	flag.StringVar(&policy, "policy", "first", "conflict policy, first, last or longest")
+	flag.BoolVar(&feedBurner, "feedburner", false, "merge FeedBurner feeds with the same name")
+	flag.StringVar(&aliasFName, "alias", "", "read URL aliases from filename")
+	flag.BoolVar(&keepScheme, "keep-scheme", false, "http and https URLs are not duplicates")
+	flag.BoolVar(&keepHostCase, "keep-host-case", false, "compare hosts case sensitively")
+	flag.BoolVar(&keepTrailingSlash, "keep-trailing-slash", false, "compare trailing slashes")
+	flag.BoolVar(&report, "report", false, "report merged duplicates")
+
+	// Process environment and options
+	flag.Parse()
+	args := flag.Args()
+
+	// Setup I/O
+	var err error
+
+	out := os.Stdout
+	eout := os.Stderr
+
+	// Handle options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", fmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", opml.LicenseText)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "%s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+	exit := func(err error) {
+		if !quiet {
+			fmt.Fprintf(eout, "%s\n", err)
+		}
+		os.Exit(1)
+	}
+
+	if len(args) == 0 {
+		exit(fmt.Errorf("expected one or more OPML files, see %s -help", appName))
+	}
+	opts := opml.MergeOptions{
+		FeedBurner:        feedBurner,
+		KeepScheme:        keepScheme,
+		KeepHostCase:      keepHostCase,
+		KeepTrailingSlash: keepTrailingSlash,
+	}
+	if opts.Policy, err = opml.ParseConflictPolicy(policy); err != nil {
+		exit(err)
+	}
+	if aliasFName != "" {
+		if opts.Aliases, err = readAliases(aliasFName); err != nil {
+			exit(err)
+		}
+	}
+
+	docs := []*opml.OPML{}
+	for _, fname := range args {
+		o, err := opml.ReadFileWith(fname, opml.ParseOptions{Preserve: true})
+		if err != nil {
+			exit(err)
+		}
+		docs = append(docs, o)
+	}
+	o, r := opml.Merge(opts, docs...)
+
+	if outputFName != "" {
+		out, err = os.Create(outputFName)
+		if err != nil {
+			exit(err)
+		}
+		defer out.Close()
+	}
+
+	encOpts := opml.EncodeOptions{Declaration: true, Canonical: canonical}
+	if prettyPrint {
+		encOpts.Indent = "    "
+	}
+	if err := opml.NewEncoderWith(out, encOpts).Encode(o); err != nil {
+		exit(err)
+	}
+	if newLine {
+		fmt.Fprintln(out)
+	}
+	if report {
+		fmt.Fprintf(eout, "%s", r)
+	}
+}
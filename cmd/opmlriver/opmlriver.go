@@ -0,0 +1,340 @@
+//
+// opmlriver is a command line utility that polls the feeds of an OPML subscription list and
+// renders a river of news as HTML and River.js JSON.
+//
+// @author R. S. Doiel, <rsdoiel@gmail.com>
+//
+// Copyright (c) 2021, R. S. Doiel
+// All rights not granted herein are expressly reserved by R. S. Doiel.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	// My Packages
+	"github.com/rsdoiel/opml"
+	"github.com/rsdoiel/opml/fetch"
+	"github.com/rsdoiel/opml/river"
+)
+
+var (
+	helpText = `%{app_name}(1) | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] [INPUT_FILENAME]
+
+# DESCRIPTION
+
+{app_name} reads an OPML subscription list, polls the feed of each
+outline element with an xmlUrl and keeps their items in a store
+directory. It then renders a river of news, the newest items first
+grouped by feed, as
+
+index.html
+: a static HTML page
+
+river.js
+: the river in the River.js JSON format wrapped in a call to
+onGetRiverStream
+
+river.json
+: the river in the River.js JSON format
+
+Feeds that haven't changed are revalidated with a conditional GET
+using the responses cached in the store's "cache" directory. With
+-interval the feeds are polled and the river rendered on a schedule
+until {app_name} is interrupted. Only the feeds in the OPML file are
+in the river, feeds removed from it are kept in the store but left
+out.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-store
+: the directory holding the feeds and their items (default "river-data")
+
+-o
+: the directory the river is written to (default ".")
+
+-interval
+: poll the feeds every interval, e.g. "15m", the feeds are polled
+once if it isn't set
+
+-render
+: render the river from the store without polling the feeds, all the
+stored feeds are rendered if no OPML file is given
+
+-workers
+: the number of feeds fetched at once (default 4)
+
+-timeout
+: the time allowed for fetching each feed, e.g. "10s" (default 30s)
+
+-keep
+: the number of items kept for each feed (default 100)
+
+-items
+: the number of items in the river (default 250)
+
+-hours
+: leave out items older than this many hours, 0 includes items of
+any age (default 24)
+
+-title
+: the title of the HTML page, the OPML title is used by default
+
+-callback
+: the function river.js calls (default "onGetRiverStream")
+
+-quiet
+: suppress error messages
+
+# EXAMPLES
+
+Poll the feeds in myfeeds.opml every 15 minutes writing the river to
+the htdocs directory.
+
+~~~
+    {app_name} -interval 15m -o htdocs myfeeds.opml
+~~~
+
+Render a week of items from the store without polling.
+
+~~~
+    {app_name} -render -hours 168 -o htdocs myfeeds.opml
+~~~
+
+`
+
+	// Standard options
+	showHelp     bool
+	showVersion  bool
+	showLicense  bool
+	showExamples bool
+	inputFName   string
+	outputDir    string
+	quiet        bool
+
+	// Application options
+	storeDir   string
+	interval   time.Duration
+	renderOnly bool
+	workers    int
+	timeout    time.Duration
+	keepItems  int
+	riverItems int
+	hours      int
+	title      string
+	callback   string
+)
+
+// writeFile replaces fname with what write writes so a web server
+// never serves a partial file
+func writeFile(fname string, write func(io.Writer) error) error {
+	fp, err := ioutil.TempFile(filepath.Dir(fname), ".river-*")
+	if err != nil {
+		return err
+	}
+	if err := write(fp); err != nil {
+		fp.Close()
+		os.Remove(fp.Name())
+		return err
+	}
+	if err := fp.Close(); err != nil {
+		os.Remove(fp.Name())
+		return err
+	}
+	if err := os.Chmod(fp.Name(), 0664); err != nil {
+		os.Remove(fp.Name())
+		return err
+	}
+	return os.Rename(fp.Name(), fname)
+}
+
+// render writes the river of the stored feeds to outputDir, if o isn't
+// nil only the feeds in o are included
+func render(store river.Store, o *opml.OPML, appName string, version string) error {
+	feeds, err := store.Feeds()
+	if err != nil {
+		return err
+	}
+	if o != nil {
+		feeds = river.Subscribed(feeds, o)
+	}
+	opts := river.RiverOptions{
+		MaxItems:   riverItems,
+		Aggregator: fmt.Sprintf("%s %s", appName, version),
+	}
+	if hours > 0 {
+		opts.Since = time.Now().Add(-time.Duration(hours) * time.Hour)
+	}
+	r := river.NewRiver(feeds, opts)
+	if err := writeFile(filepath.Join(outputDir, "index.html"), func(w io.Writer) error {
+		return r.WriteHTML(w, title)
+	}); err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(outputDir, "river.js"), func(w io.Writer) error {
+		return r.WriteJSON(w, callback)
+	}); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(outputDir, "river.json"), func(w io.Writer) error {
+		return r.WriteJSON(w, "")
+	})
+}
+
+func main() {
+	appName := path.Base(os.Args[0])
+	// NOTE: the following are set when version.go is generated
+	version := opml.Version
+	releaseDate := opml.ReleaseDate
+	releaseHash := opml.ReleaseHash
+	fmtHelp := opml.FmtHelp
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.BoolVar(&showExamples, "examples", false, "display examples")
+	flag.BoolVar(&quiet, "quiet", false, "suppress error messages")
+	flag.StringVar(&inputFName, "i", "", "set input filename")
+	flag.StringVar(&outputDir, "o", ".", "set output directory")
+
+	// Application Options
+	flag.StringVar(&storeDir, "store", "river-data", "directory holding the feeds and their items")
+	flag.DurationVar(&interval, "interval", 0, "poll the feeds every interval")
+	flag.BoolVar(&renderOnly, "render", false, "render the river without polling the feeds")
+	flag.IntVar(&workers, "workers", fetch.DefaultWorkers, "number of feeds fetched at once")
+	flag.DurationVar(&timeout, "timeout", fetch.DefaultTimeout, "time allowed for fetching each feed")
+	flag.IntVar(&keepItems, "keep", river.DefaultMaxItems, "number of items kept for each feed")
+	flag.IntVar(&riverItems, "items", river.DefaultRiverItems, "number of items in the river")
+	flag.IntVar(&hours, "hours", 24, "leave out items older than this many hours")
+	flag.StringVar(&title, "title", "", "title of the HTML page")
+	flag.StringVar(&callback, "callback", river.DefaultCallback, "function river.js calls")
+
+	// Process environment and options
+	flag.Parse()
+	args := flag.Args()
+	if len(args) > 0 {
+		inputFName = args[0]
+	}
+
+	// Setup I/O
+	out := os.Stdout
+	eout := os.Stderr
+
+	// Handle options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", fmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", opml.LicenseText)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "%s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+	exit := func(err error) {
+		if !quiet {
+			fmt.Fprintf(eout, "%s\n", err)
+		}
+		os.Exit(1)
+	}
+
+	store, err := river.NewFileStore(storeDir)
+	if err != nil {
+		exit(err)
+	}
+	if err := os.MkdirAll(outputDir, 0775); err != nil {
+		exit(err)
+	}
+	var o *opml.OPML
+	if inputFName != "" {
+		o, err = opml.ReadFile(inputFName)
+		if err != nil {
+			exit(err)
+		}
+		if title == "" && o.Head != nil {
+			title = o.Head.Title
+		}
+	}
+	if title == "" {
+		title = "River"
+	}
+	if renderOnly {
+		if err := render(store, o, appName, version); err != nil {
+			exit(err)
+		}
+		os.Exit(0)
+	}
+	if o == nil {
+		exit(fmt.Errorf("missing OPML filename, try %s -help", appName))
+	}
+	cache, err := fetch.NewDiskCache(filepath.Join(storeDir, "cache"))
+	if err != nil {
+		exit(err)
+	}
+	a := river.New(store, river.Options{
+		Fetch: fetch.Options{
+			Workers:   workers,
+			Timeout:   timeout,
+			UserAgent: fmt.Sprintf("%s/%s", appName, version),
+			Cache:     cache,
+		},
+		MaxItems: keepItems,
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = a.Run(ctx, o, interval, func(updates []river.Update) error {
+		if !quiet {
+			for _, u := range updates {
+				if u.Err != nil {
+					fmt.Fprintf(eout, "%s\n", u)
+				}
+			}
+		}
+		return render(store, o, appName, version)
+	})
+	if err != nil && err != context.Canceled {
+		exit(err)
+	}
+}
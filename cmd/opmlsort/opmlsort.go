@@ -20,7 +20,6 @@
 package main
 
 import (
-	"encoding/xml"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -48,6 +47,9 @@ var (
 # DESCRIPTION
 
 {app_name} is a program that sorts the outline in an OPML document.
+By default outline elements are sorted by their text attribute at
+every level. The sort is stable so elements that compare equal keep
+their order.
 
 # OPTIONS
 
@@ -70,14 +72,43 @@ var (
 : add a tailing newline
 
 -pretty
-: pretty print JSON output
+: pretty print XML output
+
+-canonical
+: write canonical XML, indented with a stable attribute order, for
+keeping outlines in version control
+
+-by
+: a comma separated list of outline attributes to sort by, e.g.
+"type,title". Elements with the same value for the first attribute
+are sorted by the second and so on. An attribute starting with "-" is
+sorted in descending order. Any attribute can be used including
+custom ones like "priority" or "team:owner". "created" is compared as
+a date and "children" sorts by the number of children. Add "/date",
+"/number" or "/host" to compare an attribute's values as dates,
+numbers or the host of a URL, e.g. "-lastRead/date", "dc:date/date"
+or "xmlUrl/host".
+The default is "text".
+
+-title
+: sort by title, the same as "-by title", it can't be combined with -by
 
 -case-insensitive
 : case insensitive sort
 
--title
-: sort by title
+-natural
+: compare runs of digits as numbers so "Episode 9" sorts before
+"Episode 10"
+
+-lang
+: sort using the Unicode collation of a language, e.g. "de" or "sv"
 
+-folders-first
+: sort outline elements with children before those without
+
+-depth
+: the number of levels to sort, 1 sorts the top level only. All
+levels are sorted if it is 0 (the default).
 
 # EXAMPLES
 
@@ -85,6 +116,20 @@ var (
     {app_name} myfeeds.opml sorted-feeds.opml
 ~~~
 
+Sort folders first, then by type and title ignoring case.
+
+~~~
+    {app_name} -folders-first -by type,title -case-insensitive \
+        myfeeds.opml sorted-feeds.opml
+~~~
+
+Sort by a custom priority attribute, highest first, then by the
+feed's host.
+
+~~~
+    {app_name} -by -priority/number,xmlUrl/host myfeeds.opml
+~~~
+
 `
 
 	// Standard options
@@ -98,8 +143,14 @@ var (
 
 	// Application options
 	prettyPrint     bool
-	caseInsensitive bool
+	canonical       bool
+	sortKeys        string
 	byTitle         bool
+	caseInsensitive bool
+	natural         bool
+	lang            string
+	foldersFirst    bool
+	depth           int
 )
 
 func main() {
@@ -120,8 +171,14 @@ func main() {
 
 	// Application Options
 	flag.BoolVar(&prettyPrint, "pretty", false, "pretty print XML output")
+	flag.BoolVar(&canonical, "canonical", false, "write canonical XML output")
+	flag.StringVar(&sortKeys, "by", "text", "attributes to sort by, e.g. type,title")
+	flag.BoolVar(&byTitle, "title", false, "sort by title")
 	flag.BoolVar(&caseInsensitive, "case-insensitive", false, "case insensitive sort")
-	flag.BoolVar(&byTitle, "title", true, "sort by title")
+	flag.BoolVar(&natural, "natural", false, "compare numbers in values numerically")
+	flag.StringVar(&lang, "lang", "", "sort using the collation of a language")
+	flag.BoolVar(&foldersFirst, "folders-first", false, "sort outline elements with children first")
+	flag.IntVar(&depth, "depth", 0, "number of levels to sort, 0 sorts all levels")
 
 	// Process environment and options
 	flag.Parse()
@@ -154,7 +211,7 @@ func main() {
 		os.Exit(0)
 	}
 	if showVersion {
-		fmt.Fprintln(out, "%s %s %s\n", appName, version, releaseHash)
+		fmt.Fprintf(out, "%s %s %s\n", appName, version, releaseHash)
 		os.Exit(0)
 	}
 
@@ -182,37 +239,47 @@ func main() {
 			fmt.Fprintf(eout,"%s", err)
 			os.Exit(1)
 		}
-		o, err = opml.Parse(src)
+		o, err = opml.ParseWith(src, opml.ParseOptions{Filename: inputFName, Preserve: true})
 		if err != nil {
 			fmt.Fprintf(eout,"%s", err)
 			os.Exit(1)
 		}
 	if byTitle {
-		if caseInsensitive {
-			o.SortTitleCaseInsensitive()
-		} else {
-			o.SortTitle()
-		}
-	} else {
-		if caseInsensitive {
-			o.SortCaseInsensitive()
-		} else {
-			o.Sort()
+		byGiven := false
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "by" {
+				byGiven = true
+			}
+		})
+		if byGiven {
+			fmt.Fprintf(eout, "-title can't be used with -by, add title to the -by keys instead\n")
+			os.Exit(1)
 		}
+		sortKeys = "title"
+	}
+	keys, err := opml.ParseSortKeys(sortKeys)
+	if err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		os.Exit(1)
+	}
+	for i := range keys {
+		keys[i].CaseInsensitive = caseInsensitive
+		keys[i].Natural = natural
+	}
+	sortOpts := opml.SortOptions{FoldersFirst: foldersFirst, Depth: depth, Language: lang}
+	if err := o.SortBy(sortOpts, keys...); err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		os.Exit(1)
 	}
 
+	opts := opml.EncodeOptions{Declaration: true, Canonical: canonical}
 	if prettyPrint {
-		src, err = xml.MarshalIndent(o, "", "    ")
-		if err != nil {
-			fmt.Fprintf(eout,"%s", err)
-			os.Exit(1)
-		}
-	} else {
-		src = []byte(o.String())
+		opts.Indent = "    "
+	}
+	if err := opml.NewEncoderWith(out, opts).Encode(o); err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		os.Exit(1)
 	}
-
-	fmt.Fprintln(out, `<?xml version="1.0" encoding="UTF-8"?>`)
-		fmt.Fprintf(out, "%s", src)
 	if newLine {
 		fmt.Fprintln(out)
 	}
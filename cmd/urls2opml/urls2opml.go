@@ -2,17 +2,21 @@ package main
 
 import (
 	"bufio"
-	"encoding/xml"
+	"context"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"net/url"
 	"os"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	// My packages
 	"github.com/rsdoiel/opml"
+	"github.com/rsdoiel/opml/feed"
+	"github.com/rsdoiel/opml/fetch"
 )
 
 const (
@@ -40,6 +44,24 @@ XML.  It reads from standard input and writes to standard output.
 -license
 : Display license
 
+-enrich
+: fetch each feed and fill in the outline element's text, title,
+htmlUrl, description and language from it. Lines may also name local
+feed files when enriching. RSS, Atom and JSON feeds are supported.
+
+-discover
+: treat each URL as a web page and find its feed from the page's
+<link rel="alternate"> elements, or if it has none by trying common
+feed paths like /feed and /rss.xml. The page becomes the htmlUrl.
+Pages with no feed are skipped and pages with several feeds use the
+first, both are reported. URLs that are feeds are kept as they are.
+
+-workers
+: the number of feeds or pages fetched at once when enriching or
+discovering (default 4)
+
+-timeout
+: the time allowed to fetch each feed or page, e.g. "10s" (default 30s)
 
 # EXAMPLE
 
@@ -51,6 +73,18 @@ cat .newsboat/url | cut -d \" -f 1 |\
 	>subscriptions.opml
 ~~~
 
+Convert a list of feeds filling in their titles and descriptions.
+
+~~~
+{app_name} -enrich <feeds.txt >subscriptions.opml
+~~~
+
+Convert a list of blog home pages to their feeds.
+
+~~~
+{app_name} -discover -enrich <blogs.txt >subscriptions.opml
+~~~
+
 `
 )
 
@@ -58,8 +92,77 @@ var (
 	showHelp    bool
 	showLicense bool
 	showVersion bool
+
+	// Application options
+	enrich   bool
+	discover bool
+	workers  int
+	timeout  time.Duration
 )
 
+// discoverFeeds replaces the page URL in the xmlUrl of each outline
+// element in remote with the page's feed. Elements whose page has no
+// feed are removed from o and remote. The feeds fetched while
+// discovering are returned by outline element so they aren't fetched
+// again when enriching.
+func discoverFeeds(o *opml.OPML, remote *opml.OPML, fetcher *fetch.Fetcher, eout *os.File) map[*opml.Outline]*feed.Feed {
+	results := make([]*feed.Discovery, len(remote.Body.Outline))
+	errs := make([]error, len(remote.Body.Outline))
+	sem := make(chan bool, workers)
+	wg := new(sync.WaitGroup)
+	for i, elem := range remote.Body.Outline {
+		wg.Add(1)
+		sem <- true
+		go func(i int, page string) {
+			defer wg.Done()
+			results[i], errs[i] = feed.Discover(context.Background(), fetcher, page)
+			<-sem
+		}(i, elem.XMLURL)
+	}
+	wg.Wait()
+
+	dropped := map[*opml.Outline]bool{}
+	fetched := map[*opml.Outline]*feed.Feed{}
+	for i, elem := range remote.Body.Outline {
+		d, page := results[i], elem.XMLURL
+		if errs[i] == nil && d.Feed != nil {
+			fetched[elem] = d.Feed
+		}
+		switch {
+		case errs[i] != nil:
+			fmt.Fprintf(eout, "%s, %s\n", page, errs[i])
+			dropped[elem] = true
+		case d.IsFeed:
+		case len(d.Feeds) == 0:
+			fmt.Fprintf(eout, "%s, no feed found\n", page)
+			dropped[elem] = true
+		default:
+			if len(d.Feeds) > 1 {
+				l := []string{}
+				for _, link := range d.Feeds {
+					l = append(l, link.URL)
+				}
+				fmt.Fprintf(eout, "%s, %d feeds found, using the first: %s\n", page, len(d.Feeds), strings.Join(l, " "))
+			}
+			elem.XMLURL = d.Feeds[0].URL
+			elem.HTMLURL = page
+			if elem.Text == "" {
+				elem.Text = d.Feeds[0].Title
+			}
+		}
+	}
+	for _, doc := range []*opml.OPML{o, remote} {
+		kept := []*opml.Outline{}
+		for _, elem := range doc.Body.Outline {
+			if !dropped[elem] {
+				kept = append(kept, elem)
+			}
+		}
+		doc.Body.Outline = kept
+	}
+	return fetched
+}
+
 func main() {
 	appName := path.Base(os.Args[0])
 	// NOTE: The followimg variables are set when version.go is generated
@@ -71,10 +174,12 @@ func main() {
 	flag.BoolVar(&showHelp, "help", false, "display help")
 	flag.BoolVar(&showVersion, "version", false, "display version")
 	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&enrich, "enrich", false, "fill in outline elements from the feeds")
+	flag.BoolVar(&discover, "discover", false, "find the feeds of web pages")
+	flag.IntVar(&workers, "workers", fetch.DefaultWorkers, "number of feeds fetched at once")
+	flag.DurationVar(&timeout, "timeout", fetch.DefaultTimeout, "time allowed to fetch each feed")
 	flag.Parse()
 
-	var err error
-
 	in := os.Stdin
 	out := os.Stdout
 	eout := os.Stderr
@@ -97,11 +202,14 @@ func main() {
 	label := fmt.Sprintf("url list convert with %s %s", appName, version)
 	o := opml.New()
 	o.Head.Title = label
-	o.Head.Created = time.Now().Format(time.RFC822Z)
+	o.Head.SetCreated(time.Now())
 	o.Body.Outline = []*opml.Outline{}
+	// remote holds the outline elements of the feeds to fetch
+	remote := opml.New()
 	scan := bufio.NewScanner(in)
-	i := 1
+	i := 0
 	for scan.Scan() {
+		i++
 		line := strings.TrimSpace(scan.Text())
 		if line == "" {
 			fmt.Fprintf(eout, "line %d is empty\n", i)
@@ -116,27 +224,79 @@ func main() {
 			fmt.Fprintf(eout, "line %d not a url %q, %s\n", i, line, err)
 			continue
 		}
+		if enrich && (u.Scheme == "" || u.Scheme == "file") {
+			// A local feed file
+			src, err := ioutil.ReadFile(u.Path)
+			if err != nil {
+				fmt.Fprintf(eout, "line %d, %s\n", i, err)
+				continue
+			}
+			f, err := feed.Parse(src)
+			if err != nil {
+				fmt.Fprintf(eout, "line %d, %s, %s\n", i, line, err)
+				continue
+			}
+			elem := f.Outline("")
+			if elem.XMLURL == "" {
+				elem.XMLURL = line
+			}
+			o.Body.Outline = append(o.Body.Outline, elem)
+			continue
+		}
 		if u.Scheme != "http" && u.Scheme != "https" {
 			fmt.Fprintf(eout, "line %d, skipping unsupported url %q\n", i, u.String())
 			continue
 		}
-		// FIXME: there should be an option to verify the link
-		// is still available by executing a GET, it could
-		// then populate the Outline element appropriately
-			elem := new(opml.Outline)
-			elem.XMLURL = line
-			o.Body.Outline = append(o.Body.Outline, elem)
-		i++
+		elem := new(opml.Outline)
+		elem.XMLURL = line
+		o.Body.Outline = append(o.Body.Outline, elem)
+		remote.Body.Outline = append(remote.Body.Outline, elem)
 	}
 	if err := scan.Err(); err != nil {
 		fmt.Fprintf(eout, "%s\n", err)
 		os.Exit(1)
 	}
-	src, err := xml.MarshalIndent(o, "", "    ")
-	if err != nil {
+	fetcher := fetch.New(fetch.Options{
+		Workers:   workers,
+		Timeout:   timeout,
+		UserAgent: fmt.Sprintf("%s/%s", appName, version),
+	})
+	if discover {
+		if workers <= 0 {
+			workers = fetch.DefaultWorkers
+		}
+		fetched := discoverFeeds(o, remote, fetcher, eout)
+		if enrich {
+			// Enrich from the feeds already fetched and only fetch
+			// the feeds found as links
+			rest := opml.New()
+			for _, elem := range remote.Body.Outline {
+				if f, ok := fetched[elem]; ok {
+					f.Enrich(elem, false)
+				} else {
+					rest.Body.Outline = append(rest.Body.Outline, elem)
+				}
+			}
+			remote = rest
+		}
+	}
+	if enrich {
+		for _, res := range fetcher.FetchAll(context.Background(), remote) {
+			if res.Err != nil {
+				fmt.Fprintf(eout, "%s, %s\n", res.URL, res.Err)
+				continue
+			}
+			f, err := feed.Parse(res.Body)
+			if err != nil {
+				fmt.Fprintf(eout, "%s, %s\n", res.URL, err)
+				continue
+			}
+			f.Enrich(res.Outline, false)
+		}
+	}
+	if err := opml.NewEncoderWith(out, opml.EncodeOptions{Indent: "    ", Declaration: true}).Encode(o); err != nil {
 		fmt.Fprintf(eout, "%s\n", err)
 		os.Exit(1)
 	}
-	fmt.Fprintln(out, `<?xml version="1.0" encoding="UTF-8"?>`)
-	fmt.Fprintf(out, "%s\n", src)
+	fmt.Fprintln(out)
 }
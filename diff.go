@@ -0,0 +1,412 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChangeKind is the kind of a Change found by Diff
+type ChangeKind string
+
+const (
+	// ChangeHead is a change to the head elements
+	ChangeHead ChangeKind = "head"
+	// ChangeRemove is an outline element only in the old document
+	ChangeRemove ChangeKind = "remove"
+	// ChangeAdd is an outline element only in the new document
+	ChangeAdd ChangeKind = "add"
+	// ChangeMove is an outline element with a different parent or
+	// order among its siblings, its attributes may also have changed
+	ChangeMove ChangeKind = "move"
+	// ChangeAttr is an outline element with changed attributes
+	ChangeAttr ChangeKind = "change"
+)
+
+// DefaultDiffKeys are the keys Diff matches outline elements by when
+// DiffOptions.Keys is empty
+var DefaultDiffKeys = []string{"xmlUrl", "url", "text"}
+
+// DiffOptions control how Diff matches outline elements
+type DiffOptions struct {
+	// Keys are tried in order to identify an outline element in both
+	// documents, the first key the element has a value for is used.
+	// A key is an attribute name (URL attributes are compared after
+	// NormalizeURL) or "path" for the element's path. Elements with
+	// none of the keys are identified by their path.
+	Keys []string
+}
+
+// AttrChange is an attribute whose value differs, Old or New is empty
+// if the attribute was added or removed
+type AttrChange struct {
+	Name string `json:"name"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// Change is a difference between two documents. Old and New are copies
+// of the outline element, without children, in the old and new
+// documents, OldPath and NewPath its paths. OldParent and Parent are
+// the keys of its parent in the old and new documents ("" for the
+// body) and After the key of the sibling before it in the new document
+// ("" if it is the first child), they are used by Patch.Apply.
+type Change struct {
+	Kind      ChangeKind   `json:"kind"`
+	Key       string       `json:"key,omitempty"`
+	OldPath   OutlinePath  `json:"old_path,omitempty"`
+	NewPath   OutlinePath  `json:"new_path,omitempty"`
+	OldParent string       `json:"old_parent,omitempty"`
+	Parent    string       `json:"parent,omitempty"`
+	After     string       `json:"after,omitempty"`
+	Old       *Outline     `json:"old,omitempty"`
+	New       *Outline     `json:"new,omitempty"`
+	Attrs     []AttrChange `json:"attrs,omitempty"`
+}
+
+// Changes is the result of Diff. It is written as JSON by json.Marshal,
+// as text by String and like a unified diff by Unified.
+type Changes []Change
+
+// diffNode is an outline element indexed by Diff
+type diffNode struct {
+	ol     *Outline
+	path   OutlinePath
+	key    string
+	parent string
+	// after is the key of the sibling before the element
+	after string
+	match *diffNode
+	moved bool
+}
+
+// diffIndex lists the outline elements of a document in pre-order
+type diffIndex struct {
+	nodes []*diffNode
+	byKey map[string]*diffNode
+	// children holds the keys of the children of each element, the
+	// key of the body is ""
+	children map[string][]string
+}
+
+// isURLAttr reports if the named attribute holds a URL
+func isURLAttr(name string) bool {
+	return name == "xmlUrl" || name == "htmlUrl" || name == "url"
+}
+
+// diffKey returns the key identifying ol in a document
+func diffKey(ol *Outline, p OutlinePath, keys []string) string {
+	for _, name := range keys {
+		if name == "path" {
+			break
+		}
+		if s, ok := ol.Attr(name); ok {
+			if isURLAttr(name) {
+				s = NormalizeURL(s)
+			}
+			return name + "=" + s
+		}
+	}
+	return "path=" + p.String()
+}
+
+func newDiffIndex(o *OPML, keys []string) *diffIndex {
+	idx := &diffIndex{byKey: map[string]*diffNode{}, children: map[string][]string{}}
+	keyOf := map[*Outline]string{}
+	seen := map[string]int{}
+	o.WalkWithPath(func(ol *Outline, p OutlinePath, depth int, parent *Outline) error {
+		key := diffKey(ol, p, keys)
+		seen[key]++
+		if n := seen[key]; n > 1 {
+			key = fmt.Sprintf("%s#%d", key, n)
+		}
+		keyOf[ol] = key
+		n := &diffNode{ol: ol, path: p, key: key}
+		if parent != nil {
+			n.parent = keyOf[parent]
+		}
+		if siblings := idx.children[n.parent]; len(siblings) > 0 {
+			n.after = siblings[len(siblings)-1]
+		}
+		idx.nodes = append(idx.nodes, n)
+		idx.byKey[key] = n
+		idx.children[n.parent] = append(idx.children[n.parent], key)
+		return nil
+	})
+	return idx
+}
+
+// attrChanges compares the attributes of a and b
+func attrChanges(a *Outline, b *Outline) []AttrChange {
+	changes := []AttrChange{}
+	old := map[string]string{}
+	names := []string{}
+	for _, attr := range a.attrs() {
+		name := attrKey(attr.Name)
+		old[name] = attr.Value
+		names = append(names, name)
+	}
+	for _, attr := range b.attrs() {
+		name := attrKey(attr.Name)
+		if s, ok := old[name]; ok {
+			if s != attr.Value {
+				changes = append(changes, AttrChange{Name: name, Old: s, New: attr.Value})
+			}
+			delete(old, name)
+			continue
+		}
+		changes = append(changes, AttrChange{Name: name, New: attr.Value})
+	}
+	for _, name := range names {
+		if s, ok := old[name]; ok {
+			changes = append(changes, AttrChange{Name: name, Old: s})
+		}
+	}
+	return changes
+}
+
+// headFields returns the names and values of the head elements Diff
+// compares
+func headFields(h *Head) [][2]string {
+	if h == nil {
+		h = new(Head)
+	}
+	return [][2]string{
+		{"title", h.Title},
+		{"dateCreated", h.Created},
+		{"dateModified", h.Modified},
+		{"ownerName", h.OwnerName},
+		{"ownerEmail", h.OwnerEmail},
+		{"ownerId", h.OwnerID},
+		{"docs", h.Docs},
+	}
+}
+
+// markMoved flags the children of a parent that changed order. The
+// longest run of children in their old order stays, preferring the
+// later children on a tie, the rest moved.
+func markMoved(nodes []*diffNode) {
+	if len(nodes) < 2 {
+		return
+	}
+	// Longest increasing subsequence of the old positions
+	pos := make([]int, len(nodes))
+	for i, n := range nodes {
+		pos[i] = n.match.path[len(n.match.path)-1]
+	}
+	length := make([]int, len(nodes))
+	prev := make([]int, len(nodes))
+	best := 0
+	for i := range nodes {
+		length[i], prev[i] = 1, -1
+		for j := 0; j < i; j++ {
+			if pos[j] < pos[i] && length[j]+1 > length[i] {
+				length[i], prev[i] = length[j]+1, j
+			}
+		}
+		if length[i] >= length[best] {
+			best = i
+		}
+	}
+	keep := map[int]bool{}
+	for i := best; i >= 0; i = prev[i] {
+		keep[i] = true
+	}
+	for i, n := range nodes {
+		if !keep[i] {
+			n.moved = true
+		}
+	}
+}
+
+// Diff compares the old document a with the new document b. Outline
+// elements are matched by the keys in opts. Changes are returned with
+// any head change first, then the removed elements in the order of a,
+// then the added, moved and changed elements in the order of b.
+func Diff(a *OPML, b *OPML, opts DiffOptions) Changes {
+	keys := opts.Keys
+	if len(keys) == 0 {
+		keys = DefaultDiffKeys
+	}
+	changes := Changes{}
+
+	head := []AttrChange{}
+	newHead := headFields(b.Head)
+	for i, field := range headFields(a.Head) {
+		if field[1] != newHead[i][1] {
+			head = append(head, AttrChange{Name: field[0], Old: field[1], New: newHead[i][1]})
+		}
+	}
+	if len(head) > 0 {
+		changes = append(changes, Change{Kind: ChangeHead, Attrs: head})
+	}
+
+	ia, ib := newDiffIndex(a, keys), newDiffIndex(b, keys)
+	for _, n := range ib.nodes {
+		if m, ok := ia.byKey[n.key]; ok {
+			n.match, m.match = m, n
+			n.moved = n.parent != m.parent
+		}
+	}
+	for _, n := range ia.nodes {
+		if n.match == nil {
+			changes = append(changes, Change{Kind: ChangeRemove, Key: n.key, OldPath: n.path, OldParent: n.parent, Old: copyOutline(n.ol)})
+		}
+	}
+	for _, list := range ib.children {
+		// Only children that stayed with the same parent can change order
+		nodes := []*diffNode{}
+		for _, key := range list {
+			if n := ib.byKey[key]; n.match != nil && !n.moved {
+				nodes = append(nodes, n)
+			}
+		}
+		markMoved(nodes)
+	}
+	for _, n := range ib.nodes {
+		if n.match == nil {
+			changes = append(changes, Change{Kind: ChangeAdd, Key: n.key, NewPath: n.path, Parent: n.parent, After: n.after, New: copyOutline(n.ol)})
+			continue
+		}
+		attrs := attrChanges(n.match.ol, n.ol)
+		if !n.moved && len(attrs) == 0 {
+			continue
+		}
+		kind := ChangeAttr
+		if n.moved {
+			kind = ChangeMove
+		}
+		c := Change{
+			Kind:      kind,
+			Key:       n.key,
+			OldPath:   n.match.path,
+			NewPath:   n.path,
+			OldParent: n.match.parent,
+			Parent:    n.parent,
+			After:     n.after,
+			Old:       copyOutline(n.match.ol),
+			New:       copyOutline(n.ol),
+		}
+		if len(attrs) > 0 {
+			c.Attrs = attrs
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// label returns the text of the changed outline element
+func (c Change) label() string {
+	ol := c.New
+	if ol == nil {
+		ol = c.Old
+	}
+	if ol == nil {
+		return ""
+	}
+	return fmt.Sprintf("%q", ol.Text)
+}
+
+// String returns a line describing the change
+func (c Change) String() string {
+	attrs := []string{}
+	for _, a := range c.Attrs {
+		attrs = append(attrs, fmt.Sprintf("%s %q -> %q", a.Name, a.Old, a.New))
+	}
+	s := ""
+	switch c.Kind {
+	case ChangeHead:
+		return "changed head: " + strings.Join(attrs, ", ")
+	case ChangeRemove:
+		return fmt.Sprintf("removed %s %s", c.OldPath, c.label())
+	case ChangeAdd:
+		return fmt.Sprintf("added %s %s", c.NewPath, c.label())
+	case ChangeMove:
+		s = fmt.Sprintf("moved %s -> %s %s", c.OldPath, c.NewPath, c.label())
+	default:
+		s = fmt.Sprintf("changed %s %s", c.NewPath, c.label())
+	}
+	if len(attrs) > 0 {
+		s += ": " + strings.Join(attrs, ", ")
+	}
+	return s
+}
+
+// String returns the changes as text, one line for each change
+func (c Changes) String() string {
+	var b strings.Builder
+	for _, change := range c {
+		b.WriteString(change.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// outlineTag returns ol as an empty outline element
+func outlineTag(ol *Outline) string {
+	var b strings.Builder
+	b.WriteString("<outline")
+	for _, attr := range ol.attrs() {
+		fmt.Fprintf(&b, " %s=\"%s\"", attrKey(attr.Name), escape(attr.Value, true))
+	}
+	b.WriteString("/>")
+	return b.String()
+}
+
+// Unified returns the changes in the style of a unified diff, oldName
+// and newName name the documents. Each change has a hunk header
+// holding its old and new paths followed by the outline element before
+// ("-") and after ("+") the change, the children are not shown.
+func (c Changes) Unified(oldName string, newName string) string {
+	var b strings.Builder
+	if len(c) == 0 {
+		return ""
+	}
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	for _, change := range c {
+		switch change.Kind {
+		case ChangeHead:
+			b.WriteString("@@ head @@\n")
+			for _, a := range change.Attrs {
+				if a.Old != "" {
+					fmt.Fprintf(&b, "-<%s>%s</%s>\n", a.Name, escape(a.Old, false), a.Name)
+				}
+				if a.New != "" {
+					fmt.Fprintf(&b, "+<%s>%s</%s>\n", a.Name, escape(a.New, false), a.Name)
+				}
+			}
+		case ChangeRemove:
+			fmt.Fprintf(&b, "@@ -%s @@\n-%s\n", change.OldPath, outlineTag(change.Old))
+		case ChangeAdd:
+			fmt.Fprintf(&b, "@@ +%s @@\n+%s\n", change.NewPath, outlineTag(change.New))
+		default:
+			fmt.Fprintf(&b, "@@ -%s +%s @@", change.OldPath, change.NewPath)
+			if change.Kind == ChangeMove {
+				b.WriteString(" moved")
+			}
+			b.WriteString("\n")
+			if len(change.Attrs) == 0 {
+				fmt.Fprintf(&b, " %s\n", outlineTag(change.New))
+			} else {
+				fmt.Fprintf(&b, "-%s\n+%s\n", outlineTag(change.Old), outlineTag(change.New))
+			}
+		}
+	}
+	return b.String()
+}
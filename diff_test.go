@@ -0,0 +1,117 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const (
+	diffOld = `<opml version="2.0"><head><title>Feeds</title></head><body>
+<outline text="News">
+  <outline text="Example" xmlUrl="http://example.org/feed" />
+  <outline text="Other" xmlUrl="http://other.example.org/rss" />
+  <outline text="Third" xmlUrl="http://third.example.org/rss" />
+</outline>
+<outline text="Gone" xmlUrl="http://gone.example.org/rss" />
+<outline text="Tech" />
+</body></opml>`
+
+	diffNew = `<opml version="2.0"><head><title>Team feeds</title></head><body>
+<outline text="News">
+  <outline text="Third" xmlUrl="http://third.example.org/rss" />
+  <outline text="Example Feed" xmlUrl="https://example.org/feed/" />
+</outline>
+<outline text="Tech">
+  <outline text="Other" xmlUrl="http://other.example.org/rss" />
+  <outline text="New" xmlUrl="http://new.example.org/rss" />
+</outline>
+</body></opml>`
+)
+
+func TestDiff(t *testing.T) {
+	a, err := Parse([]byte(diffOld))
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	b, err := Parse([]byte(diffNew))
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	changes := Diff(a, b, DiffOptions{})
+	expected := `changed head: title "Feeds" -> "Team feeds"
+removed /2 "Gone"
+moved /1/3 -> /1/1 "Third"
+changed /1/2 "Example Feed": text "Example" -> "Example Feed", xmlUrl "http://example.org/feed" -> "https://example.org/feed/"
+moved /1/2 -> /2/1 "Other"
+added /2/2 "New"
+`
+	if s := changes.String(); s != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, s)
+	}
+
+	expected = `--- a.opml
++++ b.opml
+@@ head @@
+-<title>Feeds</title>
++<title>Team feeds</title>
+@@ -/2 @@
+-<outline text="Gone" xmlUrl="http://gone.example.org/rss"/>
+@@ -/1/3 +/1/1 @@ moved
+ <outline text="Third" xmlUrl="http://third.example.org/rss"/>
+@@ -/1/1 +/1/2 @@
+-<outline text="Example" xmlUrl="http://example.org/feed"/>
++<outline text="Example Feed" xmlUrl="https://example.org/feed/"/>
+@@ -/1/2 +/2/1 @@ moved
+ <outline text="Other" xmlUrl="http://other.example.org/rss"/>
+@@ +/2/2 @@
++<outline text="New" xmlUrl="http://new.example.org/rss"/>
+`
+	if s := changes.Unified("a.opml", "b.opml"); s != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, s)
+	}
+
+	src, err := json.Marshal(changes)
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	decoded := Changes{}
+	if err := json.Unmarshal(src, &decoded); err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if decoded.String() != changes.String() {
+		t.Errorf("expected JSON round trip, got\n%s", decoded)
+	}
+
+	// Matching by path ignores moves
+	changes = Diff(a, b, DiffOptions{Keys: []string{"path"}})
+	for _, c := range changes {
+		if c.Kind == ChangeMove {
+			t.Errorf("unexpected move %s", c)
+		}
+	}
+
+	if changes := Diff(a, a, DiffOptions{}); len(changes) != 0 {
+		t.Errorf("expected no changes, got\n%s", changes)
+	}
+}
@@ -0,0 +1,473 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Declaration is the XML declaration written by an Encoder
+const Declaration = `<?xml version="1.0" encoding="UTF-8"?>`
+
+// xmlNamespace is the namespace bound to the "xml" prefix
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// outlineAttrs are the OPML outline attributes in the order they are
+// written
+var outlineAttrs = []string{
+	"text", "type", "title", "isComment", "isBreakpoint", "created",
+	"category", "xmlUrl", "htmlUrl", "language", "description",
+	"version", "url",
+}
+
+// attrs returns the outline's attributes, the OPML attributes that are
+// set followed by OtherAttr
+func (ol *Outline) attrs() []xml.Attr {
+	l := []xml.Attr{}
+	for _, name := range outlineAttrs {
+		if s, ok := ol.Attr(name); ok {
+			l = append(l, xml.Attr{Name: xml.Name{Local: name}, Value: s})
+		}
+	}
+	return append(l, ol.OtherAttr...)
+}
+
+// EncodeOptions control the output of an Encoder
+type EncodeOptions struct {
+	// Prefix and Indent indent each element like xml.MarshalIndent,
+	// the output isn't indented if both are empty
+	Prefix string
+	Indent string
+
+	// Declaration writes the XML declaration before the opml element
+	Declaration bool
+
+	// SelfClose writes elements without content as <outline .../>
+	SelfClose bool
+
+	// Canonical writes reproducible output suitable for keeping in
+	// version control. It implies Declaration and SelfClose, indents
+	// with two spaces if no Indent is set, sorts OtherAttr by name and
+	// ends the output with a newline.
+	Canonical bool
+}
+
+// Encoder writes an OPML document to an io.Writer. Attributes are
+// written in the order of the OPML spec followed by OtherAttr in source
+// order. A document can be written at once with Encode or one outline
+// element at a time.
+//
+//	enc := opml.NewEncoder(w)
+//	enc.Start(o)
+//	for ... {
+//	    enc.WriteOutline(ol, len(p))
+//	}
+//	enc.Close()
+type Encoder struct {
+	w    *bufio.Writer
+	opts EncodeOptions
+	err  error
+
+	started bool
+	closed  bool
+	// depth is the number of open outline elements
+	depth int
+	// level is the number of open elements
+	level int
+	// pending is the name of the last start tag if its ">" hasn't been
+	// written yet
+	pending string
+	// wrote is true once the first element has been written
+	wrote bool
+	// scopes holds the namespace prefixes declared by each open element
+	scopes  []map[string]string
+	nsCount int
+
+	// frames holds the nodes left to write in the body and each open
+	// outline element, opmlNodes and epilog the nodes left to write in
+	// and after the opml element
+	frames    []*nodeFrame
+	opmlNodes []Node
+	epilog    []Node
+}
+
+// nodeFrame is the nodes left to write in an open element
+type nodeFrame struct {
+	nodes []Node
+}
+
+// NewEncoder returns an Encoder writing to w with the default options
+func NewEncoder(w io.Writer) *Encoder {
+	return NewEncoderWith(w, EncodeOptions{})
+}
+
+// NewEncoderWith returns an Encoder writing to w using opts
+func NewEncoderWith(w io.Writer, opts EncodeOptions) *Encoder {
+	if opts.Canonical {
+		opts.Declaration = true
+		opts.SelfClose = true
+		if opts.Prefix == "" && opts.Indent == "" {
+			opts.Indent = "  "
+		}
+	}
+	return &Encoder{w: bufio.NewWriter(w), opts: opts}
+}
+
+// Indent sets the encoder to indent each element with prefix and indent
+// like xml.Encoder's Indent.
+func (enc *Encoder) Indent(prefix string, indent string) {
+	enc.opts.Prefix, enc.opts.Indent = prefix, indent
+}
+
+func (enc *Encoder) write(s string) {
+	if enc.err == nil {
+		_, enc.err = enc.w.WriteString(s)
+	}
+}
+
+func (enc *Encoder) newline() {
+	if enc.wrote && (enc.opts.Prefix != "" || enc.opts.Indent != "") {
+		enc.write("\n" + enc.opts.Prefix + strings.Repeat(enc.opts.Indent, enc.level))
+	}
+	enc.wrote = true
+}
+
+// closePending finishes an open start tag
+func (enc *Encoder) closePending() {
+	if enc.pending != "" {
+		enc.write(">")
+		enc.pending = ""
+	}
+}
+
+// prefix returns the prefix bound to the namespace space by an open
+// element.
+func (enc *Encoder) prefix(space string) (string, bool) {
+	if space == xmlNamespace {
+		return "xml", true
+	}
+	for i := len(enc.scopes) - 1; i >= 0; i-- {
+		if prefix, ok := enc.scopes[i][space]; ok {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+// start writes a start tag leaving it open for attributes
+func (enc *Encoder) start(name string, attrs []xml.Attr) {
+	enc.closePending()
+	enc.newline()
+	if enc.opts.Canonical {
+		attrs = canonicalAttrs(attrs)
+	}
+	scope := map[string]string{}
+	for _, attr := range attrs {
+		if attr.Name.Space == "xmlns" {
+			scope[attr.Value] = attr.Name.Local
+		}
+	}
+	enc.scopes = append(enc.scopes, scope)
+	enc.write("<" + name)
+	for _, attr := range attrs {
+		qname := attr.Name.Local
+		switch {
+		case attr.Name.Space == "":
+		case attr.Name.Space == "xmlns":
+			qname = "xmlns:" + attr.Name.Local
+		default:
+			prefix, ok := enc.prefix(attr.Name.Space)
+			if !ok {
+				if strings.ContainsAny(attr.Name.Space, ":/") {
+					// Declare a prefix for the namespace URL
+					enc.nsCount++
+					prefix = fmt.Sprintf("ns%d", enc.nsCount)
+					scope[attr.Name.Space] = prefix
+					enc.write(` xmlns:` + prefix + `="` + escape(attr.Name.Space, true) + `"`)
+				} else {
+					prefix = attr.Name.Space
+				}
+			}
+			qname = prefix + ":" + attr.Name.Local
+		}
+		enc.write(" " + qname + `="` + escape(attr.Value, true) + `"`)
+	}
+	enc.pending = name
+	enc.level++
+}
+
+// end writes an end tag
+func (enc *Encoder) end(name string) {
+	enc.level--
+	enc.scopes = enc.scopes[:len(enc.scopes)-1]
+	if enc.pending == name {
+		if enc.opts.SelfClose {
+			enc.write("/>")
+		} else {
+			enc.write("></" + name + ">")
+		}
+		enc.pending = ""
+		return
+	}
+	enc.closePending()
+	enc.newline()
+	enc.write("</" + name + ">")
+}
+
+// element writes an element holding only text
+func (enc *Encoder) element(name string, text string) {
+	enc.start(name, nil)
+	enc.closePending()
+	enc.write(escape(text, false))
+	enc.level--
+	enc.scopes = enc.scopes[:len(enc.scopes)-1]
+	enc.write("</" + name + ">")
+}
+
+// escape returns s with the XML special characters escaped like
+// encoding/xml, newlines are only escaped in attribute values.
+func escape(s string, attr bool) string {
+	buf := new(bytes.Buffer)
+	if attr {
+		xml.EscapeText(buf, []byte(s))
+		return buf.String()
+	}
+	for _, line := range strings.SplitAfter(s, "\n") {
+		text := strings.TrimSuffix(line, "\n")
+		xml.EscapeText(buf, []byte(text))
+		if len(text) < len(line) {
+			buf.WriteString("\n")
+		}
+	}
+	return buf.String()
+}
+
+// canonicalAttrs returns the OPML attributes in spec order followed by
+// the namespace declarations and the other attributes sorted by name.
+func canonicalAttrs(attrs []xml.Attr) []xml.Attr {
+	n := 0
+	for n < len(attrs) && attrs[n].Name.Space == "" && isSpecAttr(attrs[n].Name.Local) {
+		n++
+	}
+	other := append([]xml.Attr{}, attrs[n:]...)
+	sort.SliceStable(other, func(i, j int) bool {
+		a, b := other[i].Name, other[j].Name
+		if (a.Space == "xmlns") != (b.Space == "xmlns") {
+			return a.Space == "xmlns"
+		}
+		if a.Space != b.Space {
+			return a.Space < b.Space
+		}
+		return a.Local < b.Local
+	})
+	return append(attrs[0:n:n], other...)
+}
+
+func isSpecAttr(name string) bool {
+	if name == "version" {
+		return true
+	}
+	for _, s := range outlineAttrs {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Start writes the XML declaration (if set in the options), the opml
+// start tag, the head element and the body start tag. The outline
+// elements in o.Body are not written.
+func (enc *Encoder) Start(o *OPML) error {
+	if enc.started {
+		return fmt.Errorf("opml document already started")
+	}
+	enc.started = true
+	if enc.opts.Declaration {
+		enc.write(Declaration + "\n")
+	}
+	for _, n := range o.Prolog {
+		enc.write(n.XML + "\n")
+	}
+	version := o.Version
+	if version == "" {
+		version = "2.0"
+	}
+	enc.start("opml", append([]xml.Attr{{Name: xml.Name{Local: "version"}, Value: version}}, o.OtherAttr...))
+	enc.opmlNodes = enc.writeNodes(o.Extra, 0)
+	known := 0
+	if o.Head != nil {
+		h := o.Head
+		known++
+		enc.start("head", h.OtherAttr)
+		nodes := h.Extra
+		i := 0
+		for _, e := range []struct {
+			name string
+			text string
+		}{
+			{"title", h.Title},
+			{"dateCreated", h.Created},
+			{"dateModified", h.Modified},
+			{"ownerName", h.OwnerName},
+			{"ownerEmail", h.OwnerEmail},
+			{"ownerId", h.OwnerID},
+			{"docs", h.Docs},
+			{"expansionState", h.ExpansionState},
+		} {
+			if e.text != "" {
+				nodes = enc.writeNodes(nodes, i)
+				enc.element(e.name, e.text)
+				i++
+			}
+		}
+		for _, e := range []struct {
+			name string
+			val  int
+		}{
+			{"vertScrollState", h.VertScrollState},
+			{"windowTop", h.WindowTop},
+			{"windowLeft", h.WindowLeft},
+			{"windowBottom", h.WindowBottom},
+			{"windowRight", h.WindowRight},
+		} {
+			if e.val != 0 {
+				nodes = enc.writeNodes(nodes, i)
+				enc.element(e.name, strconv.Itoa(e.val))
+				i++
+			}
+		}
+		enc.writeNodes(nodes, math.MaxInt32)
+		enc.end("head")
+	}
+	enc.opmlNodes = enc.writeNodes(enc.opmlNodes, known)
+	var attrs []xml.Attr
+	frame := new(nodeFrame)
+	if o.Body != nil {
+		attrs = o.Body.OtherAttr
+		frame.nodes = o.Body.Extra
+	}
+	enc.start("body", attrs)
+	enc.frames = []*nodeFrame{frame}
+	enc.epilog = o.Epilog
+	return enc.err
+}
+
+// WriteOutline writes an outline element at depth, one for elements in
+// the body. Open elements at depth or deeper are closed first. The
+// element's children are written and the element is left open so the
+// following elements at depth + 1 are also its children.
+func (enc *Encoder) WriteOutline(ol *Outline, depth int) error {
+	if !enc.started || enc.closed {
+		return fmt.Errorf("opml document not started")
+	}
+	if depth < 1 || depth > enc.depth+1 {
+		return fmt.Errorf("can't write outline at depth %d, %d outline elements are open", depth, enc.depth)
+	}
+	for enc.depth >= depth {
+		enc.endOutline()
+	}
+	enc.writeNodes(ol.Before, math.MaxInt32)
+	enc.start("outline", ol.attrs())
+	enc.frames = append(enc.frames, &nodeFrame{nodes: ol.Extra})
+	enc.depth++
+	for _, elem := range ol.Outline {
+		if elem == nil {
+			continue
+		}
+		if err := enc.WriteOutline(elem, depth+1); err != nil {
+			return err
+		}
+	}
+	return enc.err
+}
+
+// flushFrame writes the nodes left in the innermost open element and
+// drops its frame
+func (enc *Encoder) flushFrame() {
+	f := enc.frames[len(enc.frames)-1]
+	enc.writeNodes(f.nodes, math.MaxInt32)
+	enc.frames = enc.frames[:len(enc.frames)-1]
+}
+
+func (enc *Encoder) endOutline() {
+	enc.flushFrame()
+	enc.end("outline")
+	enc.depth--
+}
+
+// Close closes the open outline elements, the body and opml elements
+// and flushes the output. It doesn't close the underlying io.Writer.
+func (enc *Encoder) Close() error {
+	if !enc.started || enc.closed {
+		return fmt.Errorf("opml document not started")
+	}
+	enc.closed = true
+	for enc.depth > 0 {
+		enc.endOutline()
+	}
+	enc.flushFrame()
+	enc.end("body")
+	enc.writeNodes(enc.opmlNodes, math.MaxInt32)
+	enc.end("opml")
+	for _, n := range enc.epilog {
+		enc.write("\n" + n.XML)
+	}
+	if enc.opts.Canonical {
+		enc.write("\n")
+	}
+	if enc.err != nil {
+		return enc.err
+	}
+	return enc.w.Flush()
+}
+
+// Encode writes the whole OPML document
+func (enc *Encoder) Encode(o *OPML) error {
+	if err := enc.Start(o); err != nil {
+		return err
+	}
+	if o.Body != nil {
+		for _, ol := range o.Body.Outline {
+			if ol == nil {
+				continue
+			}
+			if err := enc.WriteOutline(ol, 1); err != nil {
+				return err
+			}
+		}
+	}
+	return enc.Close()
+}
+
+// MarshalWith returns the OPML document encoded with opts
+func MarshalWith(o *OPML, opts EncodeOptions) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := NewEncoderWith(buf, opts).Encode(o); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,106 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncodeOptions(t *testing.T) {
+	src := []byte(`<opml version="2.0" xmlns:ex="http://example.org/ns">
+<head><title>Tom &amp; Jerry</title><OwnerId>http://example.org/tom</OwnerId></head>
+<body>
+<outline zeta="z" url="http://example.org/a.opml" alpha="a" text="A &lt;b&gt;" type="include" ex:note="n"/>
+<outline text="Folder"><outline text="Child" isComment="true"/></outline>
+</body>
+</opml>`)
+	o, err := Parse(src)
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if o.Head.OwnerID != "http://example.org/tom" {
+		t.Errorf("expected the legacy OwnerId to be read, got %q", o.Head.OwnerID)
+	}
+
+	// Spec order then OtherAttr in source order
+	b, err := MarshalWith(o, EncodeOptions{})
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	expected := `<outline text="A &lt;b&gt;" type="include" url="http://example.org/a.opml" zeta="z" alpha="a" ex:note="n"></outline>`
+	if !strings.Contains(string(b), expected) {
+		t.Errorf("expected %s in\n%s", expected, b)
+	}
+	if bytes.HasPrefix(b, []byte("<?xml")) {
+		t.Errorf("expected no declaration, got\n%s", b)
+	}
+
+	b, err = MarshalWith(o, EncodeOptions{Declaration: true, SelfClose: true})
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if !bytes.HasPrefix(b, []byte(Declaration+"\n<opml")) {
+		t.Errorf("expected a declaration, got\n%s", b)
+	}
+	if !bytes.Contains(b, []byte(`<outline text="Child" isComment="true"/>`)) {
+		t.Errorf("expected self closed elements, got\n%s", b)
+	}
+
+	canonical := Declaration + `
+<opml version="2.0" xmlns:ex="http://example.org/ns">
+  <head>
+    <title>Tom &amp; Jerry</title>
+    <ownerId>http://example.org/tom</ownerId>
+  </head>
+  <body>
+    <outline text="A &lt;b&gt;" type="include" url="http://example.org/a.opml" alpha="a" zeta="z" ex:note="n"/>
+    <outline text="Folder">
+      <outline text="Child" isComment="true"/>
+    </outline>
+  </body>
+</opml>
+`
+	b, err = MarshalWith(o, EncodeOptions{Canonical: true})
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if string(b) != canonical {
+		t.Errorf("expected\n%s\ngot\n%s", canonical, b)
+	}
+
+	// Canonical output is stable through a round trip
+	o2, err := Parse(b)
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	b2, err := MarshalWith(o2, EncodeOptions{Canonical: true})
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if !bytes.Equal(b, b2) {
+		t.Errorf("expected\n%s\ngot\n%s", b, b2)
+	}
+}
@@ -0,0 +1,138 @@
+/*
+feed is a Go package for reading RSS, Atom and JSON Feed documents.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package feed
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// atomText is an Atom text construct, the type is "text", "html" or
+// "xhtml"
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+// String returns the text, markup is kept for html and xhtml
+func (t atomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+// Plain returns the text without markup
+func (t atomText) Plain() string {
+	if t.Type == "html" || t.Type == "xhtml" {
+		return PlainText(t.String())
+	}
+	return t.String()
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// atomLinks returns the alternate link, preferring HTML, and the self
+// link
+func atomLinks(links []atomLink) (string, string) {
+	alternate, self := "", ""
+	for _, l := range links {
+		switch l.Rel {
+		case "", "alternate":
+			if alternate == "" || strings.Contains(l.Type, "html") {
+				alternate = strings.TrimSpace(l.Href)
+			}
+		case "self":
+			self = strings.TrimSpace(l.Href)
+		}
+	}
+	return alternate, self
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID        string       `xml:"id"`
+	Title     atomText     `xml:"title"`
+	Links     []atomLink   `xml:"link"`
+	Summary   atomText     `xml:"summary"`
+	Content   atomText     `xml:"content"`
+	Published string       `xml:"published"`
+	Updated   string       `xml:"updated"`
+	Authors   []atomPerson `xml:"author"`
+}
+
+// parseAtom reads an Atom document from its feed element
+func parseAtom(d *xml.Decoder, start xml.StartElement) (*Feed, error) {
+	doc := struct {
+		Lang     string       `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+		ID       string       `xml:"id"`
+		Title    atomText     `xml:"title"`
+		Subtitle atomText     `xml:"subtitle"`
+		Links    []atomLink   `xml:"link"`
+		Updated  string       `xml:"updated"`
+		Authors  []atomPerson `xml:"author"`
+		Entries  []atomEntry  `xml:"entry"`
+	}{}
+	if err := d.DecodeElement(&doc, &start); err != nil {
+		return nil, err
+	}
+	link, self := atomLinks(doc.Links)
+	f := &Feed{
+		Format:      Atom,
+		Version:     "1.0",
+		Title:       doc.Title.Plain(),
+		Description: doc.Subtitle.Plain(),
+		Language:    strings.TrimSpace(doc.Lang),
+		Link:        link,
+		FeedURL:     self,
+		Updated:     parseTime(doc.Updated),
+	}
+	if start.Name.Space != atomNS {
+		// Atom 0.3 used its own namespace and "tagline"
+		f.Version = "0.3"
+	}
+	for _, e := range doc.Entries {
+		link, _ := atomLinks(e.Links)
+		item := &Item{
+			ID:          strings.TrimSpace(e.ID),
+			Title:       e.Title.Plain(),
+			Link:        link,
+			Description: e.Summary.String(),
+			Content:     e.Content.String(),
+			Published:   parseTime(e.Published),
+			Updated:     parseTime(e.Updated),
+		}
+		authors := e.Authors
+		if len(authors) == 0 {
+			authors = doc.Authors
+		}
+		if len(authors) > 0 {
+			item.Author = strings.TrimSpace(authors[0].Name)
+		}
+		f.Items = append(f.Items, item)
+	}
+	return f, nil
+}
@@ -0,0 +1,266 @@
+/*
+feed is a Go package for reading RSS, Atom and JSON Feed documents.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package feed
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	// My Packages
+	"github.com/rsdoiel/opml/fetch"
+)
+
+// Fallbacks are the paths Discover tries, in order, on a page's site
+// when the page doesn't link to a feed
+var Fallbacks = []string{"/feed", "/rss.xml", "/atom.xml", "/feed.xml", "/index.xml", "/rss"}
+
+// feedTypes are the media types of feed links
+var feedTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/rdf+xml":   true,
+	"application/feed+json": true,
+	"application/json":      true,
+	"text/xml":              true,
+	"application/xml":       true,
+}
+
+// Link is a feed found by Discover
+type Link struct {
+	URL   string `json:"url"`
+	Type  string `json:"type,omitempty"`
+	Title string `json:"title,omitempty"`
+}
+
+// Discovery is the result of Discover
+type Discovery struct {
+	// Page is the URL given to Discover and PageURL the page's URL
+	// after redirects
+	Page    string `json:"page"`
+	PageURL string `json:"page_url"`
+
+	// IsFeed is set if the page is itself a feed. Feed holds the page
+	// or the fallback that is a feed, it is nil when the feeds were
+	// found as links in the page as they aren't fetched.
+	IsFeed bool  `json:"is_feed,omitempty"`
+	Feed   *Feed `json:"-"`
+
+	// Feeds are the feeds linked from the page in page order, or the
+	// first fallback that is a feed
+	Feeds []Link `json:"feeds"`
+
+	// Fallback is set if the feed was found at one of the Fallbacks
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+// tagAttrs parses the attributes of the tag starting at src[0], after
+// its name, returning them and the rest of src after the tag
+func tagAttrs(src []byte) (map[string]string, []byte) {
+	attrs := map[string]string{}
+	i := 0
+	for i < len(src) {
+		// Skip space and a self closing slash
+		for i < len(src) && (src[i] == ' ' || src[i] == '\t' || src[i] == '\n' || src[i] == '\r' || src[i] == '/') {
+			i++
+		}
+		if i >= len(src) {
+			break
+		}
+		if src[i] == '>' {
+			return attrs, src[i+1:]
+		}
+		start := i
+		for i < len(src) && !strings.ContainsRune(" \t\n\r=/>", rune(src[i])) {
+			i++
+		}
+		name := strings.ToLower(string(src[start:i]))
+		value := ""
+		if i < len(src) && src[i] == '=' {
+			i++
+			if i < len(src) && (src[i] == '"' || src[i] == '\'') {
+				q := src[i]
+				i++
+				start = i
+				for i < len(src) && src[i] != q {
+					i++
+				}
+				value = string(src[start:i])
+				if i < len(src) {
+					i++
+				}
+			} else {
+				start = i
+				for i < len(src) && !strings.ContainsRune(" \t\n\r>", rune(src[i])) {
+					i++
+				}
+				value = string(src[start:i])
+			}
+		}
+		if name != "" {
+			attrs[name] = html.UnescapeString(value)
+		}
+	}
+	return attrs, nil
+}
+
+// hasToken reports if the space separated list s holds token, ignoring
+// case
+func hasToken(s string, token string) bool {
+	for _, t := range strings.Fields(s) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// asciiLower returns a copy of src with the ASCII letters lower cased.
+// Unlike bytes.ToLower it never changes the length, so offsets found in
+// the copy can be used in src whatever the page's encoding.
+func asciiLower(src []byte) []byte {
+	lower := make([]byte, len(src))
+	for i, c := range src {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		lower[i] = c
+	}
+	return lower
+}
+
+// FindLinks returns the feeds an HTML page links to with
+// <link rel="alternate"> (or rel="feed") elements. Relative URLs are
+// resolved against the page's <base href> or, if it has none, base.
+func FindLinks(src []byte, base *url.URL) []Link {
+	links := []Link{}
+	seen := map[string]bool{}
+	lower := asciiLower(src)
+	for i := 0; i < len(lower); {
+		j := bytes.IndexByte(lower[i:], '<')
+		if j < 0 {
+			break
+		}
+		i += j + 1
+		rest := lower[i:]
+		switch {
+		case bytes.HasPrefix(rest, []byte("!--")):
+			// Skip comments
+			if k := bytes.Index(rest, []byte("-->")); k >= 0 {
+				i += k + 3
+			} else {
+				i = len(lower)
+			}
+			continue
+		case bytes.HasPrefix(rest, []byte("script")), bytes.HasPrefix(rest, []byte("style")):
+			// Skip the content of scripts and styles
+			name := "</script"
+			if rest[1] == 't' {
+				name = "</style"
+			}
+			if k := bytes.Index(rest, []byte(name)); k >= 0 {
+				i += k
+			} else {
+				i = len(lower)
+			}
+			continue
+		case bytes.HasPrefix(rest, []byte("base")), bytes.HasPrefix(rest, []byte("link")):
+		default:
+			continue
+		}
+		isBase := rest[0] == 'b'
+		if len(rest) <= 4 || !strings.ContainsRune(" \t\n\r/>", rune(rest[4])) {
+			continue
+		}
+		// Attribute values keep their case
+		attrs, _ := tagAttrs(src[i+4:])
+		if isBase {
+			if u, err := url.Parse(strings.TrimSpace(attrs["href"])); err == nil && attrs["href"] != "" {
+				if base != nil {
+					u = base.ResolveReference(u)
+				}
+				base = u
+			}
+			continue
+		}
+		rel, typ := attrs["rel"], strings.ToLower(strings.TrimSpace(attrs["type"]))
+		if k := strings.Index(typ, ";"); k >= 0 {
+			typ = strings.TrimSpace(typ[:k])
+		}
+		isFeed := (hasToken(rel, "alternate") && feedTypes[typ]) || hasToken(rel, "feed")
+		href := strings.TrimSpace(attrs["href"])
+		if !isFeed || href == "" {
+			continue
+		}
+		u, err := url.Parse(href)
+		if err != nil {
+			continue
+		}
+		if base != nil {
+			u = base.ResolveReference(u)
+		}
+		if seen[u.String()] {
+			continue
+		}
+		seen[u.String()] = true
+		links = append(links, Link{URL: u.String(), Type: typ, Title: strings.TrimSpace(attrs["title"])})
+	}
+	return links
+}
+
+// Discover finds the feeds of the web page at page using f. If the
+// page is a feed it is returned with IsFeed set. Otherwise the feeds
+// the page links to are returned and if there are none the Fallbacks
+// are tried on the page's site. A Discovery with no Feeds is returned
+// if none are found, an error is returned if the page can't be fetched.
+func Discover(ctx context.Context, f *fetch.Fetcher, page string) (*Discovery, error) {
+	res := f.Fetch(ctx, page)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	d := &Discovery{Page: page, PageURL: res.FinalURL, Feeds: []Link{}}
+	if feed, err := Parse(res.Body); err == nil {
+		d.IsFeed, d.Feed = true, feed
+		d.Feeds = append(d.Feeds, Link{URL: res.FinalURL, Type: res.ContentType, Title: feed.Title})
+		return d, nil
+	}
+	base, err := url.Parse(res.FinalURL)
+	if err != nil {
+		return nil, fmt.Errorf("bad page URL %q, %s", res.FinalURL, err)
+	}
+	d.Feeds = FindLinks(res.Body, base)
+	if len(d.Feeds) > 0 {
+		return d, nil
+	}
+	for _, p := range Fallbacks {
+		ref := base.ResolveReference(&url.URL{Path: p}).String()
+		res := f.Fetch(ctx, ref)
+		if res.Err != nil {
+			continue
+		}
+		if feed, err := Parse(res.Body); err == nil {
+			d.Feeds = append(d.Feeds, Link{URL: res.FinalURL, Type: res.ContentType, Title: feed.Title})
+			d.Fallback, d.Feed = true, feed
+			break
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,113 @@
+/*
+feed is a Go package for reading RSS, Atom and JSON Feed documents.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package feed
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	// My Packages
+	"github.com/rsdoiel/opml/fetch"
+)
+
+func TestFindLinks(t *testing.T) {
+	src := []byte(`<!DOCTYPE html>
+<html><head>
+<BASE href="https://example.org/blog/">
+<!-- <link rel="alternate" type="application/rss+xml" href="/commented.xml"> -->
+<script>var s = '<link rel="alternate" type="application/rss+xml" href="/script.xml">';</script>
+<link rel="stylesheet" href="/style.css">
+<link rel=alternate type="application/rss+xml; charset=utf-8" title="Posts &amp; notes" href=rss.xml>
+<link href="https://example.org/atom.xml" type="application/atom+xml" rel="alternate home" />
+<link rel="alternate" type="text/html" hreflang="fr" href="/fr/">
+<link rel="alternate" type="application/rss+xml" href="rss.xml">
+</head><body></body></html>`)
+	base, _ := url.Parse("https://example.org/")
+	links := FindLinks(src, base)
+	expected := []Link{
+		{URL: "https://example.org/blog/rss.xml", Type: "application/rss+xml", Title: "Posts & notes"},
+		{URL: "https://example.org/atom.xml", Type: "application/atom+xml"},
+	}
+	if fmt.Sprintf("%+v", links) != fmt.Sprintf("%+v", expected) {
+		t.Errorf("expected %+v, got %+v", expected, links)
+	}
+
+	// A Latin-1 page isn't valid UTF-8
+	src = []byte("<html><head><title>Caf\xe9 \xe9\xe9\xe9</title><LINK REL=alternate TYPE=application/rss+xml HREF=/Feed.xml></head></html>")
+	links = FindLinks(src, base)
+	if len(links) != 1 || links[0].URL != "https://example.org/Feed.xml" {
+		t.Errorf("expected the feed of a Latin-1 page, got %+v", links)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	rss, err := ioutil.ReadFile("testdata/rss2.xml")
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `<html><head><link rel="alternate" type="application/rss+xml" href="/posts.rss"></head></html>`)
+	})
+	mux.HandleFunc("/posts.rss", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(rss)
+	})
+	mux.HandleFunc("/plain/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><title>No links</title></head></html>`)
+	})
+	mux.HandleFunc("/rss.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(rss)
+	})
+	mux.HandleFunc("/many/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<link rel="alternate" type="application/rss+xml" href="a.xml"><link rel="alternate" type="application/atom+xml" href="b.xml">`)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	f := fetch.New(fetch.Options{})
+	ctx := context.Background()
+	d, err := Discover(ctx, f, ts.URL+"/")
+	if err != nil || len(d.Feeds) != 1 || d.Feeds[0].URL != ts.URL+"/posts.rss" || d.IsFeed || d.Fallback || d.Feed != nil {
+		t.Errorf("expected a linked feed, got %+v, %v", d, err)
+	}
+	d, err = Discover(ctx, f, ts.URL+"/posts.rss")
+	if err != nil || !d.IsFeed || d.Feed.Title != "Example News" {
+		t.Errorf("expected the page to be a feed, got %+v, %v", d, err)
+	}
+	d, err = Discover(ctx, f, ts.URL+"/plain/")
+	if err != nil || len(d.Feeds) != 1 || !d.Fallback || d.Feeds[0].URL != ts.URL+"/rss.xml" || d.Feed == nil {
+		t.Errorf("expected a fallback feed, got %+v, %v", d, err)
+	}
+	d, err = Discover(ctx, f, ts.URL+"/many/")
+	if err != nil || len(d.Feeds) != 2 || d.Feeds[1].URL != ts.URL+"/many/b.xml" {
+		t.Errorf("expected two feeds, got %+v, %v", d, err)
+	}
+	if _, err = Discover(ctx, f, ts.URL+"/missing/x"); err == nil {
+		t.Errorf("expected an error for a missing page")
+	}
+}
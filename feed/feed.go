@@ -0,0 +1,231 @@
+/*
+feed is a Go package for reading RSS, Atom and JSON Feed documents.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package feed reads RSS 0.9x and 2.0, RSS 1.0 (RDF), Atom and JSON
+// Feed documents into a common Feed type that can fill in the
+// attributes of an OPML outline element.
+//
+//	f, err := feed.Parse(src)
+//	if err != nil {
+//	    ...
+//	}
+//	f.Enrich(ol, false)
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	// My Packages
+	"github.com/rsdoiel/opml"
+
+	// Other packages
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// Formats of the documents Parse reads
+const (
+	RSS      = "rss"
+	RDF      = "rdf"
+	Atom     = "atom"
+	JSONFeed = "json"
+)
+
+// ErrUnknownFormat is returned by Parse for a document that isn't a
+// feed it knows
+var ErrUnknownFormat = errors.New("not an RSS, Atom or JSON feed")
+
+// Feed holds the metadata and items of a feed
+type Feed struct {
+	// Format is RSS, RDF, Atom or JSONFeed and Version the format's
+	// version, e.g. "2.0" for RSS 2.0
+	Format  string `json:"format"`
+	Version string `json:"version,omitempty"`
+
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Language    string `json:"language,omitempty"`
+
+	// Link is the web page of the feed and FeedURL the feed's own URL
+	// if it gives one
+	Link    string `json:"link,omitempty"`
+	FeedURL string `json:"feed_url,omitempty"`
+
+	// Updated is when the feed last changed, it is zero if not known
+	Updated time.Time `json:"updated,omitempty"`
+
+	Items []*Item `json:"items,omitempty"`
+}
+
+// Item is an entry in a feed
+type Item struct {
+	ID          string `json:"id,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Link        string `json:"link,omitempty"`
+	Description string `json:"description,omitempty"`
+	Content     string `json:"content,omitempty"`
+	Author      string `json:"author,omitempty"`
+
+	// Published and Updated are zero if not known
+	Published time.Time `json:"published,omitempty"`
+	Updated   time.Time `json:"updated,omitempty"`
+}
+
+// Date returns when the item was published, or updated if the
+// published date isn't known
+func (item *Item) Date() time.Time {
+	if item.Published.IsZero() {
+		return item.Updated
+	}
+	return item.Published
+}
+
+// parseTime parses a feed date, it returns the zero time if s isn't a
+// date
+func parseTime(s string) time.Time {
+	if t, err := opml.ParseDate(s); err == nil {
+		return t
+	}
+	if t, err := time.Parse(time.RFC3339, strings.TrimSpace(s)); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// PlainText returns s with HTML tags removed and entities unescaped,
+// space is collapsed
+func PlainText(s string) string {
+	var b strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+			b.WriteRune(' ')
+		case !inTag:
+			b.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(b.String())), " ")
+}
+
+// charsetReader lets the XML decoder read documents in the encodings
+// used on the web, e.g. ISO-8859-1 and Windows-1252
+func charsetReader(label string, r io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q", label)
+	}
+	return enc.NewDecoder().Reader(r), nil
+}
+
+// newDecoder returns an XML decoder for a feed
+func newDecoder(src []byte) *xml.Decoder {
+	d := xml.NewDecoder(bytes.NewReader(src))
+	d.CharsetReader = charsetReader
+	// Feeds in the wild use HTML entities like &nbsp; and unescaped
+	// ampersands
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+	return d
+}
+
+// Parse reads a feed, the format is detected from its content
+func Parse(src []byte) (*Feed, error) {
+	src = bytes.TrimPrefix(src, []byte("\xef\xbb\xbf"))
+	if trimmed := bytes.TrimSpace(src); len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseJSONFeed(trimmed)
+	}
+	d := newDecoder(src)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil, ErrUnknownFormat
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(start.Name.Local) {
+		case "rss":
+			return parseRSS(d, start)
+		case "rdf":
+			return parseRDF(d, start)
+		case "feed":
+			return parseAtom(d, start)
+		}
+		return nil, ErrUnknownFormat
+	}
+}
+
+// opmlVersion returns the version attribute the OPML spec gives the
+// feed's format, Atom and JSON Feed have none
+func (f *Feed) opmlVersion() string {
+	switch f.Format {
+	case RSS:
+		if strings.HasPrefix(f.Version, "2") {
+			return "RSS2"
+		}
+		return "RSS"
+	case RDF:
+		return "RSS1"
+	}
+	return ""
+}
+
+// Outline returns a new outline element for the feed, xmlURL is the
+// URL it was read from. If xmlURL is empty the feed's own URL is used.
+func (f *Feed) Outline(xmlURL string) *opml.Outline {
+	ol := &opml.Outline{XMLURL: xmlURL}
+	f.Enrich(ol, false)
+	return ol
+}
+
+// Enrich fills in the text, title, type, version, htmlUrl,
+// description, language and, if it isn't set, xmlUrl attributes of ol
+// from the feed. Attributes that are set are only replaced if overwrite
+// is true.
+func (f *Feed) Enrich(ol *opml.Outline, overwrite bool) {
+	set := func(attr *string, value string) {
+		if value != "" && (*attr == "" || overwrite) {
+			*attr = value
+		}
+	}
+	title := PlainText(f.Title)
+	set(&ol.Text, title)
+	set(&ol.Title, title)
+	set(&ol.Type, "rss")
+	set(&ol.Version, f.opmlVersion())
+	set(&ol.HTMLURL, f.Link)
+	set(&ol.Description, PlainText(f.Description))
+	set(&ol.Language, f.Language)
+	if ol.XMLURL == "" {
+		ol.XMLURL = f.FeedURL
+	}
+}
@@ -0,0 +1,153 @@
+/*
+feed is a Go package for reading RSS, Atom and JSON Feed documents.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package feed
+
+import (
+	"io/ioutil"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	// My Packages
+	"github.com/rsdoiel/opml"
+)
+
+func TestParse(t *testing.T) {
+	expected := map[string]Feed{
+		"rss2.xml": {
+			Format:      RSS,
+			Version:     "2.0",
+			Title:       "Example News",
+			Description: "News & notes from Example",
+			Language:    "en-us",
+			Link:        "https://example.org/",
+			FeedURL:     "https://example.org/feed.xml",
+		},
+		"rss091.xml": {
+			Format:      RSS,
+			Version:     "0.91",
+			Title:       "Café Notes",
+			Description: "Notes from the café",
+			Language:    "fr",
+			Link:        "http://cafe.example.org/",
+		},
+		"rdf.xml": {
+			Format:      RDF,
+			Version:     "1.0",
+			Title:       "RDF Site",
+			Description: "An RSS 1.0 feed",
+			Language:    "en",
+			Link:        "http://rdf.example.org/",
+		},
+		"atom.xml": {
+			Format:      Atom,
+			Version:     "1.0",
+			Title:       "Atom Example",
+			Description: "An Atom feed",
+			Language:    "en-GB",
+			Link:        "https://atom.example.org/",
+			FeedURL:     "https://atom.example.org/feed.atom",
+		},
+		"feed.json": {
+			Format:      JSONFeed,
+			Version:     "1.1",
+			Title:       "JSON Example",
+			Description: "A JSON feed",
+			Language:    "de",
+			Link:        "https://json.example.org/",
+			FeedURL:     "https://json.example.org/feed.json",
+		},
+	}
+	for fname, want := range expected {
+		src, err := ioutil.ReadFile(path.Join("testdata", fname))
+		if err != nil {
+			t.Errorf("%s", err)
+			continue
+		}
+		f, err := Parse(src)
+		if err != nil {
+			t.Errorf("%s: %s", fname, err)
+			continue
+		}
+		got := *f
+		got.Items, got.Updated = nil, time.Time{}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected\n%+v\ngot\n%+v", fname, want, got)
+		}
+		if len(f.Items) == 0 {
+			t.Errorf("%s: expected items", fname)
+		}
+	}
+
+	src, _ := ioutil.ReadFile("testdata/rss2.xml")
+	f, _ := Parse(src)
+	item := f.Items[0]
+	if item.Title != "Second post" || item.Link != "https://example.org/2" || item.Content != "<p>The <em>second</em> post</p>" || item.Author != "editor@example.org" {
+		t.Errorf("unexpected item %+v", item)
+	}
+	if s := item.Date().UTC().Format(time.RFC3339); s != "2006-01-03T16:00:00Z" {
+		t.Errorf("unexpected date %s", s)
+	}
+	if f.Items[1].ID != "https://example.org/1" {
+		t.Errorf("expected the link as id, got %q", f.Items[1].ID)
+	}
+
+	src, _ = ioutil.ReadFile("testdata/feed.json")
+	f, _ = Parse(src)
+	if item := f.Items[0]; item.ID != "2" || item.Author != "Grete" || item.Content != "Der zweite" {
+		t.Errorf("unexpected item %+v", item)
+	}
+
+	if _, err := Parse([]byte("<html><body>Not a feed</body></html>")); err != ErrUnknownFormat {
+		t.Errorf("expected ErrUnknownFormat, got %v", err)
+	}
+}
+
+func TestEnrich(t *testing.T) {
+	src, _ := ioutil.ReadFile("testdata/rss2.xml")
+	f, err := Parse(src)
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	ol := f.Outline("")
+	expected := opml.Outline{
+		Text:        "Example News",
+		Title:       "Example News",
+		Type:        "rss",
+		Version:     "RSS2",
+		XMLURL:      "https://example.org/feed.xml",
+		HTMLURL:     "https://example.org/",
+		Description: "News & notes from Example",
+		Language:    "en-us",
+	}
+	if ol.String() != expected.String() {
+		t.Errorf("expected\n%s\ngot\n%s", expected.String(), ol.String())
+	}
+
+	ol = &opml.Outline{Text: "My name", XMLURL: "http://example.org/rss"}
+	f.Enrich(ol, false)
+	if ol.Text != "My name" || ol.Title != "Example News" || ol.XMLURL != "http://example.org/rss" {
+		t.Errorf("expected set attributes to be kept, got %s", ol)
+	}
+	f.Enrich(ol, true)
+	if ol.Text != "Example News" || ol.XMLURL != "http://example.org/rss" {
+		t.Errorf("expected attributes to be replaced except xmlUrl, got %s", ol)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+feed is a Go package for reading RSS, Atom and JSON Feed documents.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package feed
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+// parseJSONFeed reads a JSON Feed 1.0 or 1.1 document
+func parseJSONFeed(src []byte) (*Feed, error) {
+	doc := struct {
+		Version     string `json:"version"`
+		Title       string `json:"title"`
+		HomePageURL string `json:"home_page_url"`
+		FeedURL     string `json:"feed_url"`
+		Description string `json:"description"`
+		Language    string `json:"language"`
+		Items       []struct {
+			ID            json.RawMessage `json:"id"`
+			URL           string          `json:"url"`
+			Title         string          `json:"title"`
+			ContentHTML   string          `json:"content_html"`
+			ContentText   string          `json:"content_text"`
+			Summary       string          `json:"summary"`
+			DatePublished string          `json:"date_published"`
+			DateModified  string          `json:"date_modified"`
+			Author        *jsonAuthor     `json:"author"`
+			Authors       []jsonAuthor    `json:"authors"`
+		} `json:"items"`
+	}{}
+	if err := json.Unmarshal(src, &doc); err != nil {
+		return nil, err
+	}
+	if !strings.Contains(doc.Version, "jsonfeed.org") {
+		return nil, ErrUnknownFormat
+	}
+	f := &Feed{
+		Format:      JSONFeed,
+		Version:     strings.TrimPrefix(strings.TrimSuffix(doc.Version, "/"), "https://jsonfeed.org/version/"),
+		Title:       doc.Title,
+		Description: doc.Description,
+		Language:    doc.Language,
+		Link:        doc.HomePageURL,
+		FeedURL:     doc.FeedURL,
+	}
+	for _, e := range doc.Items {
+		item := &Item{
+			Title:       e.Title,
+			Link:        e.URL,
+			Description: e.Summary,
+			Content:     e.ContentHTML,
+			Published:   parseTime(e.DatePublished),
+			Updated:     parseTime(e.DateModified),
+		}
+		// The id is a string but some feeds use numbers
+		var id interface{}
+		if json.Unmarshal(e.ID, &id) == nil {
+			switch v := id.(type) {
+			case string:
+				item.ID = v
+			case float64:
+				item.ID = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		}
+		if item.Content == "" {
+			item.Content = e.ContentText
+		}
+		switch {
+		case len(e.Authors) > 0:
+			item.Author = e.Authors[0].Name
+		case e.Author != nil:
+			item.Author = e.Author.Name
+		}
+		f.Items = append(f.Items, item)
+	}
+	return f, nil
+}
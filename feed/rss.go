@@ -0,0 +1,156 @@
+/*
+feed is a Go package for reading RSS, Atom and JSON Feed documents.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package feed
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// atomNS is the Atom namespace, RSS feeds use atom:link for their
+// own URL
+const atomNS = "http://www.w3.org/2005/Atom"
+
+// rssLink is an RSS link element or an atom:link in an RSS channel
+type rssLink struct {
+	XMLName xml.Name
+	Href    string `xml:"href,attr"`
+	Rel     string `xml:"rel,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// rssLinks returns the link of an RSS element and the href of its
+// atom:link with rel="self"
+func rssLinks(links []rssLink) (string, string) {
+	link, self := "", ""
+	for _, l := range links {
+		switch {
+		case l.XMLName.Space == atomNS && l.Rel == "self":
+			self = strings.TrimSpace(l.Href)
+		case l.XMLName.Space != atomNS && link == "":
+			link = strings.TrimSpace(l.Text)
+		}
+	}
+	return link, self
+}
+
+type rssItem struct {
+	Title          string    `xml:"title"`
+	Links          []rssLink `xml:"link"`
+	Description    string    `xml:"description"`
+	ContentEncoded string    `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	GUID           string    `xml:"guid"`
+	PubDate        string    `xml:"pubDate"`
+	Date           string    `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Author         string    `xml:"author"`
+	Creator        string    `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	About          string    `xml:"about,attr"`
+}
+
+func (ri *rssItem) item() *Item {
+	link, _ := rssLinks(ri.Links)
+	item := &Item{
+		ID:          strings.TrimSpace(ri.GUID),
+		Title:       strings.TrimSpace(ri.Title),
+		Link:        link,
+		Description: strings.TrimSpace(ri.Description),
+		Content:     strings.TrimSpace(ri.ContentEncoded),
+		Author:      strings.TrimSpace(ri.Author),
+	}
+	if item.ID == "" {
+		item.ID = ri.About
+	}
+	if item.ID == "" {
+		item.ID = item.Link
+	}
+	if item.Author == "" {
+		item.Author = strings.TrimSpace(ri.Creator)
+	}
+	date := ri.PubDate
+	if date == "" {
+		date = ri.Date
+	}
+	item.Published = parseTime(date)
+	return item
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Links         []rssLink `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	PubDate       string    `xml:"pubDate"`
+	Date          string    `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Items         []rssItem `xml:"item"`
+}
+
+// feed returns the channel's metadata as a Feed
+func (c *rssChannel) feed(format string, version string) *Feed {
+	link, self := rssLinks(c.Links)
+	f := &Feed{
+		Format:      format,
+		Version:     version,
+		Title:       strings.TrimSpace(c.Title),
+		Description: strings.TrimSpace(c.Description),
+		Language:    strings.TrimSpace(c.Language),
+		Link:        link,
+		FeedURL:     self,
+	}
+	for _, s := range []string{c.LastBuildDate, c.PubDate, c.Date} {
+		if t := parseTime(s); !t.IsZero() {
+			f.Updated = t
+			break
+		}
+	}
+	return f
+}
+
+// parseRSS reads an RSS 0.9x or 2.0 document from its rss element
+func parseRSS(d *xml.Decoder, start xml.StartElement) (*Feed, error) {
+	doc := struct {
+		Version string     `xml:"version,attr"`
+		Channel rssChannel `xml:"channel"`
+	}{}
+	if err := d.DecodeElement(&doc, &start); err != nil {
+		return nil, err
+	}
+	f := doc.Channel.feed(RSS, doc.Version)
+	for i := range doc.Channel.Items {
+		f.Items = append(f.Items, doc.Channel.Items[i].item())
+	}
+	return f, nil
+}
+
+// parseRDF reads an RSS 1.0 document from its rdf:RDF element, the
+// items are siblings of the channel
+func parseRDF(d *xml.Decoder, start xml.StartElement) (*Feed, error) {
+	doc := struct {
+		Channel rssChannel `xml:"channel"`
+		Items   []rssItem  `xml:"item"`
+	}{}
+	if err := d.DecodeElement(&doc, &start); err != nil {
+		return nil, err
+	}
+	// RSS 1.0 uses dc:language
+	f := doc.Channel.feed(RDF, "1.0")
+	for i := range doc.Items {
+		f.Items = append(f.Items, doc.Items[i].item())
+	}
+	return f, nil
+}
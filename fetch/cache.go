@@ -0,0 +1,108 @@
+/*
+fetch is a Go package for retrieving the feeds listed in an OPML
+outline.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package fetch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CacheEntry is a cached response
+type CacheEntry struct {
+	URL          string    `json:"url"`
+	ContentType  string    `json:"content_type,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Body         []byte    `json:"body"`
+	Fetched      time.Time `json:"fetched"`
+}
+
+// Cache keeps responses by URL, Get returns nil and no error for a URL
+// that isn't cached. Implementations must be safe for concurrent use.
+type Cache interface {
+	Get(url string) (*CacheEntry, error)
+	Put(url string, entry *CacheEntry) error
+}
+
+// DiskCache keeps responses as JSON files in a directory, the files are
+// named by the SHA-256 hash of the URL
+type DiskCache struct {
+	Dir string
+}
+
+// NewDiskCache returns a DiskCache in dir creating it if needed
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0775); err != nil {
+		return nil, err
+	}
+	return &DiskCache{Dir: dir}, nil
+}
+
+// fname returns the file name of a URL's entry
+func (c *DiskCache) fname(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Get reads the entry for url
+func (c *DiskCache) Get(url string) (*CacheEntry, error) {
+	src, err := ioutil.ReadFile(c.fname(url))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry := new(CacheEntry)
+	if err := json.Unmarshal(src, entry); err != nil {
+		return nil, err
+	}
+	if entry.URL != url {
+		return nil, nil
+	}
+	return entry, nil
+}
+
+// Put writes the entry for url, the file is replaced atomically so
+// concurrent readers never see a partial entry
+func (c *DiskCache) Put(url string, entry *CacheEntry) error {
+	src, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	fp, err := ioutil.TempFile(c.Dir, ".entry-*")
+	if err != nil {
+		return err
+	}
+	if _, err := fp.Write(src); err != nil {
+		fp.Close()
+		os.Remove(fp.Name())
+		return err
+	}
+	if err := fp.Close(); err != nil {
+		os.Remove(fp.Name())
+		return err
+	}
+	return os.Rename(fp.Name(), c.fname(url))
+}
@@ -0,0 +1,333 @@
+/*
+fetch is a Go package for retrieving the feeds listed in an OPML
+outline.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package fetch retrieves the feeds named by the xmlUrl attributes of
+// an OPML outline using a bounded pool of workers. Responses can be
+// kept in a Cache so feeds that haven't changed are revalidated with a
+// conditional GET (If-None-Match and If-Modified-Since).
+//
+//	f := fetch.New(fetch.Options{Workers: 8, Cache: cache})
+//	for _, res := range f.FetchAll(ctx, o) {
+//	    if res.Err != nil {
+//	        ...
+//	    }
+//	}
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	// My Packages
+	"github.com/rsdoiel/opml"
+)
+
+const (
+	// DefaultWorkers is the number of feeds fetched at once when
+	// Options.Workers isn't set
+	DefaultWorkers = 4
+
+	// DefaultTimeout is the time allowed for each feed, including
+	// redirects, when Options.Timeout isn't set
+	DefaultTimeout = 30 * time.Second
+
+	// DefaultMaxRedirects is the number of redirects followed when
+	// Options.MaxRedirects isn't set
+	DefaultMaxRedirects = 10
+
+	// DefaultMaxBytes is the largest response body read when
+	// Options.MaxBytes isn't set
+	DefaultMaxBytes = 16 << 20
+)
+
+// Options control a Fetcher, the zero value uses the defaults
+type Options struct {
+	// Workers is the number of feeds fetched at once
+	Workers int
+
+	// Timeout is the time allowed for each feed
+	Timeout time.Duration
+
+	// MaxRedirects is the number of redirects followed before giving up
+	MaxRedirects int
+
+	// MaxBytes is the largest response body read, larger responses
+	// are an error
+	MaxBytes int64
+
+	// UserAgent is sent with each request if set
+	UserAgent string
+
+	// Client makes the requests, http.DefaultClient is used if it is
+	// nil. Its CheckRedirect function is replaced to record redirects.
+	Client *http.Client
+
+	// Cache keeps responses for conditional requests, responses aren't
+	// cached if it is nil
+	Cache Cache
+}
+
+// Redirect is a redirect response followed while fetching a feed
+type Redirect struct {
+	// URL is the URL that was redirected
+	URL string `json:"url"`
+	// StatusCode is the redirect's status, e.g. 301 or 302
+	StatusCode int `json:"status_code"`
+	// Location is the URL redirected to
+	Location string `json:"location"`
+}
+
+// Permanent reports if the redirect is permanent (301 or 308)
+func (r Redirect) Permanent() bool {
+	return r.StatusCode == http.StatusMovedPermanently || r.StatusCode == http.StatusPermanentRedirect
+}
+
+// Result is the outcome of fetching one feed
+type Result struct {
+	// Path and Outline are the outline element the feed came from,
+	// they are not set by Fetch
+	Path    opml.OutlinePath `json:"path,omitempty"`
+	Outline *opml.Outline    `json:"-"`
+
+	// URL is the URL requested and FinalURL the URL of the response
+	// after any redirects
+	URL      string `json:"url"`
+	FinalURL string `json:"final_url,omitempty"`
+
+	// Redirects are the redirects followed in order
+	Redirects []Redirect `json:"redirects,omitempty"`
+
+	// StatusCode is the status of the final response, it is zero if
+	// there was no response
+	StatusCode int `json:"status_code,omitempty"`
+
+	// ContentType, ETag and LastModified are from the response, or
+	// the cache when NotModified is set
+	ContentType  string `json:"content_type,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+
+	// Body is the feed, it comes from the cache when NotModified is set
+	Body []byte `json:"-"`
+
+	// NotModified is set when the server answered a conditional
+	// request with 304 Not Modified
+	NotModified bool `json:"not_modified,omitempty"`
+
+	// Duration is the time taken to fetch the feed
+	Duration time.Duration `json:"duration"`
+
+	// Err is set if the feed couldn't be fetched, including for
+	// responses other than 200 OK and 304 Not Modified
+	Err error `json:"-"`
+}
+
+// Permanent returns the URL reached by following the permanent
+// redirects up to the first temporary one, or "" if the first redirect
+// isn't permanent. It is where a feed has moved to.
+func (res *Result) Permanent() string {
+	loc := ""
+	for _, r := range res.Redirects {
+		if !r.Permanent() {
+			break
+		}
+		loc = r.Location
+	}
+	return loc
+}
+
+// Fetcher retrieves feeds, it is safe for concurrent use
+type Fetcher struct {
+	opts Options
+}
+
+// New returns a Fetcher using opts
+func New(opts Options) *Fetcher {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxRedirects <= 0 {
+		opts.MaxRedirects = DefaultMaxRedirects
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	return &Fetcher{opts: opts}
+}
+
+// Fetch retrieves the feed at rawURL. Errors are returned in
+// Result.Err.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) *Result {
+	start := time.Now()
+	res := &Result{URL: rawURL}
+	err := f.fetch(ctx, res)
+	if err != nil {
+		res.Err = err
+	}
+	res.Duration = time.Since(start)
+	return res
+}
+
+func (f *Fetcher) fetch(ctx context.Context, res *Result) error {
+	ctx, cancel := context.WithTimeout(ctx, f.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, res.URL, nil)
+	if err != nil {
+		return err
+	}
+	if f.opts.UserAgent != "" {
+		req.Header.Set("User-Agent", f.opts.UserAgent)
+	}
+	var cached *CacheEntry
+	if f.opts.Cache != nil {
+		if cached, err = f.opts.Cache.Get(res.URL); err != nil {
+			return err
+		}
+		if cached != nil {
+			if cached.ETag != "" {
+				req.Header.Set("If-None-Match", cached.ETag)
+			}
+			if cached.LastModified != "" {
+				req.Header.Set("If-Modified-Since", cached.LastModified)
+			}
+		}
+	}
+
+	// Each request gets its own copy of the client to record redirects
+	client := *f.opts.Client
+	client.CheckRedirect = func(next *http.Request, via []*http.Request) error {
+		if len(via) > f.opts.MaxRedirects {
+			return fmt.Errorf("stopped after %d redirects", f.opts.MaxRedirects)
+		}
+		prev := via[len(via)-1]
+		r := Redirect{URL: prev.URL.String(), Location: next.URL.String()}
+		if next.Response != nil {
+			r.StatusCode = next.Response.StatusCode
+		}
+		res.Redirects = append(res.Redirects, r)
+		return nil
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	res.StatusCode = resp.StatusCode
+	res.FinalURL = resp.Request.URL.String()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if cached == nil {
+			return fmt.Errorf("%s without a cached copy", resp.Status)
+		}
+		res.NotModified = true
+		res.ContentType, res.ETag, res.LastModified = cached.ContentType, cached.ETag, cached.LastModified
+		res.Body = cached.Body
+		return nil
+	case http.StatusOK:
+	default:
+		return fmt.Errorf("%s", resp.Status)
+	}
+
+	res.ContentType = resp.Header.Get("Content-Type")
+	res.ETag = resp.Header.Get("ETag")
+	res.LastModified = resp.Header.Get("Last-Modified")
+	res.Body, err = ioutil.ReadAll(io.LimitReader(resp.Body, f.opts.MaxBytes+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(res.Body)) > f.opts.MaxBytes {
+		res.Body = nil
+		return fmt.Errorf("response larger than %d bytes", f.opts.MaxBytes)
+	}
+	if f.opts.Cache != nil && (res.ETag != "" || res.LastModified != "") {
+		entry := &CacheEntry{
+			URL:          res.URL,
+			ContentType:  res.ContentType,
+			ETag:         res.ETag,
+			LastModified: res.LastModified,
+			Body:         res.Body,
+			Fetched:      time.Now().UTC(),
+		}
+		if err := f.opts.Cache.Put(res.URL, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolveRedirect fetches ref and returns where it has moved
+// permanently, see Result.Permanent, or ref if it hasn't moved. An
+// error is returned if ref can't be fetched and hasn't moved. It lets a
+// Fetcher be used as an opml.RedirectResolver.
+func (f *Fetcher) ResolveRedirect(ref string) (string, error) {
+	res := f.Fetch(context.Background(), ref)
+	if loc := res.Permanent(); loc != "" {
+		return loc, nil
+	}
+	if res.Err != nil {
+		return "", res.Err
+	}
+	return ref, nil
+}
+
+// FetchAll retrieves the feed of each outline element with an xmlUrl
+// using Options.Workers workers. The results are returned in outline
+// order. If ctx is cancelled the feeds not yet fetched have ctx's error.
+func (f *Fetcher) FetchAll(ctx context.Context, o *opml.OPML) []*Result {
+	results := []*Result{}
+	o.WalkWithPath(func(ol *opml.Outline, p opml.OutlinePath, depth int, parent *opml.Outline) error {
+		if ol.XMLURL != "" {
+			results = append(results, &Result{Path: p, Outline: ol, URL: ol.XMLURL})
+		}
+		return nil
+	})
+
+	jobs := make(chan int)
+	wg := new(sync.WaitGroup)
+	for i := 0; i < f.opts.Workers && i < len(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				res := f.Fetch(ctx, results[j].URL)
+				res.Path, res.Outline = results[j].Path, results[j].Outline
+				results[j] = res
+			}
+		}()
+	}
+	for i := range results {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,148 @@
+/*
+fetch is a Go package for retrieving the feeds listed in an OPML
+outline.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	// My Packages
+	"github.com/rsdoiel/opml"
+)
+
+func newServer(hits *int32) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, "<rss></rss>")
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/temporary", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/temporary", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/feed", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestFetch(t *testing.T) {
+	hits := int32(0)
+	ts := newServer(&hits)
+	defer ts.Close()
+	cache, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	f := New(Options{Cache: cache, Timeout: 200 * time.Millisecond, MaxRedirects: 3})
+	ctx := context.Background()
+
+	res := f.Fetch(ctx, ts.URL+"/feed")
+	if res.Err != nil || res.StatusCode != 200 || string(res.Body) != "<rss></rss>" || res.NotModified {
+		t.Errorf("unexpected result %+v", res)
+	}
+	// The second request is conditional
+	res = f.Fetch(ctx, ts.URL+"/feed")
+	if res.Err != nil || !res.NotModified || string(res.Body) != "<rss></rss>" || res.ContentType != "application/rss+xml" {
+		t.Errorf("expected a not modified result from the cache, got %+v", res)
+	}
+
+	res = f.Fetch(ctx, ts.URL+"/moved")
+	if res.Err != nil || res.FinalURL != ts.URL+"/feed" || len(res.Redirects) != 2 {
+		t.Errorf("expected two redirects, got %+v", res)
+		t.FailNow()
+	}
+	if !res.Redirects[0].Permanent() || res.Redirects[1].Permanent() || res.Redirects[0].Location != ts.URL+"/temporary" {
+		t.Errorf("unexpected redirects %+v", res.Redirects)
+	}
+	if loc := res.Permanent(); loc != ts.URL+"/temporary" {
+		t.Errorf("expected the permanent location to be /temporary, got %q", loc)
+	}
+	if loc, err := f.ResolveRedirect(ts.URL + "/moved"); err != nil || loc != ts.URL+"/temporary" {
+		t.Errorf("expected /moved to resolve to /temporary, got %q, %v", loc, err)
+	}
+	if loc, err := f.ResolveRedirect(ts.URL + "/temporary"); err != nil || loc != ts.URL+"/temporary" {
+		t.Errorf("expected /temporary to resolve to itself, got %q, %v", loc, err)
+	}
+	if _, err := f.ResolveRedirect(ts.URL + "/missing"); err == nil {
+		t.Errorf("expected an error resolving /missing")
+	}
+
+	if res = f.Fetch(ctx, ts.URL+"/loop"); res.Err == nil {
+		t.Errorf("expected an error for a redirect loop")
+	}
+	if res = f.Fetch(ctx, ts.URL+"/slow"); res.Err == nil {
+		t.Errorf("expected a timeout")
+	}
+	if res = f.Fetch(ctx, ts.URL+"/missing"); res.Err == nil || res.StatusCode != 404 {
+		t.Errorf("expected a 404, got %+v", res)
+	}
+}
+
+func TestFetchAll(t *testing.T) {
+	hits := int32(0)
+	ts := newServer(&hits)
+	defer ts.Close()
+
+	o := opml.New()
+	o.Body.Outline = []*opml.Outline{
+		{Text: "One", XMLURL: ts.URL + "/feed"},
+		{Text: "Folder", Outline: []*opml.Outline{
+			{Text: "Two", XMLURL: ts.URL + "/missing"},
+			{Text: "Three", XMLURL: ts.URL + "/moved"},
+		}},
+		{Text: "No feed"},
+	}
+	results := New(Options{Workers: 2}).FetchAll(context.Background(), o)
+	if len(results) != 3 {
+		t.Errorf("expected 3 results, got %d", len(results))
+		t.FailNow()
+	}
+	for i, expected := range []string{"/1", "/2/1", "/2/2"} {
+		if s := results[i].Path.String(); s != expected {
+			t.Errorf("expected result %d to be %s, got %s", i, expected, s)
+		}
+	}
+	if results[0].Err != nil || results[1].Err == nil || results[2].Err != nil || results[2].Outline.Text != "Three" {
+		t.Errorf("unexpected results %+v", results)
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("expected 2 requests for the feed, got %d", n)
+	}
+}
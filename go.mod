@@ -2,4 +2,7 @@ module github.com/rsdoiel/opml
 
 go 1.16
 
-require github.com/caltechlibrary/cli v0.0.16
+require (
+	github.com/caltechlibrary/cli v0.0.16
+	golang.org/x/text v0.4.0
+)
@@ -0,0 +1,280 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+)
+
+// DefaultMaxDepth is the number of nested includes Resolve follows when
+// ResolveOptions.MaxDepth isn't set
+const DefaultMaxDepth = 8
+
+var (
+	// ErrIncludeCycle is returned by Resolve when a document includes
+	// itself directly or through other documents
+	ErrIncludeCycle = errors.New("include cycle")
+
+	// ErrIncludeDepth is returned by Resolve when includes are nested
+	// deeper than the MaxDepth
+	ErrIncludeDepth = errors.New("includes nested too deeply")
+
+	// ErrLocalInclude is returned by Resolve when a document retrieved
+	// over http or https includes a document that isn't, e.g. a file URL
+	ErrLocalInclude = errors.New("remote document can't include a local document")
+)
+
+// IncludeError is returned by Resolve when an included document can't
+// be fetched, parsed or resolved. Path is the include element in the
+// document being resolved and URL the included document.
+type IncludeError struct {
+	Path OutlinePath
+	URL  string
+	Err  error
+}
+
+func (e *IncludeError) Error() string {
+	return fmt.Sprintf("include %s (%s): %s", e.Path, e.URL, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *IncludeError) Unwrap() error {
+	return e.Err
+}
+
+// Fetcher retrieves the source of an included document, ref is an
+// absolute URL
+type Fetcher interface {
+	Fetch(ref string) ([]byte, error)
+}
+
+// FetcherFunc lets an ordinary function be used as a Fetcher
+type FetcherFunc func(ref string) ([]byte, error)
+
+// Fetch calls f(ref)
+func (f FetcherFunc) Fetch(ref string) ([]byte, error) {
+	return f(ref)
+}
+
+// FileFetcher reads file URLs from the local file system
+type FileFetcher struct{}
+
+// Fetch reads the file named by a file URL
+func (FileFetcher) Fetch(ref string) ([]byte, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "" && u.Scheme != "file" {
+		return nil, fmt.Errorf("%q is not a file URL", ref)
+	}
+	return ioutil.ReadFile(filepath.FromSlash(u.Path))
+}
+
+// HTTPFetcher retrieves http and https URLs using Client, or
+// http.DefaultClient if Client is nil
+type HTTPFetcher struct {
+	Client *http.Client
+}
+
+// Fetch gets ref returning an error if the response isn't 200 OK
+func (f HTTPFetcher) Fetch(ref string) ([]byte, error) {
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Get(ref)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// DefaultFetcher reads file URLs with a FileFetcher and http and https
+// URLs with an HTTPFetcher
+var DefaultFetcher Fetcher = FetcherFunc(func(ref string) ([]byte, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "", "file":
+		return FileFetcher{}.Fetch(ref)
+	case "http", "https":
+		return HTTPFetcher{}.Fetch(ref)
+	}
+	return nil, fmt.Errorf("can't fetch %q, unsupported scheme", ref)
+})
+
+// ResolveOptions control how Resolve includes documents
+type ResolveOptions struct {
+	// Base is the URL or file name of the document being resolved,
+	// relative include URLs are resolved against it. If it is empty
+	// they are resolved against the current directory.
+	Base string
+
+	// Fetcher retrieves the included documents, DefaultFetcher is used
+	// if it is nil
+	Fetcher Fetcher
+
+	// MaxDepth is the number of nested includes followed, if it is
+	// zero DefaultMaxDepth is used
+	MaxDepth int
+
+	// KeepWrapper keeps each include outline element and adds the
+	// included outline elements after its children, otherwise the
+	// include element is replaced by the included outline elements
+	// followed by its children
+	KeepWrapper bool
+}
+
+// baseURL returns s as an absolute URL, file names become file URLs
+func baseURL(s string) (*url.URL, error) {
+	if u, err := url.Parse(s); err == nil && u.Scheme != "" && len(u.Scheme) > 1 {
+		return u, nil
+	}
+	if s == "" {
+		s = "."
+	}
+	abs, err := filepath.Abs(s)
+	if err != nil {
+		return nil, err
+	}
+	if s == "." {
+		// Resolve references against the directory, not its parent
+		abs += string(filepath.Separator)
+	}
+	return &url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}, nil
+}
+
+// isRemote reports if u is an http or https URL
+func isRemote(u *url.URL) bool {
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+type resolver struct {
+	opts ResolveOptions
+}
+
+// Resolve replaces the outline elements of type "include" with the body
+// of the document named by their url attribute, see ResolveOptions.
+// Included documents are resolved in turn with relative URLs resolved
+// against the included document's URL. A document with an http or https
+// URL may only include http and https URLs. Errors are returned as an
+// *IncludeError, the document may be partly resolved when an error is
+// returned.
+func (o *OPML) Resolve(opts ResolveOptions) error {
+	if opts.Fetcher == nil {
+		opts.Fetcher = DefaultFetcher
+	}
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = DefaultMaxDepth
+	}
+	base, err := baseURL(opts.Base)
+	if err != nil {
+		return err
+	}
+	stack := []string{}
+	if opts.Base != "" {
+		stack = append(stack, base.String())
+	}
+	if o.Body == nil {
+		return nil
+	}
+	r := &resolver{opts: opts}
+	return r.resolveList(&o.Body.Outline, OutlinePath{}, base, stack, 0)
+}
+
+// resolveList resolves the include elements in the list of outline
+// elements at p, depth is the number of includes followed to reach it
+func (r *resolver) resolveList(list *[]*Outline, p OutlinePath, base *url.URL, stack []string, depth int) error {
+	out := make([]*Outline, 0, len(*list))
+	for i, ol := range *list {
+		if ol == nil {
+			continue
+		}
+		cur := p.Child(i + 1)
+		if err := r.resolveList(&ol.Outline, cur, base, stack, depth); err != nil {
+			return err
+		}
+		if ol.Type != "include" || ol.URL == "" {
+			out = append(out, ol)
+			continue
+		}
+		included, err := r.include(ol.URL, cur, base, stack, depth+1)
+		if err != nil {
+			return err
+		}
+		if r.opts.KeepWrapper {
+			ol.Outline = append(ol.Outline, included...)
+			out = append(out, ol)
+		} else {
+			out = append(out, included...)
+			out = append(out, ol.Outline...)
+		}
+	}
+	*list = out
+	return nil
+}
+
+// include fetches, parses and resolves the document at ref returning
+// its body's outline elements
+func (r *resolver) include(ref string, p OutlinePath, base *url.URL, stack []string, depth int) ([]*Outline, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, &IncludeError{Path: p, URL: ref, Err: err}
+	}
+	u = base.ResolveReference(u)
+	key := u.String()
+	if isRemote(base) && !isRemote(u) {
+		return nil, &IncludeError{Path: p, URL: key, Err: ErrLocalInclude}
+	}
+	for _, s := range stack {
+		if s == key {
+			return nil, &IncludeError{Path: p, URL: key, Err: ErrIncludeCycle}
+		}
+	}
+	if depth > r.opts.MaxDepth {
+		return nil, &IncludeError{Path: p, URL: key, Err: ErrIncludeDepth}
+	}
+	src, err := r.opts.Fetcher.Fetch(key)
+	if err != nil {
+		return nil, &IncludeError{Path: p, URL: key, Err: err}
+	}
+	doc, err := ParseWith(src, ParseOptions{Filename: key})
+	if err != nil {
+		return nil, &IncludeError{Path: p, URL: key, Err: err}
+	}
+	if doc.Body == nil {
+		return nil, nil
+	}
+	next := append(append([]string{}, stack...), key)
+	if err := r.resolveList(&doc.Body.Outline, OutlinePath{}, u, next, depth); err != nil {
+		return nil, &IncludeError{Path: p, URL: key, Err: err}
+	}
+	return doc.Body.Outline, nil
+}
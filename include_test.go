@@ -0,0 +1,185 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// outlineText returns the text of each outline element prefixed by its path
+func outlineText(o *OPML) string {
+	l := []string{}
+	o.WalkWithPath(func(ol *Outline, p OutlinePath, depth int, parent *Outline) error {
+		l = append(l, p.String()+" "+ol.Text)
+		return nil
+	})
+	return strings.Join(l, "\n")
+}
+
+func TestResolve(t *testing.T) {
+	fname := "testdata/include/main.opml"
+	o, err := ReadFile(fname)
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if err := o.Resolve(ResolveOptions{Base: fname}); err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	expected := `/1 Before
+/2 Part one
+/3 Folder
+/3/1 Leaf one
+/3/2 Leaf two
+/4 After`
+	if s := outlineText(o); s != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, s)
+	}
+
+	o, err = ReadFile(fname)
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if err := o.Resolve(ResolveOptions{Base: fname, KeepWrapper: true}); err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	expected = `/1 Before
+/2 Part
+/2/1 Part one
+/2/2 Folder
+/2/2/1 Leaf
+/2/2/1/1 Leaf one
+/2/2/1/2 Leaf two
+/3 After`
+	if s := outlineText(o); s != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, s)
+	}
+
+	// Depth limit
+	o, _ = ReadFile(fname)
+	err = o.Resolve(ResolveOptions{Base: fname, MaxDepth: 1})
+	if !errors.Is(err, ErrIncludeDepth) {
+		t.Errorf("expected ErrIncludeDepth, got %v", err)
+	}
+
+	// Cycles
+	o, _ = ReadFile("testdata/include/cycle-a.opml")
+	err = o.Resolve(ResolveOptions{Base: "testdata/include/cycle-a.opml"})
+	if !errors.Is(err, ErrIncludeCycle) {
+		t.Errorf("expected ErrIncludeCycle, got %v", err)
+	}
+	var ie *IncludeError
+	if !errors.As(err, &ie) || ie.Path.String() != "/1" || !strings.HasSuffix(ie.URL, "cycle-b.opml") {
+		t.Errorf("unexpected include error %v", err)
+	}
+
+	// Missing files
+	o, _ = ReadFile(fname)
+	if err := o.Resolve(ResolveOptions{Base: "testdata/main.opml"}); err == nil {
+		t.Errorf("expected an error for a missing include")
+	}
+}
+
+func TestResolveFetcher(t *testing.T) {
+	docs := map[string]string{
+		"https://example.org/lists/all.opml":  `<opml version="2.0"><body><outline text="Go" type="include" url="go.opml"/><outline text="News" type="include" url="/news/index.opml"/></body></opml>`,
+		"https://example.org/lists/go.opml":   `<opml version="2.0"><body><outline text="Go Blog" type="rss" xmlUrl="https://go.dev/blog/feed.atom"/></body></opml>`,
+		"https://example.org/news/index.opml": `<opml version="2.0"><body><outline text="BBC" type="rss" xmlUrl="http://feeds.bbci.co.uk/news/rss.xml"/></body></opml>`,
+	}
+	fetched := []string{}
+	fetcher := FetcherFunc(func(ref string) ([]byte, error) {
+		fetched = append(fetched, ref)
+		if src, ok := docs[ref]; ok {
+			return []byte(src), nil
+		}
+		return nil, fmt.Errorf("404 Not Found")
+	})
+	o, err := Parse([]byte(`<opml version="2.0"><body><outline text="All" type="include" url="lists/all.opml"/></body></opml>`))
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if err := o.Resolve(ResolveOptions{Base: "https://example.org/index.opml", Fetcher: fetcher}); err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	expected := "/1 Go Blog\n/2 BBC"
+	if s := outlineText(o); s != expected {
+		t.Errorf("expected\n%s\ngot\n%s\nfetched %s", expected, s, fetched)
+	}
+
+	// The include element's own children follow the included elements
+	o, err = Parse([]byte(`<opml version="2.0"><body><outline text="Go" type="include" url="lists/go.opml"><outline text="Go Weekly" type="rss" xmlUrl="https://golangweekly.com/rss"/></outline></body></opml>`))
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if err := o.Resolve(ResolveOptions{Base: "https://example.org/index.opml", Fetcher: fetcher}); err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	expected = "/1 Go Blog\n/2 Go Weekly"
+	if s := outlineText(o); s != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, s)
+	}
+}
+
+func TestResolveRemoteLocal(t *testing.T) {
+	fname, err := filepath.Abs("testdata/include/part.opml")
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	local := "file://" + filepath.ToSlash(fname)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<opml version="2.0"><body><outline text="Local" type="include" url="%s"/></body></opml>`, local)
+	}))
+	defer ts.Close()
+
+	o, err := Parse([]byte(`<opml version="2.0"><body><outline text="Remote" type="include" url="list.opml"/></body></opml>`))
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	err = o.Resolve(ResolveOptions{Base: ts.URL + "/index.opml"})
+	if !errors.Is(err, ErrLocalInclude) {
+		t.Errorf("expected ErrLocalInclude, got %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), local) {
+		t.Errorf("expected the error to name %s, got %v", local, err)
+	}
+
+	// A local document can include it
+	o, err = Parse([]byte(fmt.Sprintf(`<opml version="2.0"><body><outline text="Local" type="include" url="%s"/></body></opml>`, local)))
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if err := o.Resolve(ResolveOptions{}); err != nil {
+		t.Errorf("%s", err)
+	}
+}
@@ -0,0 +1,259 @@
+/*
+index is a Go package for searching the items of the feeds in an OPML
+outline.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package index keeps a full-text index of feed items, e.g. those
+// harvested by the river package, keyed to the outline element of the
+// feed they came from. Items are added incrementally and found with
+// keyword and "quoted phrase" queries.
+//
+//	ix, err := index.Open("items.json")
+//	...
+//	ix.AddFeed(feed)
+//	hits := ix.Search(`go "river of news"`, index.SearchOptions{})
+//	...
+//	err = ix.Save()
+package index
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	// My Packages
+	"github.com/rsdoiel/opml"
+	"github.com/rsdoiel/opml/river"
+)
+
+// Doc is an indexed feed item
+type Doc struct {
+	// ID identifies the item, it is the feed's URL and the item's ID
+	// separated by a space
+	ID string `json:"id"`
+
+	// Path is the outline element of the feed the item came from
+	Path      opml.OutlinePath `json:"path"`
+	FeedURL   string           `json:"feed_url"`
+	FeedTitle string           `json:"feed_title,omitempty"`
+
+	Title   string    `json:"title,omitempty"`
+	Summary string    `json:"summary,omitempty"`
+	Link    string    `json:"link,omitempty"`
+	Date    time.Time `json:"date,omitempty"`
+}
+
+// posting records where a term appears in a doc, positions in the
+// summary follow those in the title with a gap so phrases don't span
+// the two
+type posting struct {
+	doc       int
+	positions []int
+}
+
+// Index is a full-text index of Docs. It isn't safe for concurrent
+// use.
+type Index struct {
+	// Filename is where Save writes the index
+	Filename string
+
+	docs []*Doc
+	// titleLen is the number of words in the title of each doc
+	titleLen []int
+	byID     map[string]int
+	terms    map[string][]posting
+}
+
+// New returns an empty index saved to fname
+func New(fname string) *Index {
+	return &Index{Filename: fname, byID: map[string]int{}, terms: map[string][]posting{}}
+}
+
+// Open reads the index saved in fname, an empty index is returned if
+// the file doesn't exist
+func Open(fname string) (*Index, error) {
+	ix := New(fname)
+	src, err := ioutil.ReadFile(fname)
+	if os.IsNotExist(err) {
+		return ix, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	docs := []*Doc{}
+	if err := json.Unmarshal(src, &docs); err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		ix.Add(doc)
+	}
+	return ix, nil
+}
+
+// Save writes the docs to the index's Filename, the file is replaced
+// atomically. The term index is rebuilt by Open.
+func (ix *Index) Save() error {
+	docs := []*Doc{}
+	for _, doc := range ix.docs {
+		if doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+	src, err := json.Marshal(docs)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(ix.Filename)
+	fp, err := ioutil.TempFile(dir, ".index-*")
+	if err != nil {
+		return err
+	}
+	if _, err := fp.Write(src); err != nil {
+		fp.Close()
+		os.Remove(fp.Name())
+		return err
+	}
+	if err := fp.Close(); err != nil {
+		os.Remove(fp.Name())
+		return err
+	}
+	return os.Rename(fp.Name(), ix.Filename)
+}
+
+// Len returns the number of docs in the index
+func (ix *Index) Len() int {
+	return len(ix.byID)
+}
+
+// Get returns the doc with id or nil
+func (ix *Index) Get(id string) *Doc {
+	if i, ok := ix.byID[id]; ok {
+		return ix.docs[i]
+	}
+	return nil
+}
+
+// tokenize splits s into lower case words of letters and digits
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Add indexes doc replacing a doc with the same ID. It returns false if
+// the doc was already indexed unchanged.
+func (ix *Index) Add(doc *Doc) bool {
+	if cur := ix.Get(doc.ID); cur != nil {
+		if cur.Path.String() == doc.Path.String() && cur.FeedTitle == doc.FeedTitle &&
+			cur.Title == doc.Title && cur.Summary == doc.Summary &&
+			cur.Link == doc.Link && cur.Date.Equal(doc.Date) {
+			return false
+		}
+		ix.Remove(doc.ID)
+	}
+	i := len(ix.docs)
+	title := tokenize(doc.Title)
+	ix.docs = append(ix.docs, doc)
+	ix.titleLen = append(ix.titleLen, len(title))
+	ix.byID[doc.ID] = i
+
+	words := append(append(title, ""), tokenize(doc.Summary)...)
+	found := map[string]*posting{}
+	order := []string{}
+	for pos, word := range words {
+		if word == "" {
+			continue
+		}
+		p, ok := found[word]
+		if !ok {
+			p = &posting{doc: i}
+			found[word] = p
+			order = append(order, word)
+		}
+		p.positions = append(p.positions, pos)
+	}
+	for _, word := range order {
+		ix.terms[word] = append(ix.terms[word], *found[word])
+	}
+	return true
+}
+
+// Remove drops the doc with id from the index, it returns false if
+// there is no such doc
+func (ix *Index) Remove(id string) bool {
+	i, ok := ix.byID[id]
+	if !ok {
+		return false
+	}
+	delete(ix.byID, id)
+	ix.docs[i] = nil
+	for term, postings := range ix.terms {
+		kept := postings[:0]
+		for _, p := range postings {
+			if p.doc != i {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) == 0 {
+			delete(ix.terms, term)
+		} else {
+			ix.terms[term] = kept
+		}
+	}
+	return true
+}
+
+// AddFeed indexes the items of a feed kept by the river package, it
+// returns the number of docs added or changed
+func (ix *Index) AddFeed(f *river.Feed) int {
+	n := 0
+	for _, item := range f.Items {
+		doc := &Doc{
+			ID:        f.URL + " " + item.ID,
+			Path:      f.Path,
+			FeedURL:   f.URL,
+			FeedTitle: f.Title,
+			Title:     item.Title,
+			Summary:   item.Body,
+			Link:      item.Link,
+			Date:      item.When(),
+		}
+		if ix.Add(doc) {
+			n++
+		}
+	}
+	return n
+}
+
+// Docs returns the docs in the index newest first
+func (ix *Index) Docs() []*Doc {
+	docs := []*Doc{}
+	for _, doc := range ix.docs {
+		if doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		return docs[i].Date.After(docs[j].Date)
+	})
+	return docs
+}
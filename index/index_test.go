@@ -0,0 +1,136 @@
+/*
+index is a Go package for searching the items of the feeds in an OPML
+outline.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package index
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	// My Packages
+	"github.com/rsdoiel/opml"
+	"github.com/rsdoiel/opml/river"
+)
+
+func testFeeds() []*river.Feed {
+	day := func(d int) time.Time {
+		return time.Date(2021, 3, d, 12, 0, 0, 0, time.UTC)
+	}
+	return []*river.Feed{
+		{URL: "https://a.example.org/feed", Path: opml.OutlinePath{1}, Title: "A", Items: []*river.Item{
+			{ID: "a1", Title: "The river of news", Body: "Dave Winer's River5 aggregator.", Link: "https://a.example.org/1", PubDate: day(1), FirstSeen: day(1)},
+			{ID: "a2", Title: "Go modules", Body: "News about the Go river of releases.", Link: "https://a.example.org/2", PubDate: day(3), FirstSeen: day(3)},
+		}},
+		{URL: "https://b.example.org/feed", Path: opml.OutlinePath{2, 1}, Title: "B", Items: []*river.Item{
+			{ID: "b1", Title: "OPML outlines", Body: "Outlines, rivers and news.", PubDate: day(2), FirstSeen: day(2)},
+			{ID: "b2", Title: "Unrelated", Body: "Nothing to see here.", PubDate: day(4), FirstSeen: day(4)},
+		}},
+	}
+}
+
+// ids returns the item IDs of hits
+func ids(hits []Hit) string {
+	s := []string{}
+	for _, hit := range hits {
+		s = append(s, fmt.Sprintf("%s:%d", hit.ID[strings.LastIndex(hit.ID, " ")+1:], hit.Score))
+	}
+	return strings.Join(s, " ")
+}
+
+func TestParseQuery(t *testing.T) {
+	q := ParseQuery(`Go  "River of News" -"dave winer" -opml "unterminated phrase`)
+	expected := Query{
+		Phrases: [][]string{{"go"}, {"river", "of", "news"}, {"unterminated", "phrase"}},
+		Exclude: [][]string{{"dave", "winer"}, {"opml"}},
+	}
+	if !reflect.DeepEqual(q, expected) {
+		t.Errorf("expected %+v, got %+v", expected, q)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ix := New(filepath.Join(t.TempDir(), "index.json"))
+	for _, f := range testFeeds() {
+		if n := ix.AddFeed(f); n != 2 {
+			t.Errorf("expected 2 docs added, got %d", n)
+		}
+	}
+	expected := map[string]string{
+		`news`:                "a1:2 a2:1 b1:1",
+		`"river of news"`:     "a1:2",
+		`river news`:          "a1:4 a2:2",
+		`NEWS -"river of"`:    "b1:1",
+		`river5`:              "a1:1",
+		`missing`:             "",
+		``:                    "b2:0 a2:0 b1:0 a1:0",
+		`-news`:               "b2:0",
+		`"news about the go"`: "a2:1",
+	}
+	for query, s := range expected {
+		if hits := ix.Search(query, SearchOptions{}); ids(hits) != s {
+			t.Errorf("%s: expected %q, got %q", query, s, ids(hits))
+		}
+	}
+	if s := ids(ix.Search("news", SearchOptions{Path: opml.OutlinePath{2}})); s != "b1:1" {
+		t.Errorf("expected hits under /2, got %q", s)
+	}
+	if s := ids(ix.Search("news", SearchOptions{Since: time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC), Limit: 1})); s != "a2:1" {
+		t.Errorf("expected one hit since March 2nd, got %q", s)
+	}
+
+	// Unchanged items aren't added again, changed items replace the
+	// old ones
+	feeds := testFeeds()
+	if n := ix.AddFeed(feeds[0]); n != 0 {
+		t.Errorf("expected no docs added, got %d", n)
+	}
+	feeds[0].Items[0].Title = "A river of news"
+	feeds[0].Items = append(feeds[0].Items, &river.Item{ID: "a3", Title: "Outlines", FirstSeen: time.Date(2021, 3, 5, 0, 0, 0, 0, time.UTC)})
+	if n := ix.AddFeed(feeds[0]); n != 2 || ix.Len() != 5 {
+		t.Errorf("expected 2 docs added for 5 in all, got %d, %d", n, ix.Len())
+	}
+	if s := ids(ix.Search("outlines", SearchOptions{})); s != "b1:3 a3:2" {
+		t.Errorf("unexpected hits %q", s)
+	}
+	if s := ids(ix.Search(`"the river"`, SearchOptions{})); s != "" {
+		t.Errorf("expected the old title to be removed, got %q", s)
+	}
+
+	if err := ix.Save(); err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	saved, err := Open(ix.Filename)
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if saved.Len() != 5 || ids(saved.Search("river", SearchOptions{})) != ids(ix.Search("river", SearchOptions{})) {
+		t.Errorf("expected the saved index to match, got %d docs", saved.Len())
+	}
+	if doc := saved.Get("https://a.example.org/feed a1"); doc == nil || doc.Path.String() != "/1" || doc.FeedTitle != "A" {
+		t.Errorf("unexpected doc %+v", doc)
+	}
+	if empty, err := Open(filepath.Join(t.TempDir(), "missing.json")); err != nil || empty.Len() != 0 {
+		t.Errorf("expected an empty index, got %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+/*
+index is a Go package for searching the items of the feeds in an OPML
+outline.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package index
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	// My Packages
+	"github.com/rsdoiel/opml"
+)
+
+// DefaultLimit is the number of hits Search returns when
+// SearchOptions.Limit isn't set
+const DefaultLimit = 20
+
+// Query is a parsed search, each phrase is one or more words
+type Query struct {
+	// Phrases must all appear in a doc
+	Phrases [][]string
+	// Exclude are phrases that must not appear
+	Exclude [][]string
+}
+
+// ParseQuery parses words and "quoted phrases", a leading "-" excludes
+// docs holding the word or phrase. Words are matched without regard to
+// case or punctuation.
+func ParseQuery(s string) Query {
+	q := Query{}
+	add := func(text string, exclude bool) {
+		words := tokenize(text)
+		if len(words) == 0 {
+			return
+		}
+		if exclude {
+			q.Exclude = append(q.Exclude, words)
+		} else {
+			q.Phrases = append(q.Phrases, words)
+		}
+	}
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		exclude := false
+		if s[0] == '-' {
+			exclude, s = true, s[1:]
+		}
+		if strings.HasPrefix(s, `"`) {
+			end := strings.Index(s[1:], `"`)
+			if end < 0 {
+				add(s[1:], exclude)
+				break
+			}
+			add(s[1:end+1], exclude)
+			s = s[end+2:]
+			continue
+		}
+		end := strings.IndexFunc(s, func(r rune) bool { return r == ' ' || r == '\t' || r == '\n' })
+		if end < 0 {
+			end = len(s)
+		}
+		add(s[0:end], exclude)
+		s = s[end:]
+	}
+	return q
+}
+
+// SearchOptions filter and limit the hits, the zero value uses the
+// defaults
+type SearchOptions struct {
+	// Limit is the number of hits returned, DefaultLimit is used if it
+	// is zero and all the hits are returned if it is negative
+	Limit int
+
+	// Path limits the hits to feeds at or under an outline element
+	Path opml.OutlinePath
+
+	// Since leaves out docs dated before it
+	Since time.Time
+}
+
+// Hit is a doc matching a query, Score counts the matches with those
+// in the title counting twice
+type Hit struct {
+	*Doc
+	Score int `json:"score"`
+}
+
+// inPath reports if p is prefix or one of its descendants
+func inPath(p opml.OutlinePath, prefix opml.OutlinePath) bool {
+	if len(p) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if p[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// match returns the score of each doc holding phrase
+func (ix *Index) match(phrase []string) map[int]int {
+	scores := map[int]int{}
+	rest := make([]map[int][]int, len(phrase)-1)
+	for k, word := range phrase[1:] {
+		rest[k] = map[int][]int{}
+		for _, p := range ix.terms[word] {
+			rest[k][p.doc] = p.positions
+		}
+	}
+	has := func(positions []int, pos int) bool {
+		i := sort.SearchInts(positions, pos)
+		return i < len(positions) && positions[i] == pos
+	}
+	for _, p := range ix.terms[phrase[0]] {
+		for _, pos := range p.positions {
+			found := true
+			for k := range rest {
+				if !has(rest[k][p.doc], pos+k+1) {
+					found = false
+					break
+				}
+			}
+			if found {
+				scores[p.doc]++
+				if pos < ix.titleLen[p.doc] {
+					scores[p.doc]++
+				}
+			}
+		}
+	}
+	return scores
+}
+
+// Search returns the docs matching query, see ParseQuery, highest
+// score first then newest first. A query without phrases matches every
+// doc.
+func (ix *Index) Search(query string, opts SearchOptions) []Hit {
+	q := ParseQuery(query)
+	scores := map[int]int{}
+	if len(q.Phrases) == 0 {
+		for i, doc := range ix.docs {
+			if doc != nil {
+				scores[i] = 0
+			}
+		}
+	}
+	for k, phrase := range q.Phrases {
+		found := ix.match(phrase)
+		if k == 0 {
+			scores = found
+			continue
+		}
+		for i := range scores {
+			if n, ok := found[i]; ok {
+				scores[i] += n
+			} else {
+				delete(scores, i)
+			}
+		}
+	}
+	for _, phrase := range q.Exclude {
+		for i := range ix.match(phrase) {
+			delete(scores, i)
+		}
+	}
+
+	hits := []Hit{}
+	for i, score := range scores {
+		doc := ix.docs[i]
+		if doc == nil || !inPath(doc.Path, opts.Path) {
+			continue
+		}
+		if !opts.Since.IsZero() && doc.Date.Before(opts.Since) {
+			continue
+		}
+		hits = append(hits, Hit{Doc: doc, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if !hits[i].Date.Equal(hits[j].Date) {
+			return hits[i].Date.After(hits[j].Date)
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	limit := opts.Limit
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+	if limit > 0 && len(hits) > limit {
+		hits = hits[0:limit]
+	}
+	return hits
+}
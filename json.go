@@ -0,0 +1,150 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// attrKey returns the JSON key for an attribute name, "local" or
+// "space:local"
+func attrKey(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// MarshalJSON writes the attributes as a JSON object in source order.
+// Attributes in a namespace have a "space:local" key, e.g.
+// "xmlns:team" or "http://example.org/team:owner".
+func (cattr CustomAttrs) MarshalJSON() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteString("{")
+	i := 0
+	for _, attr := range cattr {
+		if attr.Name.Local == "" {
+			continue
+		}
+		k, err := json.Marshal(attrKey(attr.Name))
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(attr.Value)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.Write(k)
+		buf.WriteString(":")
+		buf.Write(v)
+		i++
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON reads a JSON object written by MarshalJSON keeping the
+// order of the keys. Numbers and booleans are accepted as values.
+func (cattr *CustomAttrs) UnmarshalJSON(src []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(src))
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		*cattr = nil
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("other_attrs must be an object")
+	}
+	attrs := CustomAttrs{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := tok.(string)
+		tok, err = dec.Token()
+		if err != nil {
+			return err
+		}
+		var value string
+		switch v := tok.(type) {
+		case string:
+			value = v
+		case json.Number:
+			value = v.String()
+		case bool:
+			value = fmt.Sprintf("%t", v)
+		case nil:
+		default:
+			return fmt.Errorf("other_attrs %q must be a string", key)
+		}
+		name := xml.Name{Local: key}
+		if i := strings.LastIndex(key, ":"); i > 0 {
+			name = xml.Name{Space: key[0:i], Local: key[i+1:]}
+		}
+		attrs = append(attrs, xml.Attr{Name: name, Value: value})
+	}
+	*cattr = attrs
+	return nil
+}
+
+// UnmarshalJSON decodes a head object. It also accepts the keys
+// "dataModified" and "OwnerId" written by earlier versions of this
+// package.
+func (h *Head) UnmarshalJSON(src []byte) error {
+	// Head is renamed so json.Unmarshal doesn't call this method
+	type Elem Head
+	v := struct {
+		*Elem
+		LegacyModified string `json:"dataModified"`
+		LegacyOwnerID  string `json:"OwnerId"`
+	}{Elem: (*Elem)(h)}
+	if err := json.Unmarshal(src, &v); err != nil {
+		return err
+	}
+	if h.Modified == "" {
+		h.Modified = v.LegacyModified
+	}
+	if h.OwnerID == "" {
+		h.OwnerID = v.LegacyOwnerID
+	}
+	return nil
+}
+
+// ParseJSON reads an OPML document from JSON written by json.Marshal
+func ParseJSON(src []byte) (*OPML, error) {
+	o := New()
+	if err := json.Unmarshal(src, o); err != nil {
+		return nil, err
+	}
+	if o.Body == nil {
+		o.Body = new(Body)
+	}
+	return o, nil
+}
@@ -0,0 +1,121 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	fnames, err := filepath.Glob("testdata/*.opml")
+	if err != nil || len(fnames) == 0 {
+		t.Errorf("can't find testdata, %v", err)
+		t.FailNow()
+	}
+	for _, fname := range fnames {
+		o, err := ReadFileWith(fname, ParseOptions{Preserve: true})
+		if err != nil {
+			t.Errorf("%s", err)
+			continue
+		}
+		expected, err := MarshalWith(o, EncodeOptions{Canonical: true})
+		if err != nil {
+			t.Errorf("%s: %s", fname, err)
+			continue
+		}
+		src, err := json.MarshalIndent(o, "", "    ")
+		if err != nil {
+			t.Errorf("%s: %s", fname, err)
+			continue
+		}
+		o2, err := ParseJSON(src)
+		if err != nil {
+			t.Errorf("%s: %s", fname, err)
+			continue
+		}
+		got, err := MarshalWith(o2, EncodeOptions{Canonical: true})
+		if err != nil {
+			t.Errorf("%s: %s", fname, err)
+			continue
+		}
+		if !bytes.Equal(expected, got) {
+			t.Errorf("%s: expected\n%s\ngot\n%s\nfrom\n%s", fname, expected, got, src)
+		}
+	}
+}
+
+func TestCustomAttrsJSON(t *testing.T) {
+	o, err := ReadFile("testdata/annotated.opml")
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	src, err := json.Marshal(o.Body.Outline[0].OtherAttr)
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	expected := `{"http://example.org/team:owner":"alice"}`
+	if string(src) != expected {
+		t.Errorf("expected %s, got %s", expected, src)
+	}
+
+	// Key order is kept, numbers and booleans are accepted
+	var attrs CustomAttrs
+	if err := json.Unmarshal([]byte(`{"zeta":"z","alpha":1,"ok":true,"team:note":"n"}`), &attrs); err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	got := []string{}
+	for _, attr := range attrs {
+		got = append(got, attrKey(attr.Name)+"="+attr.Value)
+	}
+	if s := strings.Join(got, " "); s != "zeta=z alpha=1 ok=true team:note=n" {
+		t.Errorf("unexpected attributes %s", s)
+	}
+	if err := json.Unmarshal([]byte(`{"a":["b"]}`), &attrs); err == nil {
+		t.Errorf("expected an error for an array value")
+	}
+}
+
+func TestHeadJSON(t *testing.T) {
+	h := &Head{Title: "t", Modified: "Mon, 01 Mar 2021 10:00:00 GMT", OwnerID: "http://example.org/me"}
+	src, err := json.Marshal(h)
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	expected := `{"title":"t","dateModified":"Mon, 01 Mar 2021 10:00:00 GMT","ownerId":"http://example.org/me"}`
+	if string(src) != expected {
+		t.Errorf("expected %s, got %s", expected, src)
+	}
+
+	// Keys written by earlier versions
+	h = new(Head)
+	if err := json.Unmarshal([]byte(`{"title":"t","dataModified":"then","OwnerId":"http://example.org/me"}`), h); err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if h.Title != "t" || h.Modified != "then" || h.OwnerID != "http://example.org/me" {
+		t.Errorf("unexpected head %+v", h)
+	}
+}
@@ -0,0 +1,396 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ConflictPolicy decides which value Merge keeps when duplicate
+// outline elements have different values for an attribute
+type ConflictPolicy int
+
+const (
+	// ConflictFirst keeps the value of the first element
+	ConflictFirst ConflictPolicy = iota
+	// ConflictLast keeps the value of the last element
+	ConflictLast
+	// ConflictLongest keeps the longest value, the first if they are
+	// the same length
+	ConflictLongest
+)
+
+func (c ConflictPolicy) String() string {
+	switch c {
+	case ConflictLast:
+		return "last"
+	case ConflictLongest:
+		return "longest"
+	}
+	return "first"
+}
+
+// ParseConflictPolicy returns the policy named "first", "last" or
+// "longest"
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch strings.ToLower(s) {
+	case "first":
+		return ConflictFirst, nil
+	case "last":
+		return ConflictLast, nil
+	case "longest":
+		return ConflictLongest, nil
+	}
+	return ConflictFirst, fmt.Errorf("unknown conflict policy %q, expected first, last or longest", s)
+}
+
+// feedBurnerHosts serve FeedBurner feeds, the first path element is
+// the feed's name
+var feedBurnerHosts = map[string]bool{
+	"feeds.feedburner.com":  true,
+	"feeds2.feedburner.com": true,
+	"feedproxy.google.com":  true,
+}
+
+// defaultPorts are the ports dropped by NormalizeURL
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// NormalizeURL returns a key for comparing feed URLs. The http and
+// https schemes are dropped so they compare equal, the host is lower
+// cased, the default port of the scheme, a trailing slash and the
+// fragment are removed. A URL that can't be parsed is returned trimmed
+// of spaces.
+func NormalizeURL(s string) string {
+	return normalizeURL(s, MergeOptions{})
+}
+
+// normalizeURL returns the key for s following the KeepScheme,
+// KeepHostCase and KeepTrailingSlash options
+func normalizeURL(s string, opts MergeOptions) string {
+	s = strings.TrimSpace(s)
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return s
+	}
+	scheme := strings.ToLower(u.Scheme)
+	host := u.Hostname()
+	if !opts.KeepHostCase {
+		host = strings.ToLower(host)
+	}
+	if port := u.Port(); port != "" && port != defaultPorts[scheme] {
+		host += ":" + port
+	}
+	p := u.EscapedPath()
+	if !opts.KeepTrailingSlash {
+		p = strings.TrimSuffix(p, "/")
+	}
+	key := "//" + host + p
+	if opts.KeepScheme || (scheme != "http" && scheme != "https") {
+		key = scheme + ":" + key
+	}
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+	return key
+}
+
+// MergeOptions control how Merge identifies duplicates and merges them
+type MergeOptions struct {
+	// Policy decides which attribute value is kept when duplicates
+	// disagree
+	Policy ConflictPolicy
+
+	// FeedBurner treats the FeedBurner hosts (feeds.feedburner.com,
+	// feeds2.feedburner.com and feedproxy.google.com) as the same
+	// and ignores the query string, e.g. "?format=xml"
+	FeedBurner bool
+
+	// Aliases maps a feed URL to the URL it redirects to, e.g. a
+	// FeedBurner URL to the publisher's feed. The URLs are compared
+	// the way duplicates are.
+	Aliases map[string]string
+
+	// KeepScheme compares the scheme of URLs, by default http and https
+	// URLs compare equal
+	KeepScheme bool
+
+	// KeepHostCase compares hosts case sensitively
+	KeepHostCase bool
+
+	// KeepTrailingSlash compares a path with a trailing slash as
+	// different from the path without it
+	KeepTrailingSlash bool
+}
+
+// Duplicate is an outline element collapsed into an earlier one
+type Duplicate struct {
+	// Key is the normalized URL shared by the elements
+	Key string `json:"key"`
+	// Source is the index of the document holding the duplicate and
+	// SourcePath its path in that document
+	Source     int         `json:"source"`
+	SourcePath OutlinePath `json:"source_path"`
+	// Kept is the path of the merged element in the result
+	Kept OutlinePath `json:"kept"`
+	Text string      `json:"text,omitempty"`
+	URL  string      `json:"url"`
+}
+
+// Conflict is an attribute with different values in duplicate outline
+// elements
+type Conflict struct {
+	Key     string      `json:"key"`
+	Kept    OutlinePath `json:"kept"`
+	Attr    string      `json:"attr"`
+	Value   string      `json:"value"`
+	Dropped string      `json:"dropped"`
+}
+
+// MergeReport describes what Merge collapsed
+type MergeReport struct {
+	Duplicates []Duplicate `json:"duplicates"`
+	Conflicts  []Conflict  `json:"conflicts"`
+	// Folders is the number of folders merged into a folder with the
+	// same text
+	Folders int `json:"folders"`
+}
+
+// String returns the report as text, a line for each duplicate and
+// conflict followed by a summary
+func (r *MergeReport) String() string {
+	var b strings.Builder
+	for _, d := range r.Duplicates {
+		fmt.Fprintf(&b, "duplicate %s (document %d %s) merged into %s\n", d.URL, d.Source+1, d.SourcePath, d.Kept)
+	}
+	for _, c := range r.Conflicts {
+		fmt.Fprintf(&b, "conflict %s %s: kept %q, dropped %q\n", c.Kept, c.Attr, c.Value, c.Dropped)
+	}
+	fmt.Fprintf(&b, "%d duplicates, %d conflicts, %d folders merged\n", len(r.Duplicates), len(r.Conflicts), r.Folders)
+	return b.String()
+}
+
+type merger struct {
+	opts    MergeOptions
+	aliases map[string]string
+	seen    map[string]*Outline
+	paths   map[string]OutlinePath
+	report  *MergeReport
+}
+
+// feedURL returns the xmlUrl of ol, or its url if it has no xmlUrl
+func feedURL(ol *Outline) string {
+	if ol.XMLURL != "" {
+		return ol.XMLURL
+	}
+	return ol.URL
+}
+
+// key returns the key identifying duplicates of ol, xmlUrl or url if
+// there is no xmlUrl, or "" if ol has neither
+func (m *merger) key(ol *Outline) string {
+	s := feedURL(ol)
+	if s == "" {
+		return ""
+	}
+	key := normalizeURL(s, m.opts)
+	if alias, ok := m.aliases[key]; ok {
+		key = alias
+	}
+	if m.opts.FeedBurner {
+		if u, err := url.Parse(s); err == nil && feedBurnerHosts[strings.ToLower(u.Hostname())] {
+			name := strings.SplitN(strings.Trim(u.Path, "/"), "/", 2)[0]
+			key = "feedburner:" + name
+		}
+	}
+	return key
+}
+
+// copyOutline returns a copy of ol without its children
+func copyOutline(ol *Outline) *Outline {
+	cp := *ol
+	cp.Outline = nil
+	cp.OtherAttr = append(CustomAttrs{}, ol.OtherAttr...)
+	cp.Before = append([]Node{}, ol.Before...)
+	cp.Extra = append([]Node{}, ol.Extra...)
+	cp.Pos = nil
+	return &cp
+}
+
+// mergeAttr keeps a value for one attribute following the policy
+func (m *merger) mergeAttr(key string, p OutlinePath, name string, kept string, val string) (string, bool) {
+	if val == "" || val == kept {
+		return kept, false
+	}
+	if kept == "" {
+		return val, true
+	}
+	use := kept
+	switch m.opts.Policy {
+	case ConflictLast:
+		use = val
+	case ConflictLongest:
+		if len(val) > len(kept) {
+			use = val
+		}
+	}
+	dropped := val
+	if use == val {
+		dropped = kept
+	}
+	m.report.Conflicts = append(m.report.Conflicts, Conflict{Key: key, Kept: p, Attr: name, Value: use, Dropped: dropped})
+	return use, use != kept
+}
+
+// mergeAttrs merges the attributes of dup into ol. The xmlUrl and url
+// identify the feed so the first element's values are always kept.
+func (m *merger) mergeAttrs(key string, p OutlinePath, ol *Outline, dup *Outline) {
+	for _, name := range outlineAttrs {
+		kept, _ := ol.Attr(name)
+		val, _ := dup.Attr(name)
+		if (name == "xmlUrl" || name == "url") && kept != "" {
+			continue
+		}
+		if s, changed := m.mergeAttr(key, p, name, kept, val); changed {
+			ol.SetAttr(name, s)
+		}
+	}
+	for _, attr := range dup.OtherAttr {
+		found := false
+		for i, cur := range ol.OtherAttr {
+			if cur.Name == attr.Name {
+				found = true
+				if s, changed := m.mergeAttr(key, p, attrKey(attr.Name), cur.Value, attr.Value); changed {
+					ol.OtherAttr[i].Value = s
+				}
+				break
+			}
+		}
+		if !found {
+			ol.OtherAttr = append(ol.OtherAttr, attr)
+		}
+	}
+}
+
+// mergeList merges the src outline elements of document doc into dst,
+// the list at p in the result
+func (m *merger) mergeList(dst *[]*Outline, p OutlinePath, src []*Outline, srcPath OutlinePath, doc int) {
+	for i, elem := range src {
+		if elem == nil {
+			continue
+		}
+		sp := srcPath.Child(i + 1)
+		key := m.key(elem)
+		if key != "" {
+			if ol, ok := m.seen[key]; ok {
+				kept := m.paths[key]
+				m.report.Duplicates = append(m.report.Duplicates, Duplicate{
+					Key:        key,
+					Source:     doc,
+					SourcePath: sp,
+					Kept:       kept,
+					Text:       elem.Text,
+					URL:        feedURL(elem),
+				})
+				m.mergeAttrs(key, kept, ol, elem)
+				m.mergeList(&ol.Outline, kept, elem.Outline, sp, doc)
+				continue
+			}
+		} else if len(elem.Outline) > 0 {
+			// Folders with the same text are merged
+			merged := false
+			for j, ol := range *dst {
+				if m.key(ol) == "" && len(ol.Outline) > 0 && strings.EqualFold(ol.Text, elem.Text) {
+					m.report.Folders++
+					m.mergeList(&ol.Outline, p.Child(j+1), elem.Outline, sp, doc)
+					merged = true
+					break
+				}
+			}
+			if merged {
+				continue
+			}
+		}
+		ol := copyOutline(elem)
+		*dst = append(*dst, ol)
+		cur := p.Child(len(*dst))
+		if key != "" {
+			m.seen[key] = ol
+			m.paths[key] = cur
+		}
+		m.mergeList(&ol.Outline, cur, elem.Outline, sp, doc)
+	}
+}
+
+// Merge combines the outlines of docs removing duplicate feeds. Outline
+// elements are duplicates if their xmlUrl (or url if they have no
+// xmlUrl) are the same after NormalizeURL, adjusted by the Keep options,
+// and the aliases in opts. The first element is kept where it is and
+// the attributes of its duplicates are merged into it following
+// opts.Policy. Folders, outline elements with children and no URL, with
+// the same text at the same level are merged. The head of the first document is kept. The
+// documents passed in are not changed.
+func Merge(opts MergeOptions, docs ...*OPML) (*OPML, *MergeReport) {
+	m := &merger{
+		opts:    opts,
+		aliases: map[string]string{},
+		seen:    map[string]*Outline{},
+		paths:   map[string]OutlinePath{},
+		report:  &MergeReport{Duplicates: []Duplicate{}, Conflicts: []Conflict{}},
+	}
+	for k, v := range opts.Aliases {
+		m.aliases[normalizeURL(k, opts)] = normalizeURL(v, opts)
+	}
+	result := New()
+	for i, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		if i == 0 {
+			if doc.Head != nil {
+				head := *doc.Head
+				result.Head = &head
+			}
+			if doc.Body != nil {
+				result.Body.OtherAttr = append(CustomAttrs{}, doc.Body.OtherAttr...)
+			}
+		}
+		// Keep namespace declarations and other attributes
+		for _, attr := range doc.OtherAttr {
+			found := false
+			for _, cur := range result.OtherAttr {
+				if cur.Name == attr.Name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				result.OtherAttr = append(result.OtherAttr, xml.Attr{Name: attr.Name, Value: attr.Value})
+			}
+		}
+		if doc.Body != nil {
+			m.mergeList(&result.Body.Outline, OutlinePath{}, doc.Body.Outline, OutlinePath{}, i)
+		}
+	}
+	return result, m.report
+}
@@ -0,0 +1,128 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"testing"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	expected := map[string]string{
+		"http://Example.org/feed/":         "//example.org/feed",
+		"https://example.org/feed":         "//example.org/feed",
+		"http://example.org:80/feed#top":   "//example.org/feed",
+		"http://example.org:8080/feed?x=1": "//example.org:8080/feed?x=1",
+		"  feed.xml ":                      "feed.xml",
+		"http://example.org:443/feed":      "//example.org:443/feed",
+		"https://example.org:443/feed":     "//example.org/feed",
+		"ftp://example.org/feed/":          "ftp://example.org/feed",
+	}
+	for src, key := range expected {
+		if s := NormalizeURL(src); s != key {
+			t.Errorf("NormalizeURL(%q) expected %q, got %q", src, key, s)
+		}
+	}
+
+	opts := MergeOptions{KeepScheme: true, KeepHostCase: true, KeepTrailingSlash: true}
+	expected = map[string]string{
+		"http://Example.org/feed/":         "http://Example.org/feed/",
+		"https://example.org:443/feed#x":   "https://example.org/feed",
+		"http://example.org:8080/feed?x=1": "http://example.org:8080/feed?x=1",
+	}
+	for src, key := range expected {
+		if s := normalizeURL(src, opts); s != key {
+			t.Errorf("normalizeURL(%q, %+v) expected %q, got %q", src, opts, key, s)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, err := Parse([]byte(`<opml version="2.0"><head><title>A</title></head><body>
+<outline text="News">
+  <outline text="Example" xmlUrl="http://example.org/feed/" />
+  <outline text="Other" xmlUrl="http://other.example.org/rss" />
+</outline>
+<outline text="Burner" xmlUrl="http://feeds.feedburner.com/Burner" />
+</body></opml>`))
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	b, err := Parse([]byte(`<opml version="2.0"><head><title>B</title></head><body>
+<outline text="news">
+  <outline text="Example Feed" xmlUrl="https://EXAMPLE.org/feed" htmlUrl="https://example.org/" />
+  <outline text="Third" xmlUrl="http://third.example.org/rss" />
+</outline>
+<outline text="Burner" xmlUrl="http://feedproxy.google.com/Burner?format=xml" />
+</body></opml>`))
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+
+	o, report := Merge(MergeOptions{FeedBurner: true}, a, b)
+	expected := `/1 News
+/1/1 Example
+/1/2 Other
+/1/3 Third
+/2 Burner`
+	if s := outlineText(o); s != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, s)
+	}
+	if o.Head.Title != "A" {
+		t.Errorf("expected head of first document, got %q", o.Head.Title)
+	}
+	if len(report.Duplicates) != 2 || report.Folders != 1 {
+		t.Errorf("expected 2 duplicates and 1 folder, got %+v", report)
+	}
+	if len(report.Conflicts) != 1 || report.Conflicts[0].Attr != "text" || report.Conflicts[0].Dropped != "Example Feed" {
+		t.Errorf("expected a text conflict, got %+v", report.Conflicts)
+	}
+	ol, _ := o.Get(OutlinePath{1, 1})
+	if ol.HTMLURL != "https://example.org/" {
+		t.Errorf("expected htmlUrl from the duplicate, got %q", ol.HTMLURL)
+	}
+	// The documents merged are not changed
+	if len(a.Body.Outline[0].Outline) != 2 {
+		t.Errorf("expected first document to be unchanged")
+	}
+
+	// Without FeedBurner the burner feeds differ
+	o, _ = Merge(MergeOptions{}, a, b)
+	if len(o.Body.Outline) != 3 {
+		t.Errorf("expected 3 top level outlines, got %d", len(o.Body.Outline))
+	}
+
+	// Policies
+	for policy, text := range map[ConflictPolicy]string{ConflictFirst: "Example", ConflictLast: "Example Feed", ConflictLongest: "Example Feed"} {
+		o, _ = Merge(MergeOptions{Policy: policy}, a, b)
+		if ol, _ := o.Get(OutlinePath{1, 1}); ol.Text != text {
+			t.Errorf("%s expected %q, got %q", policy, text, ol.Text)
+		}
+	}
+
+	// Aliases
+	o, report = Merge(MergeOptions{Aliases: map[string]string{"http://third.example.org/rss": "http://other.example.org/rss"}}, a, b)
+	if ol, _ := o.Get(OutlinePath{1}); len(ol.Outline) != 2 || len(report.Duplicates) != 2 {
+		t.Errorf("expected alias to be merged, got\n%s", outlineText(o))
+	}
+
+	if _, err := ParseConflictPolicy("newest"); err == nil {
+		t.Errorf("expected an error for an unknown policy")
+	}
+}
@@ -18,7 +18,6 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package opml
 
 import (
-	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io/ioutil"
@@ -27,21 +26,9 @@ import (
 	"strings"
 )
 
+// CustomAttrs holds the attributes not defined by the OPML spec
 type CustomAttrs []xml.Attr
 
-func (cattr CustomAttrs) MarshalJSON() ([]byte, error) {
-	m := map[string]string{}
-	for _, attr := range cattr {
-		k := attr.Name.Local
-		v := attr.Value
-		if k != "" {
-			m[k] = v
-		}
-	}
-
-	return json.Marshal(m)
-}
-
 // OPML is the root structure for holding an OPML document
 type OPML struct {
 	XMLName   xml.Name    `xml:"opml" json:"-"`
@@ -49,17 +36,24 @@ type OPML struct {
 	Head      *Head       `xml:"head" json:"head"`
 	Body      *Body       `xml:"body" json:"body"`
 	OtherAttr CustomAttrs `xml:",any,attr" json:"other_attrs,omitempty"`
+
+	// Prolog, Extra and Epilog hold the nodes before, in and after the
+	// opml element, they are set by ParseWith when
+	// ParseOptions.Preserve is true
+	Prolog []Node `xml:"-" json:"prolog,omitempty"`
+	Extra  []Node `xml:"-" json:"extra,omitempty"`
+	Epilog []Node `xml:"-" json:"epilog,omitempty"`
 }
 
 // Head holds the metadata for an OPML document
 type Head struct {
 	XMLName         xml.Name    `json:"-"`
 	Title           string      `xml:"title,omitempty" json:"title,omitempty"`
-	Created         string      `xml:"dateCreated,omitempty" json:"dateCreated,omitempty"`   // RFC 882 date and time
-	Modified        string      `xml:"dateModified,omitempty" json:"dataModified,omitempty"` // RFC 882 date and time
+	Created         string      `xml:"dateCreated,omitempty" json:"dateCreated,omitempty"`   // RFC 822 date and time
+	Modified        string      `xml:"dateModified,omitempty" json:"dateModified,omitempty"` // RFC 822 date and time
 	OwnerName       string      `xml:"ownerName,omitempty" json:"ownerName,omitempty"`
 	OwnerEmail      string      `xml:"ownerEmail,omitempty" json:"ownerEmail,omitempty"`
-	OwnerID         string      `xml:"OwnerId,omitempty" json:"OwnerId,omitempty"`               // url
+	OwnerID         string      `xml:"ownerId,omitempty" json:"ownerId,omitempty"`               // url
 	Docs            string      `xml:"docs,omitempty" json:"docs,omitempty"`                     // url
 	ExpansionState  string      `xml:"expansionState,omitempty" json:"expansionState,omitempty"` // array of numbers
 	VertScrollState int         `xml:"vertScrollState,omitempty" json:"vertScrollState,omitempty"`
@@ -68,6 +62,9 @@ type Head struct {
 	WindowBottom    int         `xml:"windowBottom,omitempty" json:"windowBottom,omitempty"`
 	WindowRight     int         `xml:"windowRight,omitempty" json:"windowRight,omitempty"`
 	OtherAttr       CustomAttrs `xml:",any,attr" json:"other_attrs,omitempty"`
+
+	// Extra holds the nodes in the head kept by ParseWith
+	Extra []Node `xml:"-" json:"extra,omitempty"`
 }
 
 // Body holds the outline for an OPML document
@@ -75,6 +72,10 @@ type Body struct {
 	XMLName   xml.Name    `json:"-"`
 	Outline   []*Outline `xml:"outline" json:"outline"`
 	OtherAttr CustomAttrs `xml:",any,attr" json:"other_attrs,omitempty"`
+
+	// Extra holds the nodes kept by ParseWith that follow the last
+	// outline element in the body
+	Extra []Node `xml:"-" json:"extra,omitempty"`
 }
 
 // Outline is the primary element of an OPML document, may hold sub-Outlines
@@ -85,18 +86,37 @@ type Outline struct {
 	Title        string      `xml:"title,attr,omitempty" json:"title,omitempty"`
 	IsComment    bool        `xml:"isComment,attr,omitempty" json:"isComment,omitempty"`
 	IsBreakpoint bool        `xml:"isBreakpoint,attr,omitempty" json:"isBreakpoint,omitempty"`
-	Created      string      `xml:"created,attr,omitempty" json:"created,omitempty"` // RFC 882 date and time
+	Created      string      `xml:"created,attr,omitempty" json:"created,omitempty"` // RFC 822 date and time
 	Category     string      `xml:"category,attr,omitempty" json:"category,omitempty"`
 	XMLURL       string      `xml:"xmlUrl,attr,omitempty" json:"xmlUrl,omitempty"`   // url
 	HTMLURL      string      `xml:"htmlUrl,attr,omitempty" json:"htmlUrl,omitempty"` // url
-	Language     string      `xml:"langauge,attr,omitempty" json:"language,omitempty"`
+	Language     string      `xml:"language,attr,omitempty" json:"language,omitempty"`
 	Description  string      `xml:"description,attr,omitempty" json:"description,omitempty"`
 	Version      string      `xml:"version,attr,omitempty" json:"version,omitempty"`
 	URL          string      `xml:"url,attr,omitempty" json:"url,omitempty"` // url
 	Outline      []*Outline `xml:"outline,omitempty" json:"outline,omitempty"`
 	OtherAttr    CustomAttrs `xml:",any,attr" json:"other_attrs,omitempty"`
+
+	// Pos is the source position of the outline element, it is only
+	// set by ParseWith and ReadFileWith when ParseOptions.Positions is true
+	Pos *Position `xml:"-" json:"-"`
+
+	// Before holds the nodes kept by ParseWith that precede the outline
+	// element in its parent, they move with the outline when it is
+	// sorted, moved or copied
+	Before []Node `xml:"-" json:"before,omitempty"`
+
+	// Extra holds the nodes kept by ParseWith that follow the last
+	// child of the outline element
+	Extra []Node `xml:"-" json:"extra,omitempty"`
 }
 
+// ByText, ByTextCaseInsensitive, ByType, ByTitle and
+// ByTitleCaseInsensitive implement sort.Interface for a list of outline
+// elements.
+//
+// Deprecated: use SortBy or SortOutlines which sort by several keys and
+// are stable.
 type ByText []*Outline
 type ByTextCaseInsensitive []*Outline
 type ByType []*Outline
@@ -119,6 +139,25 @@ func (h *Head) String() string {
 	return string(s)
 }
 
+// UnmarshalXML decodes a head element. It also accepts "OwnerId" for
+// ownerId, written by earlier versions of this package.
+func (h *Head) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	// Head is renamed so DecodeElement doesn't call this method
+	type Elem Head
+	v := struct {
+		Elem
+		LegacyOwnerID string `xml:"OwnerId"`
+	}{}
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	*h = Head(v.Elem)
+	if h.OwnerID == "" {
+		h.OwnerID = v.LegacyOwnerID
+	}
+	return nil
+}
+
 func (b *Body) String() string {
 	s, _ := xml.Marshal(b)
 	return string(s)
@@ -137,6 +176,52 @@ func (ol *Outline) HasChildren() bool {
 	return false
 }
 
+// Attr returns the value of the named outline attribute and true if it
+// is set. Names are the OPML attribute names (e.g. "text", "xmlUrl"),
+// any other name is looked up in OtherAttr.
+func (ol *Outline) Attr(name string) (string, bool) {
+	var s string
+	switch name {
+	case "text":
+		s = ol.Text
+	case "type":
+		s = ol.Type
+	case "title":
+		s = ol.Title
+	case "isComment":
+		if ol.IsComment {
+			s = "true"
+		}
+	case "isBreakpoint":
+		if ol.IsBreakpoint {
+			s = "true"
+		}
+	case "created":
+		s = ol.Created
+	case "category":
+		s = ol.Category
+	case "xmlUrl":
+		s = ol.XMLURL
+	case "htmlUrl":
+		s = ol.HTMLURL
+	case "language":
+		s = ol.Language
+	case "description":
+		s = ol.Description
+	case "version":
+		s = ol.Version
+	case "url":
+		s = ol.URL
+	default:
+		for _, attr := range ol.OtherAttr {
+			if attr.Name.Local == name || (attr.Name.Space != "" && attr.Name.Space+":"+attr.Name.Local == name) {
+				return attr.Value, true
+			}
+		}
+		return "", false
+	}
+	return s, s != ""
+}
 
 // Append one or more Body.Outline lists to the current OPML structure
 func (o *OPML) Append(outlines ...*OPML) error {
@@ -275,36 +360,24 @@ func (a ByTitleCaseInsensitive) Sort() {
 	}
 }
 
-// Sort do a recursive ByText sort of outline elements starting at the OPML struct.
+// Sort do a recursive sort by text of outline elements starting at the OPML struct.
 func (o *OPML) Sort() {
-	if o.Body != nil && len(o.Body.Outline) > 0 {
-		ol := ByText(o.Body.Outline)
-		ol.Sort()
-	}
+	o.SortBy(SortOptions{}, SortKey{Name: "text"})
 }
 
-// SortCaseInsensitive do a recursive ByTextCaseInsensitive sort of outline elements starting at the OPML struct.
+// SortCaseInsensitive do a recursive case insensitive sort by text of outline elements starting at the OPML struct.
 func (o *OPML) SortCaseInsensitive() {
-	if o.Body != nil && len(o.Body.Outline) > 0 {
-		ol := ByTextCaseInsensitive(o.Body.Outline)
-		ol.Sort()
-	}
+	o.SortBy(SortOptions{}, SortKey{Name: "text", CaseInsensitive: true})
 }
 
-// SortTitle do a recusive ByTitle sort of outline elements starting at the OMPL struct
+// SortTitle do a recusive sort by title of outline elements starting at the OMPL struct
 func (o *OPML) SortTitle() {
-	if o.Body != nil && len(o.Body.Outline) > 0 {
-		ol := ByTitle(o.Body.Outline)
-		ol.Sort()
-	}
+	o.SortBy(SortOptions{}, SortKey{Name: "title"})
 }
 
-// SortTitleCaseInsensitive do a recusive ByTitleCaseInsensitive sort of outline elements starting at the OMPL struct
+// SortTitleCaseInsensitive do a recusive case insensitive sort by title of outline elements starting at the OMPL struct
 func (o *OPML) SortTitleCaseInsensitive() {
-	if o.Body != nil && len(o.Body.Outline) > 0 {
-		ol := ByTitleCaseInsensitive(o.Body.Outline)
-		ol.Sort()
-	}
+	o.SortBy(SortOptions{}, SortKey{Name: "title", CaseInsensitive: true})
 }
 
 // Len for ByType sort of Outline
@@ -335,17 +408,16 @@ func (a ByType) Sort() {
 	}
 }
 
-// SortTypes do a recursive ByText sort of outline elements starting at the OPML struct.
+// SortTypes do a recursive sort by type of outline elements starting at the OPML struct.
 func (o *OPML) SortTypes() {
-	if o.Body != nil && len(o.Body.Outline) > 0 {
-		ol := ByType(o.Body.Outline)
-		ol.Sort()
-	}
+	o.SortBy(SortOptions{}, SortKey{Name: "type"})
 }
 
 // Parse reads a []byte and returns a OMPL object and error
 func Parse(src []byte) (*OPML, error) {
 	o := New()
+	// Version is left empty if the document has no version attribute
+	o.Version = ""
 	err := xml.Unmarshal(src, &o)
 	return o, err
 }
@@ -363,15 +435,18 @@ func (o *OPML) ReadFile(s string) error {
 	if err != nil {
 		return err
 	}
+	// Version is left empty if the document has no version attribute
+	o.Version = ""
 	return xml.Unmarshal(src, &o)
 }
 
-// WriteFile writes the contents of a OPML struct to a file
+// WriteFile writes the contents of a OPML struct to a file starting
+// with the XML declaration
 func (o *OPML) WriteFile(s string, perm os.FileMode) error {
-	if len(o.Body.Outline) == 0 {
-		o.Body.Outline = []*Outline{}
+	b, err := MarshalWith(o, EncodeOptions{Declaration: true})
+	if err != nil {
+		return err
 	}
-	b, _ := xml.Marshal(o)
 	return ioutil.WriteFile(s, b, perm)
 }
 
@@ -384,33 +459,3 @@ func Marshal(o *OPML) ([]byte, error) {
 func Unmarshal(src []byte, o *OPML) error {
 	return xml.Unmarshal(src, &o)
 }
-
-func walk(ol *Outline, fn func(*Outline) bool) bool {
-	if ol == nil {
-		return false
-	}
-	ok := fn(ol)
-	if ol.Outline != nil {
-		for _, elem := range ol.Outline {
-
-			return walk(elem, fn)
-		}
-	}
-	return ok
-}
-
-// Walk does a depth first walk of an outline, stops if function
-// return false.
-func (o *OPML) Walk(fn func(*Outline) bool) error {
-	if o.Body.Outline != nil && len(o.Body.Outline) > 0 {
-		for _, elem := range o.Body.Outline {
-			if ok := walk(elem, fn); ! ok {
-				break
-			}
-		}
-	} else {
-		return fmt.Errorf("outline is empty")
-	}
-	return nil
-}
-
@@ -93,7 +93,7 @@ func TestWrite(t *testing.T) {
 		t.Errorf("%s", err)
 	}
 
-	s := []byte(o.String())
+	s := []byte(Declaration + "\n" + o.String())
 	src, err := ioutil.ReadFile(fname)
 	if err != nil {
 		t.Errorf("%s", err)
@@ -161,9 +161,15 @@ func TestAppend(t *testing.T) {
 	}
 }
 
-/*
 func TestFilterForTypes(t *testing.T) {
-	t.Errorf("Filter for types not implemented")
-	t.FailNow()
+	o, err := ReadFile("testdata/example2.opml")
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	result := o.Filter(MustCompileQuery(`type = include`))
+	expected := `<body><outline text="Places of interest"><outline text="Victoria, BC" type="include" url="http://api.example.org/janedoe/victoria-bc.opml"></outline></outline></body>`
+	if s := result.String(); strings.Contains(s, expected) == false {
+		t.Errorf("expected %s in %s", expected, s)
+	}
 }
-*/
@@ -0,0 +1,373 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// Patch holds the changes that turn one document into another. Outline
+// elements are found by key, not path, so a patch can be applied to a
+// copy of the document that has been edited. It is written as JSON by
+// json.Marshal and read by ParsePatch.
+type Patch struct {
+	// Keys are the keys used to identify outline elements, see
+	// DiffOptions
+	Keys    []string `json:"keys"`
+	Changes Changes  `json:"changes"`
+}
+
+// PatchConflict is a change that couldn't be applied
+type PatchConflict struct {
+	Change Change `json:"change"`
+	Reason string `json:"reason"`
+}
+
+func (c PatchConflict) String() string {
+	return fmt.Sprintf("%s: %s", c.Change, c.Reason)
+}
+
+// PatchError is returned by Apply when changes conflict with the
+// document
+type PatchError struct {
+	Conflicts []PatchConflict
+}
+
+func (e *PatchError) Error() string {
+	l := []string{}
+	for _, c := range e.Conflicts {
+		l = append(l, c.String())
+	}
+	return fmt.Sprintf("%d conflicting changes: %s", len(e.Conflicts), strings.Join(l, "; "))
+}
+
+// NewPatch returns a patch holding the changes from a to b
+func NewPatch(a *OPML, b *OPML, opts DiffOptions) *Patch {
+	keys := opts.Keys
+	if len(keys) == 0 {
+		keys = DefaultDiffKeys
+	}
+	return &Patch{Keys: keys, Changes: Diff(a, b, DiffOptions{Keys: keys})}
+}
+
+// ParsePatch reads a patch from JSON
+func ParsePatch(src []byte) (*Patch, error) {
+	p := new(Patch)
+	if err := json.Unmarshal(src, p); err != nil {
+		return nil, err
+	}
+	if len(p.Keys) == 0 {
+		p.Keys = DefaultDiffKeys
+	}
+	return p, nil
+}
+
+// cloneOutline returns a copy of ol and its children
+func cloneOutline(ol *Outline) *Outline {
+	cp := copyOutline(ol)
+	for _, child := range ol.Outline {
+		if child != nil {
+			cp.Outline = append(cp.Outline, cloneOutline(child))
+		}
+	}
+	return cp
+}
+
+// clone returns a copy of o that shares no outline elements with it
+func clone(o *OPML) *OPML {
+	cp := *o
+	if o.Head != nil {
+		head := *o.Head
+		cp.Head = &head
+	}
+	cp.Body = new(Body)
+	if o.Body != nil {
+		*cp.Body = *o.Body
+		cp.Body.Outline = nil
+		for _, ol := range o.Body.Outline {
+			if ol != nil {
+				cp.Body.Outline = append(cp.Body.Outline, cloneOutline(ol))
+			}
+		}
+	}
+	return &cp
+}
+
+// setHead sets the named head element, see headFields
+func setHead(h *Head, name string, value string) {
+	switch name {
+	case "title":
+		h.Title = value
+	case "dateCreated":
+		h.Created = value
+	case "dateModified":
+		h.Modified = value
+	case "ownerName":
+		h.OwnerName = value
+	case "ownerEmail":
+		h.OwnerEmail = value
+	case "ownerId":
+		h.OwnerID = value
+	case "docs":
+		h.Docs = value
+	}
+}
+
+// setAttrKey sets the attribute named by key, as returned by attrKey,
+// an empty value removes it
+func setAttrKey(ol *Outline, key string, value string) error {
+	if isSpecAttr(key) {
+		return ol.SetAttr(key, value)
+	}
+	for i, attr := range ol.OtherAttr {
+		if attrKey(attr.Name) == key {
+			if value == "" {
+				ol.OtherAttr = append(ol.OtherAttr[:i], ol.OtherAttr[i+1:]...)
+			} else {
+				ol.OtherAttr[i].Value = value
+			}
+			return nil
+		}
+	}
+	if value != "" {
+		name := xml.Name{Local: key}
+		if i := strings.LastIndex(key, ":"); i > 0 {
+			name = xml.Name{Space: key[0:i], Local: key[i+1:]}
+		}
+		ol.OtherAttr = append(ol.OtherAttr, xml.Attr{Name: name, Value: value})
+	}
+	return nil
+}
+
+// patcher applies changes to a document
+type patcher struct {
+	o         *OPML
+	index     *diffIndex
+	found     map[string]*Outline
+	conflicts []PatchConflict
+}
+
+func (pt *patcher) conflict(c Change, format string, args ...interface{}) {
+	pt.conflicts = append(pt.conflicts, PatchConflict{Change: c, Reason: fmt.Sprintf(format, args...)})
+}
+
+// setAttrs applies the attribute changes, an attribute whose value is
+// neither the old nor the new value is a conflict
+func (pt *patcher) setAttrs(c Change, ol *Outline) {
+	for _, a := range c.Attrs {
+		cur, _ := ol.Attr(a.Name)
+		switch cur {
+		case a.New:
+		case a.Old:
+			if err := setAttrKey(ol, a.Name, a.New); err != nil {
+				pt.conflict(c, "%s", err)
+			}
+		default:
+			pt.conflict(c, "%s is %q, expected %q", a.Name, cur, a.Old)
+		}
+	}
+}
+
+// insert adds ol to the children of the parent element after the
+// sibling named by the change
+func (pt *patcher) insert(c Change, ol *Outline) {
+	list := &pt.o.Body.Outline
+	if c.Parent != "" {
+		parent, ok := pt.found[c.Parent]
+		if !ok {
+			pt.conflict(c, "parent %s not found", c.Parent)
+			return
+		}
+		list = &parent.Outline
+	}
+	i := 0
+	if c.After != "" {
+		i = len(*list)
+		if after, ok := pt.found[c.After]; ok {
+			for j, sibling := range *list {
+				if sibling == after {
+					i = j + 1
+					break
+				}
+			}
+		}
+	}
+	*list = append(*list, nil)
+	copy((*list)[i+1:], (*list)[i:])
+	(*list)[i] = ol
+	pt.found[c.Key] = ol
+}
+
+// prune removes the outline elements in detached from list and the
+// children of the elements left
+func prune(list []*Outline, detached map[*Outline]bool) []*Outline {
+	out := []*Outline{}
+	for _, ol := range list {
+		if ol == nil || detached[ol] {
+			continue
+		}
+		ol.Outline = prune(ol.Outline, detached)
+		out = append(out, ol)
+	}
+	return out
+}
+
+// apply applies the changes it can to pt.o recording the others as
+// conflicts. Head and attribute changes are made first, then removed
+// and moved elements are taken out of the outline and finally added and
+// moved elements are inserted in the order of the changes.
+func (pt *patcher) apply(changes Changes) {
+	if pt.o.Head == nil {
+		pt.o.Head = new(Head)
+	}
+	if pt.o.Body == nil {
+		pt.o.Body = new(Body)
+	}
+	for _, n := range pt.index.nodes {
+		pt.found[n.key] = n.ol
+	}
+	detached := map[*Outline]bool{}
+	moved := map[string]*Outline{}
+	removed := []*Outline{}
+	removals := map[*Outline]Change{}
+	for _, c := range changes {
+		switch c.Kind {
+		case ChangeHead:
+			fields := map[string]string{}
+			for _, field := range headFields(pt.o.Head) {
+				fields[field[0]] = field[1]
+			}
+			for _, a := range c.Attrs {
+				switch fields[a.Name] {
+				case a.New:
+				case a.Old:
+					setHead(pt.o.Head, a.Name, a.New)
+				default:
+					pt.conflict(c, "head %s is %q, expected %q", a.Name, fields[a.Name], a.Old)
+				}
+			}
+		case ChangeRemove:
+			n, ok := pt.index.byKey[c.Key]
+			if !ok {
+				// Already removed
+				continue
+			}
+			if c.Old != nil && len(attrChanges(c.Old, n.ol)) > 0 {
+				pt.conflict(c, "changed since the patch was made")
+				continue
+			}
+			removed = append(removed, n.ol)
+			removals[n.ol] = c
+			detached[n.ol] = true
+		case ChangeMove, ChangeAttr:
+			n, ok := pt.index.byKey[c.Key]
+			if !ok {
+				pt.conflict(c, "not found")
+				continue
+			}
+			pt.setAttrs(c, n.ol)
+			if c.Kind == ChangeMove {
+				if n.parent != c.OldParent && n.parent != c.Parent {
+					pt.conflict(c, "moved since the patch was made")
+					continue
+				}
+				moved[c.Key] = n.ol
+				detached[n.ol] = true
+			}
+		}
+	}
+	// A removed element keeps children that aren't in the patch,
+	// children are checked before their parents
+	for i := len(removed) - 1; i >= 0; i-- {
+		ol := removed[i]
+		for _, child := range ol.Outline {
+			if child != nil && !detached[child] {
+				pt.conflict(removals[ol], "has children that aren't removed")
+				delete(detached, ol)
+				break
+			}
+		}
+	}
+	for ol := range detached {
+		ol.Outline = prune(ol.Outline, detached)
+	}
+	pt.o.Body.Outline = prune(pt.o.Body.Outline, detached)
+	for _, ol := range removed {
+		if detached[ol] {
+			delete(pt.found, removals[ol].Key)
+		}
+	}
+
+	for _, c := range changes {
+		switch c.Kind {
+		case ChangeAdd:
+			if ol, ok := pt.found[c.Key]; ok {
+				if c.New != nil && len(attrChanges(c.New, ol)) > 0 {
+					pt.conflict(c, "already exists with different attributes")
+				}
+				continue
+			}
+			ol := new(Outline)
+			if c.New != nil {
+				ol = copyOutline(c.New)
+			}
+			pt.insert(c, ol)
+		case ChangeMove:
+			if ol, ok := moved[c.Key]; ok {
+				pt.insert(c, ol)
+			}
+		}
+	}
+}
+
+// Apply makes the changes in the patch to o. If a change conflicts with
+// o, e.g. an attribute doesn't have the value it had when the patch was
+// made or an element has been removed, o is left unchanged and a
+// *PatchError listing the conflicts is returned.
+func (p *Patch) Apply(o *OPML) error {
+	keys := p.Keys
+	if len(keys) == 0 {
+		keys = DefaultDiffKeys
+	}
+	cp := clone(o)
+	pt := &patcher{o: cp, index: newDiffIndex(cp, keys), found: map[string]*Outline{}}
+	pt.apply(p.Changes)
+	if len(pt.conflicts) > 0 {
+		return &PatchError{Conflicts: pt.conflicts}
+	}
+	o.Head, o.Body = cp.Head, cp.Body
+	return nil
+}
+
+// Merge3 merges the changes made to base in ours and theirs. The
+// changes from base to theirs are applied to a copy of ours, changes
+// that conflict with ours are skipped, keeping ours, and returned as
+// conflicts. The documents passed in are not changed.
+func Merge3(base *OPML, ours *OPML, theirs *OPML, opts DiffOptions) (*OPML, []PatchConflict) {
+	p := NewPatch(base, theirs, opts)
+	result := clone(ours)
+	pt := &patcher{o: result, index: newDiffIndex(result, p.Keys), found: map[string]*Outline{}}
+	pt.apply(p.Changes)
+	if pt.conflicts == nil {
+		pt.conflicts = []PatchConflict{}
+	}
+	return result, pt.conflicts
+}
@@ -0,0 +1,111 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPatch(t *testing.T) {
+	a, err := Parse([]byte(diffOld))
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	b, err := Parse([]byte(diffNew))
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	src, err := json.Marshal(NewPatch(a, b, DiffOptions{}))
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	p, err := ParsePatch(src)
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	expected := outlineText(b)
+	if err := p.Apply(a); err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if s := outlineText(a); s != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, s)
+	}
+	if changes := Diff(a, b, DiffOptions{}); len(changes) != 0 {
+		t.Errorf("expected no changes after Apply, got\n%s", changes)
+	}
+	// Applying it again changes nothing
+	if err := p.Apply(a); err != nil {
+		t.Errorf("%s", err)
+	}
+
+	// A conflict leaves the document unchanged
+	a, _ = Parse([]byte(diffOld))
+	a.Body.Outline[0].Outline[0].Text = "Edited"
+	before := outlineText(a)
+	err = p.Apply(a)
+	var perr *PatchError
+	if !errors.As(err, &perr) || len(perr.Conflicts) != 1 {
+		t.Errorf("expected one conflict, got %v", err)
+	}
+	if s := outlineText(a); s != before {
+		t.Errorf("expected document unchanged, got\n%s", s)
+	}
+}
+
+func TestMerge3(t *testing.T) {
+	base, _ := Parse([]byte(`<opml version="2.0"><head><title>Feeds</title></head><body>
+<outline text="One" xmlUrl="http://one.example.org/rss" />
+<outline text="Two" xmlUrl="http://two.example.org/rss" />
+<outline text="Three" xmlUrl="http://three.example.org/rss" />
+</body></opml>`))
+	ours, _ := Parse([]byte(`<opml version="2.0"><head><title>Feeds</title></head><body>
+<outline text="One" xmlUrl="http://one.example.org/rss" />
+<outline text="Two (ours)" xmlUrl="http://two.example.org/rss" />
+<outline text="Three" xmlUrl="http://three.example.org/rss" />
+<outline text="Four" xmlUrl="http://four.example.org/rss" />
+</body></opml>`))
+	theirs, _ := Parse([]byte(`<opml version="2.0"><head><title>Our feeds</title></head><body>
+<outline text="Two (theirs)" xmlUrl="http://two.example.org/rss" />
+<outline text="Three" xmlUrl="http://three.example.org/rss" />
+<outline text="Five" xmlUrl="http://five.example.org/rss" />
+</body></opml>`))
+	o, conflicts := Merge3(base, ours, theirs, DiffOptions{})
+	expected := `/1 Two (ours)
+/2 Three
+/3 Five
+/4 Four`
+	if s := outlineText(o); s != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, s)
+	}
+	if o.Head.Title != "Our feeds" {
+		t.Errorf("expected their title, got %q", o.Head.Title)
+	}
+	if len(conflicts) != 1 || conflicts[0].Change.Key != "xmlUrl=//two.example.org/rss" {
+		t.Errorf("expected a conflict for Two, got %+v", conflicts)
+	}
+	if len(ours.Body.Outline) != 4 || ours.Body.Outline[1].Text != "Two (ours)" {
+		t.Errorf("expected ours to be unchanged")
+	}
+}
@@ -0,0 +1,247 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrMalformedPath is returned when an outline path can't be parsed
+	ErrMalformedPath = errors.New("malformed outline path")
+
+	// ErrPathOutOfRange is returned when an outline path does not
+	// point at an outline element in the document
+	ErrPathOutOfRange = errors.New("outline path out of range")
+
+	// ErrNotFound is returned by Find when no outline element matches
+	ErrNotFound = errors.New("outline not found")
+)
+
+// PathError records the operation and path that caused an error
+type PathError struct {
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Op, e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error, e.g. ErrMalformedPath
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// OutlinePath addresses an outline element by position. Each
+// element is a one based index into an outline list starting
+// at Body.Outline, e.g. "/3/2" is the second child of the third
+// outline in the body. An empty path is the root (the body).
+type OutlinePath []int
+
+// ParsePath parses a path like "/3/2" into an OutlinePath. An
+// empty string or "/" is the root path.
+func ParsePath(s string) (OutlinePath, error) {
+	p := OutlinePath{}
+	for _, part := range strings.Split(strings.Trim(s, "/"), "/") {
+		if part == "" {
+			if len(p) > 0 {
+				return nil, &PathError{Op: "parse", Path: s, Err: ErrMalformedPath}
+			}
+			continue
+		}
+		i, err := strconv.Atoi(part)
+		if err != nil || i < 1 {
+			return nil, &PathError{Op: "parse", Path: s, Err: ErrMalformedPath}
+		}
+		p = append(p, i)
+	}
+	return p, nil
+}
+
+// String returns the path in "/3/2" form, the root is "/"
+func (p OutlinePath) String() string {
+	if len(p) == 0 {
+		return "/"
+	}
+	parts := make([]string, len(p))
+	for i, n := range p {
+		parts[i] = strconv.Itoa(n)
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// MarshalText writes the path in "/3/2" form, e.g. for JSON
+func (p OutlinePath) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText parses a path in "/3/2" form
+func (p *OutlinePath) UnmarshalText(src []byte) error {
+	q, err := ParsePath(string(src))
+	if err != nil {
+		return err
+	}
+	*p = q
+	return nil
+}
+
+// IsRoot returns true if the path points at the root of the outline
+func (p OutlinePath) IsRoot() bool {
+	return len(p) == 0
+}
+
+// Parent returns the path of the parent element, the parent of
+// the root is the root.
+func (p OutlinePath) Parent() OutlinePath {
+	if len(p) == 0 {
+		return OutlinePath{}
+	}
+	return append(OutlinePath{}, p[:len(p)-1]...)
+}
+
+// Child returns a new path for the i-th (one based) child of p
+func (p OutlinePath) Child(i int) OutlinePath {
+	return append(append(OutlinePath{}, p...), i)
+}
+
+// list returns a pointer to the outline list the path's children live in.
+// The root path returns the body's outline list.
+func (o *OPML) list(op string, p OutlinePath) (*[]*Outline, error) {
+	if o.Body == nil {
+		o.Body = new(Body)
+	}
+	l := &o.Body.Outline
+	for _, i := range p {
+		if i < 1 || i > len(*l) {
+			return nil, &PathError{Op: op, Path: p.String(), Err: ErrPathOutOfRange}
+		}
+		l = &(*l)[i-1].Outline
+	}
+	return l, nil
+}
+
+// Get returns the outline element at path p
+func (o *OPML) Get(p OutlinePath) (*Outline, error) {
+	if p.IsRoot() {
+		return nil, &PathError{Op: "get", Path: p.String(), Err: ErrPathOutOfRange}
+	}
+	l, err := o.list("get", p.Parent())
+	if err != nil {
+		return nil, err
+	}
+	i := p[len(p)-1]
+	if i < 1 || i > len(*l) {
+		return nil, &PathError{Op: "get", Path: p.String(), Err: ErrPathOutOfRange}
+	}
+	return (*l)[i-1], nil
+}
+
+// AppendAt appends outline elements as the last children of the
+// element at path p. The root path appends to Body.Outline.
+func (o *OPML) AppendAt(p OutlinePath, elems ...*Outline) error {
+	l, err := o.list("append", p)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, elems...)
+	return nil
+}
+
+// InsertAt inserts outline elements before the element at path p.
+// The last index of p may be one past the end of the list, which
+// is the same as appending to the parent.
+func (o *OPML) InsertAt(p OutlinePath, elems ...*Outline) error {
+	if p.IsRoot() {
+		return &PathError{Op: "insert", Path: p.String(), Err: ErrPathOutOfRange}
+	}
+	l, err := o.list("insert", p.Parent())
+	if err != nil {
+		return err
+	}
+	i := p[len(p)-1]
+	if i < 1 || i > len(*l)+1 {
+		return &PathError{Op: "insert", Path: p.String(), Err: ErrPathOutOfRange}
+	}
+	updated := make([]*Outline, 0, len(*l)+len(elems))
+	updated = append(updated, (*l)[:i-1]...)
+	updated = append(updated, elems...)
+	updated = append(updated, (*l)[i-1:]...)
+	*l = updated
+	return nil
+}
+
+// ReplaceAt replaces the element at path p with elem
+func (o *OPML) ReplaceAt(p OutlinePath, elem *Outline) error {
+	if p.IsRoot() {
+		return &PathError{Op: "replace", Path: p.String(), Err: ErrPathOutOfRange}
+	}
+	l, err := o.list("replace", p.Parent())
+	if err != nil {
+		return err
+	}
+	i := p[len(p)-1]
+	if i < 1 || i > len(*l) {
+		return &PathError{Op: "replace", Path: p.String(), Err: ErrPathOutOfRange}
+	}
+	(*l)[i-1] = elem
+	return nil
+}
+
+// DeleteAt removes the element at path p along with its children.
+// Deleting the root path leaves an empty outline.
+func (o *OPML) DeleteAt(p OutlinePath) error {
+	if p.IsRoot() {
+		if o.Body == nil {
+			o.Body = new(Body)
+		}
+		o.Body.Outline = []*Outline{}
+		return nil
+	}
+	l, err := o.list("delete", p.Parent())
+	if err != nil {
+		return err
+	}
+	i := p[len(p)-1]
+	if i < 1 || i > len(*l) {
+		return &PathError{Op: "delete", Path: p.String(), Err: ErrPathOutOfRange}
+	}
+	*l = append((*l)[:i-1], (*l)[i:]...)
+	return nil
+}
+
+// Find returns the path of the first outline element, in document
+// order, whose attribute name has value.
+func (o *OPML) Find(name string, value string) (OutlinePath, error) {
+	var found OutlinePath
+	o.WalkWithPath(func(ol *Outline, p OutlinePath, depth int, parent *Outline) error {
+		if s, ok := ol.Attr(name); ok && s == value {
+			found = p
+			return StopWalk
+		}
+		return nil
+	})
+	if found == nil {
+		return nil, &PathError{Op: "find", Path: fmt.Sprintf("%s=%q", name, value), Err: ErrNotFound}
+	}
+	return found, nil
+}
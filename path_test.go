@@ -0,0 +1,160 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	for src, expected := range map[string]string{
+		"":       "/",
+		"/":      "/",
+		"/3":     "/3",
+		"/3/2":   "/3/2",
+		"3/2/":   "/3/2",
+		"/10/1/": "/10/1",
+	} {
+		p, err := ParsePath(src)
+		if err != nil {
+			t.Errorf("ParsePath(%q) unexpected error, %s", src, err)
+			continue
+		}
+		if p.String() != expected {
+			t.Errorf("ParsePath(%q) expected %q, got %q", src, expected, p)
+		}
+	}
+	for _, src := range []string{"/0", "/a", "/3//2", "/-1", "/1.5"} {
+		_, err := ParsePath(src)
+		if !errors.Is(err, ErrMalformedPath) {
+			t.Errorf("ParsePath(%q) expected ErrMalformedPath, got %v", src, err)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	o, err := ReadFile("testdata/example4.opml")
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	p, _ := ParsePath("/1/3/2")
+	elem, err := o.Get(p)
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if elem.Text != "West Newton" {
+		t.Errorf("expected West Newton, got %q", elem.Text)
+	}
+	for _, s := range []string{"/", "/2", "/1/6", "/1/1/1"} {
+		p, _ = ParsePath(s)
+		if _, err := o.Get(p); !errors.Is(err, ErrPathOutOfRange) {
+			t.Errorf("Get(%q) expected ErrPathOutOfRange, got %v", s, err)
+		}
+	}
+}
+
+func TestInsertAppendReplaceDelete(t *testing.T) {
+	o, err := ReadFile("testdata/example4.opml")
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	// Append to the root and to a nested element
+	if err := o.AppendAt(OutlinePath{}, &Outline{Text: "Elsewhere"}); err != nil {
+		t.Errorf("%s", err)
+	}
+	if len(o.Body.Outline) != 2 || o.Body.Outline[1].Text != "Elsewhere" {
+		t.Errorf("expected Elsewhere appended to root, got %s", o.Body)
+	}
+	if err := o.AppendAt(OutlinePath{1, 2}, &Outline{Text: "Harlem"}); err != nil {
+		t.Errorf("%s", err)
+	}
+	if elem, _ := o.Get(OutlinePath{1, 2, 3}); elem == nil || elem.Text != "Harlem" {
+		t.Errorf("expected Harlem at /1/2/3, got %s", elem)
+	}
+
+	// Insert before the first child and one past the end
+	if err := o.InsertAt(OutlinePath{1, 1}, &Outline{Text: "Anchorage"}); err != nil {
+		t.Errorf("%s", err)
+	}
+	if elem, _ := o.Get(OutlinePath{1, 1}); elem == nil || elem.Text != "Anchorage" {
+		t.Errorf("expected Anchorage at /1/1, got %s", elem)
+	}
+	if elem, _ := o.Get(OutlinePath{1, 2}); elem == nil || elem.Text != "Victoria, BC" {
+		t.Errorf("expected Victoria, BC at /1/2, got %s", elem)
+	}
+	if err := o.InsertAt(OutlinePath{3}, &Outline{Text: "Last"}); err != nil {
+		t.Errorf("%s", err)
+	}
+	if err := o.InsertAt(OutlinePath{5}, &Outline{Text: "Too far"}); !errors.Is(err, ErrPathOutOfRange) {
+		t.Errorf("expected ErrPathOutOfRange, got %v", err)
+	}
+
+	// Replace and delete
+	if err := o.ReplaceAt(OutlinePath{3}, &Outline{Text: "Replaced"}); err != nil {
+		t.Errorf("%s", err)
+	}
+	if elem, _ := o.Get(OutlinePath{3}); elem == nil || elem.Text != "Replaced" {
+		t.Errorf("expected Replaced at /3, got %s", elem)
+	}
+	if err := o.DeleteAt(OutlinePath{2}); err != nil {
+		t.Errorf("%s", err)
+	}
+	if len(o.Body.Outline) != 2 || o.Body.Outline[1].Text != "Replaced" {
+		t.Errorf("expected Elsewhere deleted, got %s", o.Body)
+	}
+	if err := o.DeleteAt(OutlinePath{9}); !errors.Is(err, ErrPathOutOfRange) {
+		t.Errorf("expected ErrPathOutOfRange, got %v", err)
+	}
+	if err := o.DeleteAt(OutlinePath{}); err != nil {
+		t.Errorf("%s", err)
+	}
+	if len(o.Body.Outline) != 0 {
+		t.Errorf("expected an empty outline after deleting root, got %s", o.Body)
+	}
+}
+
+func TestFind(t *testing.T) {
+	o, err := ReadFile("testdata/example2.opml")
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	p, err := o.Find("text", "Metairie")
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if p.String() != "/1/4/2" {
+		t.Errorf("expected /1/4/2, got %s", p)
+	}
+	p, err = o.Find("url", "http://api.example.org/janedoe/victoria-bc.opml")
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if p.String() != "/1/5" {
+		t.Errorf("expected /1/5, got %s", p)
+	}
+	if _, err := o.Find("text", "Nowhere"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
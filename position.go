@@ -0,0 +1,144 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"fmt"
+	"io/ioutil"
+	"unicode/utf8"
+)
+
+// Position is a location in an OPML source, lines and columns are one
+// based, columns count characters.
+type Position struct {
+	Filename string `json:"filename,omitempty"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+// String returns the position as "filename:line:column"
+func (p Position) String() string {
+	s := p.Filename
+	if p.Line > 0 {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return s
+}
+
+// ParseError is returned by ParseWith and ReadFileWith, it records
+// where in the source the error happened and the path of the enclosing
+// outline element (nil if the error is outside the body's outline).
+type ParseError struct {
+	Pos  Position
+	Path OutlinePath
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	s := e.Pos.String()
+	if s != "" {
+		s += ": "
+	}
+	if e.Path != nil {
+		s += fmt.Sprintf("in outline %s: ", e.Path)
+	}
+	return s + e.Err.Error()
+}
+
+// Unwrap returns the underlying error, e.g. an *xml.SyntaxError
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseOptions control optional behavior of ParseWith and ReadFileWith
+type ParseOptions struct {
+	// Filename is used in positions and error messages
+	Filename string
+
+	// Positions records the source position of each outline element
+	// in Outline.Pos
+	Positions bool
+
+	// Preserve keeps comments, processing instructions, directives,
+	// unknown elements and stray text as Nodes in the Extra fields of
+	// the document so they survive a round trip through an Encoder
+	Preserve bool
+}
+
+// lineCounter turns increasing byte offsets into lines and columns
+type lineCounter struct {
+	src       []byte
+	offset    int
+	line      int
+	lineStart int
+}
+
+func newLineCounter(src []byte) *lineCounter {
+	return &lineCounter{src: src, line: 1}
+}
+
+// position returns the position of offset, it is fastest when offsets
+// don't decrease between calls.
+func (lc *lineCounter) position(fname string, offset int) Position {
+	if offset > len(lc.src) {
+		offset = len(lc.src)
+	}
+	if offset < lc.offset {
+		// Count again from the start
+		lc.offset, lc.line, lc.lineStart = 0, 1, 0
+	}
+	for ; lc.offset < offset; lc.offset++ {
+		if lc.src[lc.offset] == '\n' {
+			lc.line++
+			lc.lineStart = lc.offset + 1
+		}
+	}
+	return Position{
+		Filename: fname,
+		Line:     lc.line,
+		Column:   utf8.RuneCount(lc.src[lc.lineStart:offset]) + 1,
+	}
+}
+
+// ParseWith reads a []byte and returns an OPML object like Parse. Errors
+// are returned as a *ParseError holding the position of the error and
+// the path of the enclosing outline element, this includes values that
+// can't be decoded such as isComment="maybe". If opts.Positions is true
+// the source position of each outline element is recorded in
+// Outline.Pos. If opts.Preserve is true comments, processing
+// instructions and unknown elements are kept so an Encoder writes
+// them back in place.
+func ParseWith(src []byte, opts ParseOptions) (*OPML, error) {
+	return parse(src, opts)
+}
+
+// ReadFileWith reads an OPML file like ReadFile using ParseWith. If
+// opts.Filename is empty it is set to fname.
+func ReadFileWith(fname string, opts ParseOptions) (*OPML, error) {
+	src, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Filename == "" {
+		opts.Filename = fname
+	}
+	return ParseWith(src, opts)
+}
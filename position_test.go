@@ -0,0 +1,186 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPositions(t *testing.T) {
+	fname := "testdata/example4.opml"
+	o, err := ReadFileWith(fname, ParseOptions{Positions: true})
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	for _, test := range []struct {
+		path string
+		line int
+		col  int
+	}{
+		{"/1", 7, 3},
+		{"/1/2", 9, 13},
+		{"/1/2/1", 10, 17},
+		{"/1/3/2", 15, 5},
+	} {
+		p, err := ParsePath(test.path)
+		if err != nil {
+			t.Errorf("%s: %s", test.path, err)
+			continue
+		}
+		ol, err := o.Get(p)
+		if err != nil {
+			t.Errorf("%s: %s", test.path, err)
+			continue
+		}
+		if ol.Pos == nil {
+			t.Errorf("%s: expected a position", test.path)
+			continue
+		}
+		expected := Position{Filename: fname, Line: test.line, Column: test.col}
+		if *ol.Pos != expected {
+			t.Errorf("%s: expected %s, got %s", test.path, expected, ol.Pos)
+		}
+	}
+
+	// Positions are only recorded when asked for
+	o, err = ReadFileWith(fname, ParseOptions{})
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if o.Body.Outline[0].Pos != nil {
+		t.Errorf("expected no position, got %s", o.Body.Outline[0].Pos)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	src := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+  <head><title>broken</title></head>
+  <body>
+    <outline text="One"/>
+    <outline text="Two">
+      <outline text="Three & Four"/>
+    </outline>
+  </body>
+</opml>
+`)
+	_, err := ParseWith(src, ParseOptions{Filename: "broken.opml"})
+	if err == nil {
+		t.Errorf("expected an error")
+		t.FailNow()
+	}
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Errorf("expected a *ParseError, got %T %s", err, err)
+		t.FailNow()
+	}
+	if pe.Pos.Filename != "broken.opml" || pe.Pos.Line != 7 {
+		t.Errorf("expected broken.opml line 7, got %s", pe.Pos)
+	}
+	if pe.Path.String() != "/2" {
+		t.Errorf("expected enclosing path /2, got %q", pe.Path)
+	}
+	var se *xml.SyntaxError
+	if !errors.As(err, &se) {
+		t.Errorf("expected an *xml.SyntaxError, got %T", pe.Err)
+	}
+	if s := err.Error(); !strings.HasPrefix(s, "broken.opml:7:") || !strings.Contains(s, "in outline /2") {
+		t.Errorf("unexpected error message %q", s)
+	}
+}
+
+func TestValidatePositions(t *testing.T) {
+	o, err := ReadFileWith("testdata/invalid.opml", ParseOptions{Positions: true})
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	for _, d := range Validate(o) {
+		if d.Path != nil && (d.Pos == nil || d.Pos.Line == 0) {
+			t.Errorf("expected a position for %s", d)
+		}
+	}
+}
+
+func TestParseTypeError(t *testing.T) {
+	src := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+  <head>
+    <title>types</title>
+    <windowTop>10</windowTop>
+  </head>
+  <body>
+    <outline text="One">
+      <outline text="Two" isComment="maybe"/>
+    </outline>
+  </body>
+</opml>
+`)
+	_, err := ParseWith(src, ParseOptions{Filename: "types.opml"})
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Errorf("expected a *ParseError, got %T %v", err, err)
+		t.FailNow()
+	}
+	if pe.Pos.Line != 9 || pe.Pos.Column != 7 || pe.Path.String() != "/1/1" {
+		t.Errorf("expected line 9 column 7 in /1/1, got %s in %s", pe.Pos, pe.Path)
+	}
+	var ne *strconv.NumError
+	if !errors.As(err, &ne) {
+		t.Errorf("expected a *strconv.NumError, got %T", pe.Err)
+	}
+
+	src = []byte("<opml version=\"2.0\">\n<head>\n<windowTop>top</windowTop>\n</head>\n</opml>")
+	_, err = ParseWith(src, ParseOptions{Filename: "head.opml"})
+	if !errors.As(err, &pe) || pe.Pos.Line != 3 || pe.Pos.Column != 1 || pe.Path != nil {
+		t.Errorf("expected an error at head.opml:3:1, got %v", err)
+	}
+}
+
+func TestParseWithMatchesParse(t *testing.T) {
+	fnames, err := filepath.Glob("testdata/*.opml")
+	if err != nil || len(fnames) == 0 {
+		t.Errorf("expected test files, %v", err)
+		t.FailNow()
+	}
+	for _, src := range fnames {
+		expected, err := ReadFile(src)
+		if err != nil {
+			t.Errorf("%s: %s", src, err)
+			continue
+		}
+		o, err := ReadFileWith(src, ParseOptions{})
+		if err != nil {
+			t.Errorf("%s: %s", src, err)
+			continue
+		}
+		a, _ := json.Marshal(expected)
+		b, _ := json.Marshal(o)
+		if string(a) != string(b) {
+			t.Errorf("%s: expected\n%s\ngot\n%s", src, a, b)
+		}
+	}
+}
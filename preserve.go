@@ -0,0 +1,400 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Node is XML kept by ParseWith when ParseOptions.Preserve is set that
+// has no place in the OPML structs, e.g. a comment, a processing
+// instruction or an element the package doesn't know.
+type Node struct {
+	// Index is the number of elements known to the package (head and
+	// body, head elements or outline elements) that come before the
+	// node in its parent. The Encoder uses it to place the nodes of the
+	// opml and head elements, nodes in the body and outline elements
+	// are kept with the outline that follows them (Outline.Before).
+	Index int `json:"index"`
+	// XML is the node's source
+	XML string `json:"xml"`
+}
+
+// headElements are the elements decoded into Head, "OwnerId" is the
+// legacy spelling of "ownerId"
+var headElements = map[string]bool{
+	"title":           true,
+	"dateCreated":     true,
+	"dateModified":    true,
+	"ownerName":       true,
+	"ownerEmail":      true,
+	"ownerId":         true,
+	"OwnerId":         true,
+	"docs":            true,
+	"expansionState":  true,
+	"vertScrollState": true,
+	"windowTop":       true,
+	"windowLeft":      true,
+	"windowBottom":    true,
+	"windowRight":     true,
+}
+
+// parseFrame is an open element seen by parse
+type parseFrame struct {
+	name string
+	// known is the number of known child elements seen so far
+	known int
+	// extra is where nodes in the element are kept
+	extra *[]Node
+	// before holds the nodes waiting for the next outline element of
+	// an element with a list
+	before []Node
+	// list is where child outline elements are added, it is nil if the
+	// element can't hold outline elements
+	list *[]*Outline
+	// outline is set if the element is an outline element
+	outline bool
+}
+
+// setOutlineAttr sets the field of ol named by attr the way
+// xml.Unmarshal does, attributes are matched by their local name and
+// those without a field are kept in OtherAttr
+func setOutlineAttr(ol *Outline, attr xml.Attr) error {
+	switch attr.Name.Local {
+	case "text":
+		ol.Text = attr.Value
+	case "type":
+		ol.Type = attr.Value
+	case "title":
+		ol.Title = attr.Value
+	case "isComment", "isBreakpoint":
+		b := false
+		if attr.Value != "" {
+			var err error
+			if b, err = strconv.ParseBool(strings.TrimSpace(attr.Value)); err != nil {
+				return err
+			}
+		}
+		if attr.Name.Local == "isComment" {
+			ol.IsComment = b
+		} else {
+			ol.IsBreakpoint = b
+		}
+	case "created":
+		ol.Created = attr.Value
+	case "category":
+		ol.Category = attr.Value
+	case "xmlUrl":
+		ol.XMLURL = attr.Value
+	case "htmlUrl":
+		ol.HTMLURL = attr.Value
+	case "language":
+		ol.Language = attr.Value
+	case "description":
+		ol.Description = attr.Value
+	case "version":
+		ol.Version = attr.Value
+	case "url":
+		ol.URL = attr.Value
+	default:
+		ol.OtherAttr = append(ol.OtherAttr, attr)
+	}
+	return nil
+}
+
+// setHeadElement sets the field of h for the head element name, the way
+// Head.UnmarshalXML does. The legacy "OwnerId" element is kept in
+// legacyOwnerID.
+func setHeadElement(h *Head, name string, text string, legacyOwnerID *string) error {
+	var n *int
+	switch name {
+	case "title":
+		h.Title = text
+	case "dateCreated":
+		h.Created = text
+	case "dateModified":
+		h.Modified = text
+	case "ownerName":
+		h.OwnerName = text
+	case "ownerEmail":
+		h.OwnerEmail = text
+	case "ownerId":
+		h.OwnerID = text
+	case "OwnerId":
+		*legacyOwnerID = text
+	case "docs":
+		h.Docs = text
+	case "expansionState":
+		h.ExpansionState = text
+	case "vertScrollState":
+		n = &h.VertScrollState
+	case "windowTop":
+		n = &h.WindowTop
+	case "windowLeft":
+		n = &h.WindowLeft
+	case "windowBottom":
+		n = &h.WindowBottom
+	case "windowRight":
+		n = &h.WindowRight
+	}
+	if n != nil {
+		*n = 0
+		if text != "" {
+			i, err := strconv.ParseInt(strings.TrimSpace(text), 10, 0)
+			if err != nil {
+				return err
+			}
+			*n = int(i)
+		}
+	}
+	return nil
+}
+
+// elementText reads the rest of an element returning the character
+// data directly inside it, nested elements are skipped
+func elementText(d *xml.Decoder) (string, error) {
+	var b strings.Builder
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth == 0 {
+				return b.String(), nil
+			}
+			depth--
+		case xml.CharData:
+			if depth == 0 {
+				b.Write(t)
+			}
+		}
+	}
+}
+
+// parse decodes an OPML document in a single pass over the XML tokens
+// of src, filling in the OPML structs as xml.Unmarshal does. It records
+// the position of each outline element if opts.Positions is set and,
+// if opts.Preserve is set, the nodes that xml.Unmarshal drops. Errors,
+// including values that can't be decoded, are returned as a
+// *ParseError with the position of the element at fault.
+func parse(src []byte, opts ParseOptions) (*OPML, error) {
+	d := xml.NewDecoder(bytes.NewReader(src))
+	lc := newLineCounter(src)
+	o := New()
+	frames := []*parseFrame{}
+	// path is the path of the open outline element
+	path := OutlinePath{}
+	rootSeen, rootDone := false, false
+	legacyOwnerID := ""
+
+	fail := func(offset int, err error) (*OPML, error) {
+		var p OutlinePath
+		if len(path) > 0 {
+			p = append(OutlinePath{}, path...)
+		}
+		return nil, &ParseError{
+			Pos:  lc.position(opts.Filename, offset),
+			Path: p,
+			Err:  err,
+		}
+	}
+	// keep records src[start:InputOffset()] as a node of the innermost
+	// open element, or of the document outside the opml element
+	keep := func(start int) {
+		if !opts.Preserve {
+			return
+		}
+		n := Node{XML: string(src[start:int(d.InputOffset())])}
+		switch {
+		case len(frames) > 0:
+			f := frames[len(frames)-1]
+			n.Index = f.known
+			if f.list != nil {
+				f.before = append(f.before, n)
+			} else {
+				*f.extra = append(*f.extra, n)
+			}
+		case rootDone:
+			o.Epilog = append(o.Epilog, n)
+		default:
+			o.Prolog = append(o.Prolog, n)
+		}
+	}
+	// skip skips the element started at offset keeping it as a node
+	skip := func(offset int) error {
+		if err := d.Skip(); err != nil {
+			return err
+		}
+		keep(offset)
+		return nil
+	}
+
+	for {
+		offset := int(d.InputOffset())
+		tok, err := d.Token()
+		if err == io.EOF {
+			if !rootSeen {
+				return fail(offset, err)
+			}
+			return o, nil
+		}
+		if err != nil {
+			return fail(int(d.InputOffset()), err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := t.Name.Local
+			if len(frames) == 0 {
+				if rootSeen {
+					// Only the first element is decoded
+					if err := skip(offset); err != nil {
+						return fail(int(d.InputOffset()), err)
+					}
+					continue
+				}
+				if name != "opml" {
+					return fail(offset, fmt.Errorf("expected element type <opml> but have <%s>", name))
+				}
+				rootSeen = true
+				o.XMLName = t.Name
+				// Version is left empty if the document has no
+				// version attribute
+				o.Version = ""
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "version" {
+						o.Version = attr.Value
+					} else {
+						o.OtherAttr = append(o.OtherAttr, attr)
+					}
+				}
+				frames = append(frames, &parseFrame{name: name, extra: &o.Extra})
+				continue
+			}
+			f := frames[len(frames)-1]
+			switch {
+			case f.list != nil:
+				if name != "outline" {
+					break
+				}
+				ol := &Outline{XMLName: t.Name, Before: f.before}
+				f.before = nil
+				*f.list = append(*f.list, ol)
+				f.known++
+				path = append(path, f.known)
+				if opts.Positions {
+					pos := lc.position(opts.Filename, offset)
+					ol.Pos = &pos
+				}
+				for _, attr := range t.Attr {
+					if err := setOutlineAttr(ol, attr); err != nil {
+						return fail(offset, err)
+					}
+				}
+				frames = append(frames, &parseFrame{name: name, extra: &ol.Extra, list: &ol.Outline, outline: true})
+				continue
+			case f.name == "head" && len(frames) == 2:
+				if !headElements[name] {
+					break
+				}
+				text, err := elementText(d)
+				if err != nil {
+					return fail(int(d.InputOffset()), err)
+				}
+				if err := setHeadElement(o.Head, name, text, &legacyOwnerID); err != nil {
+					return fail(offset, err)
+				}
+				f.known++
+				continue
+			case len(frames) == 1:
+				switch name {
+				case "head":
+					// A head element replaces the head like
+					// Head.UnmarshalXML
+					f.known++
+					*o.Head = Head{}
+					for _, attr := range t.Attr {
+						o.Head.OtherAttr = append(o.Head.OtherAttr, attr)
+					}
+					legacyOwnerID = ""
+					frames = append(frames, &parseFrame{name: name, extra: &o.Head.Extra})
+					continue
+				case "body":
+					f.known++
+					o.Body.XMLName = t.Name
+					for _, attr := range t.Attr {
+						o.Body.OtherAttr = append(o.Body.OtherAttr, attr)
+					}
+					frames = append(frames, &parseFrame{name: name, extra: &o.Body.Extra, list: &o.Body.Outline})
+					continue
+				}
+			}
+			// Unknown elements are skipped and kept as nodes
+			if err := skip(offset); err != nil {
+				return fail(int(d.InputOffset()), err)
+			}
+		case xml.EndElement:
+			f := frames[len(frames)-1]
+			frames = frames[:len(frames)-1]
+			if len(f.before) > 0 {
+				*f.extra = append(*f.extra, f.before...)
+			}
+			if f.outline {
+				path = path[:len(path)-1]
+			}
+			if f.name == "head" && len(frames) == 1 && o.Head.OwnerID == "" {
+				o.Head.OwnerID = legacyOwnerID
+			}
+			if len(frames) == 0 {
+				rootDone = true
+			}
+		case xml.Comment, xml.Directive:
+			keep(offset)
+		case xml.ProcInst:
+			// The declaration is written by an Encoder
+			if t.Target != "xml" {
+				keep(offset)
+			}
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) > 0 {
+				keep(offset)
+			}
+		}
+	}
+}
+
+// writeNodes writes the nodes with an Index up to index, it returns the
+// nodes left to write
+func (enc *Encoder) writeNodes(nodes []Node, index int) []Node {
+	for len(nodes) > 0 && nodes[0].Index <= index {
+		enc.closePending()
+		enc.newline()
+		enc.write(nodes[0].XML)
+		nodes = nodes[1:]
+	}
+	return nodes
+}
@@ -0,0 +1,185 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	fnames, err := filepath.Glob("testdata/*.opml")
+	if err != nil || len(fnames) == 0 {
+		t.Errorf("can't find testdata, %v", err)
+		t.FailNow()
+	}
+	for _, fname := range fnames {
+		o, err := ReadFileWith(fname, ParseOptions{Preserve: true})
+		if err != nil {
+			t.Errorf("%s", err)
+			continue
+		}
+		for _, opts := range []EncodeOptions{
+			{Declaration: true},
+			{Indent: "    ", SelfClose: true},
+			{Canonical: true},
+		} {
+			src, err := MarshalWith(o, opts)
+			if err != nil {
+				t.Errorf("%s: %s", fname, err)
+				continue
+			}
+			o2, err := ParseWith(src, ParseOptions{Filename: fname, Preserve: true})
+			if err != nil {
+				t.Errorf("%s: %s\n%s", fname, err, src)
+				continue
+			}
+			// Nothing decoded into the structs is lost
+			if o.String() != o2.String() {
+				t.Errorf("%s %+v: expected\n%s\ngot\n%s", fname, opts, o, o2)
+			}
+			// and the preserved nodes are written back in place
+			src2, err := MarshalWith(o2, opts)
+			if err != nil {
+				t.Errorf("%s: %s", fname, err)
+				continue
+			}
+			if !bytes.Equal(src, src2) {
+				t.Errorf("%s %+v: expected\n%s\ngot\n%s", fname, opts, src, src2)
+			}
+		}
+	}
+}
+
+func TestPreserve(t *testing.T) {
+	fname := "testdata/annotated.opml"
+	o, err := ReadFileWith(fname, ParseOptions{Preserve: true})
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if len(o.Prolog) != 2 || len(o.Epilog) != 1 {
+		t.Errorf("expected 2 prolog and 1 epilog nodes, got %+v and %+v", o.Prolog, o.Epilog)
+	}
+	if len(o.Head.Extra) != 2 || o.Head.Extra[0].Index != 1 || o.Head.Extra[1].Index != 2 {
+		t.Errorf("unexpected head nodes %+v", o.Head.Extra)
+	}
+	if len(o.Body.Extra) != 1 || o.Body.Extra[0].XML != "<!-- end of feeds -->" {
+		t.Errorf("unexpected body nodes %+v", o.Body.Extra)
+	}
+	if ol := o.Body.Outline[0]; len(ol.Before) != 1 || len(ol.Extra) != 0 {
+		t.Errorf("unexpected nodes %+v and %+v", ol.Before, ol.Extra)
+	}
+	if ol := o.Body.Outline[0].Outline[1]; len(ol.Before) != 1 || ol.Before[0].XML != "<!-- moved from News -->" {
+		t.Errorf("unexpected nodes before %q, %+v", ol.Text, ol.Before)
+	}
+
+	expected := Declaration + `
+<!-- Team subscriptions, edit with care -->
+<?xml-stylesheet type="text/xsl" href="opml.xsl"?>
+<opml version="2.0" xmlns:team="http://example.org/team">
+  <head>
+    <title>Team feeds</title>
+    <!-- dates are UTC -->
+    <dateCreated>Mon, 01 Mar 2021 10:00:00 GMT</dateCreated>
+    <team:reviewed by="rsdoiel">2021-03-02</team:reviewed>
+    <ownerName>R. S. Doiel</ownerName>
+  </head>
+  <body>
+    <?editor fold="true"?>
+    <outline text="Go" team:owner="alice">
+      <team:note>Keep this list short</team:note>
+      <outline text="Go Blog" type="rss" xmlUrl="https://go.dev/blog/feed.atom"/>
+      <!-- moved from News -->
+      <outline text="golang weekly" type="rss" xmlUrl="https://golangweekly.com/rss"/>
+    </outline>
+    <outline text="News"/>
+    <!-- end of feeds -->
+  </body>
+</opml>
+<!-- generated by hand -->
+`
+	src, err := MarshalWith(o, EncodeOptions{Canonical: true})
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if string(src) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, src)
+	}
+
+	// Without Preserve the nodes are dropped
+	o, err = ReadFileWith(fname, ParseOptions{})
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	src, err = MarshalWith(o, EncodeOptions{})
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if strings.Contains(string(src), "<!--") || strings.Contains(string(src), "team:note") {
+		t.Errorf("expected no preserved nodes, got\n%s", src)
+	}
+}
+
+func TestPreserveSort(t *testing.T) {
+	src := []byte(`<opml version="2.0">
+  <head>
+    <title>Sorted</title>
+  </head>
+  <body>
+    <outline text="Zeta"/>
+    <!-- about Alpha -->
+    <outline text="Alpha">
+      <outline text="b"/>
+      <!-- last -->
+    </outline>
+  </body>
+</opml>`)
+	o, err := ParseWith(src, ParseOptions{Preserve: true})
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	o.Sort()
+	expected := `<opml version="2.0">
+  <head>
+    <title>Sorted</title>
+  </head>
+  <body>
+    <!-- about Alpha -->
+    <outline text="Alpha">
+      <outline text="b"></outline>
+      <!-- last -->
+    </outline>
+    <outline text="Zeta"></outline>
+  </body>
+</opml>`
+	src, err = MarshalWith(o, EncodeOptions{Indent: "  "})
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if string(src) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, src)
+	}
+}
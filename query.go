@@ -0,0 +1,489 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Query is a compiled filter expression. Expressions compare outline
+// attributes with values and are combined with "and", "or", "not"
+// and parentheses.
+//
+//	type = rss and (text like "Go*" or @priority >= 2)
+//	xmlUrl matches "feedburner\.com" and not has-children
+//	created >= 2021-01-01 and created < 2022-01-01 and depth <= 2
+//
+// The left side of a comparison is an attribute name (e.g. text, type,
+// xmlUrl), a custom attribute prefixed with "@" or one of depth,
+// children (the number of child elements) and created (compared as a
+// date). Operators are "=", "!=", "<", "<=", ">", ">=", "like" and
+// "ilike" (shell patterns, ilike ignores case) and "matches" (a regular
+// expression). "has NAME" is true when the attribute is set and
+// "has-children" when the element has children.
+type Query struct {
+	src  string
+	expr queryExpr
+}
+
+type queryExpr interface {
+	match(ol *Outline, depth int) bool
+}
+
+type queryAnd struct {
+	left, right queryExpr
+}
+
+func (q *queryAnd) match(ol *Outline, depth int) bool {
+	return q.left.match(ol, depth) && q.right.match(ol, depth)
+}
+
+type queryOr struct {
+	left, right queryExpr
+}
+
+func (q *queryOr) match(ol *Outline, depth int) bool {
+	return q.left.match(ol, depth) || q.right.match(ol, depth)
+}
+
+type queryNot struct {
+	e queryExpr
+}
+
+func (q *queryNot) match(ol *Outline, depth int) bool {
+	return !q.e.match(ol, depth)
+}
+
+type queryHas struct {
+	name string
+}
+
+func (q *queryHas) match(ol *Outline, depth int) bool {
+	_, ok := ol.Attr(q.name)
+	return ok
+}
+
+type queryHasChildren struct{}
+
+func (q *queryHasChildren) match(ol *Outline, depth int) bool {
+	return ol.HasChildren()
+}
+
+// queryCompare holds a single "field op value" comparison
+type queryCompare struct {
+	field string
+	op    string
+	value string
+	re    *regexp.Regexp
+	num   float64
+	isNum bool
+	date  time.Time
+}
+
+// fieldValue returns the value of the comparison's field and if it is set
+func (q *queryCompare) fieldValue(ol *Outline, depth int) (string, bool) {
+	switch q.field {
+	case "depth":
+		return strconv.Itoa(depth), true
+	case "children":
+		return strconv.Itoa(len(ol.Outline)), true
+	}
+	return ol.Attr(strings.TrimPrefix(q.field, "@"))
+}
+
+func (q *queryCompare) match(ol *Outline, depth int) bool {
+	val, ok := q.fieldValue(ol, depth)
+	switch q.op {
+	case "like", "ilike", "matches":
+		return ok && q.re.MatchString(val)
+	case "=":
+		return ok && val == q.value
+	case "!=":
+		return !ok || val != q.value
+	}
+	if !ok {
+		return false
+	}
+	// Ordered comparisons, dates for created, numbers when both sides
+	// are numeric and strings otherwise.
+	cmp := 0
+	if q.field == "created" {
+		t, err := ParseDate(val)
+		if err != nil {
+			return false
+		}
+		switch {
+		case t.Before(q.date):
+			cmp = -1
+		case t.After(q.date):
+			cmp = 1
+		}
+	} else if n, err := strconv.ParseFloat(val, 64); err == nil && q.isNum {
+		switch {
+		case n < q.num:
+			cmp = -1
+		case n > q.num:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(val, q.value)
+	}
+	switch q.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// CompileQuery parses a filter expression into a Query
+func CompileQuery(src string) (*Query, error) {
+	toks, err := lexQuery(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{src: src, toks: toks}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, p.errorf("unexpected %q", p.toks[p.pos].val)
+	}
+	return &Query{src: src, expr: e}, nil
+}
+
+// MustCompileQuery is like CompileQuery but panics if the expression
+// can't be parsed.
+func MustCompileQuery(src string) *Query {
+	q, err := CompileQuery(src)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// String returns the source of the query
+func (q *Query) String() string {
+	return q.src
+}
+
+// Match returns true if the outline element at path p matches the query
+func (q *Query) Match(ol *Outline, p OutlinePath) bool {
+	if ol == nil {
+		return false
+	}
+	return q.expr.match(ol, len(p))
+}
+
+// Filter returns a new OPML document holding the outline elements that
+// match q along with their ancestors. Elements are copied, the original
+// document is not changed.
+func (o *OPML) Filter(q *Query) *OPML {
+	return o.FilterFunc(func(ol *Outline, p OutlinePath) bool {
+		return q.Match(ol, p)
+	})
+}
+
+// FilterFunc is like Filter but selects the outline elements with fn
+func (o *OPML) FilterFunc(fn func(ol *Outline, p OutlinePath) bool) *OPML {
+	result := New()
+	result.Version = o.Version
+	result.OtherAttr = o.OtherAttr
+	if o.Head != nil {
+		head := *o.Head
+		result.Head = &head
+	}
+	if o.Body != nil {
+		result.Body.OtherAttr = o.Body.OtherAttr
+		result.Body.Outline = filterOutline(o.Body.Outline, OutlinePath{}, fn)
+	}
+	return result
+}
+
+func filterOutline(l []*Outline, parent OutlinePath, fn func(*Outline, OutlinePath) bool) []*Outline {
+	var result []*Outline
+	for i, elem := range l {
+		if elem == nil {
+			continue
+		}
+		p := parent.Child(i + 1)
+		children := filterOutline(elem.Outline, p, fn)
+		if len(children) > 0 || fn(elem, p) {
+			cp := *elem
+			cp.Outline = children
+			result = append(result, &cp)
+		}
+	}
+	return result
+}
+
+//
+// Lexer and parser
+//
+
+type queryToken struct {
+	kind byte // 'w' word, 's' quoted string, 'o' operator, '(' or ')'
+	val  string
+	pos  int
+}
+
+func lexQuery(src string) ([]queryToken, error) {
+	toks := []queryToken{}
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			toks = append(toks, queryToken{kind: byte(r), val: string(r), pos: i})
+			i++
+		case r == '"' || r == '\'':
+			j := i + 1
+			for j < len(runes) && runes[j] != r {
+				if runes[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("query %q: unterminated string at %d", src, i)
+			}
+			// Only the quote and backslash are escaped so regular
+			// expressions can be written as is.
+			var sb strings.Builder
+			for k := i + 1; k < j; k++ {
+				if runes[k] == '\\' && k+1 < j && (runes[k+1] == r || runes[k+1] == '\\') {
+					k++
+				}
+				sb.WriteRune(runes[k])
+			}
+			s := sb.String()
+			toks = append(toks, queryToken{kind: 's', val: s, pos: i})
+			i = j + 1
+		case strings.ContainsRune("=!<>", r):
+			j := i + 1
+			if j < len(runes) && runes[j] == '=' {
+				j++
+			}
+			op := string(runes[i:j])
+			if op == "!" {
+				return nil, fmt.Errorf("query %q: unexpected \"!\" at %d", src, i)
+			}
+			toks = append(toks, queryToken{kind: 'o', val: op, pos: i})
+			i = j
+		default:
+			j := i
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && !strings.ContainsRune("()=!<>\"'", runes[j]) {
+				j++
+			}
+			toks = append(toks, queryToken{kind: 'w', val: string(runes[i:j]), pos: i})
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+type queryParser struct {
+	src  string
+	toks []queryToken
+	pos  int
+}
+
+func (p *queryParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("query %q: %s", p.src, fmt.Sprintf(format, args...))
+}
+
+// keyword returns true if the next token is the word kw (case insensitive)
+func (p *queryParser) keyword(kw string) bool {
+	return p.pos < len(p.toks) && p.toks[p.pos].kind == 'w' && strings.EqualFold(p.toks[p.pos].val, kw)
+}
+
+func (p *queryParser) parseOr() (queryExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseNot() (queryExpr, error) {
+	if p.keyword("not") {
+		p.pos++
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &queryNot{e}, nil
+	}
+	return p.parseTerm()
+}
+
+func (p *queryParser) parseTerm() (queryExpr, error) {
+	if p.pos >= len(p.toks) {
+		return nil, p.errorf("unexpected end of query")
+	}
+	tok := p.toks[p.pos]
+	p.pos++
+	switch {
+	case tok.kind == '(':
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.toks) || p.toks[p.pos].kind != ')' {
+			return nil, p.errorf("missing closing \")\"")
+		}
+		p.pos++
+		return e, nil
+	case tok.kind != 'w':
+		return nil, p.errorf("unexpected %q at %d", tok.val, tok.pos)
+	case strings.EqualFold(tok.val, "has-children"):
+		return &queryHasChildren{}, nil
+	case strings.EqualFold(tok.val, "has"):
+		if p.pos >= len(p.toks) || p.toks[p.pos].kind != 'w' {
+			return nil, p.errorf("has expects an attribute name")
+		}
+		p.pos++
+		return &queryHas{strings.TrimPrefix(p.toks[p.pos-1].val, "@")}, nil
+	}
+
+	// field op value
+	q := &queryCompare{field: tok.val}
+	if p.pos >= len(p.toks) {
+		return nil, p.errorf("%s expects an operator", tok.val)
+	}
+	op := p.toks[p.pos]
+	switch {
+	case op.kind == 'o':
+		q.op = op.val
+	case op.kind == 'w' && (strings.EqualFold(op.val, "like") || strings.EqualFold(op.val, "ilike") || strings.EqualFold(op.val, "matches")):
+		q.op = strings.ToLower(op.val)
+	default:
+		return nil, p.errorf("unexpected %q at %d, expected an operator", op.val, op.pos)
+	}
+	p.pos++
+	if p.pos >= len(p.toks) || (p.toks[p.pos].kind != 'w' && p.toks[p.pos].kind != 's') {
+		return nil, p.errorf("%s %s expects a value", q.field, q.op)
+	}
+	q.value = p.toks[p.pos].val
+	p.pos++
+
+	var err error
+	switch q.op {
+	case "like", "ilike":
+		q.re, err = GlobRegexp(q.value, q.op == "ilike")
+	case "matches":
+		q.re, err = regexp.Compile(q.value)
+	case "<", "<=", ">", ">=":
+		if q.field == "created" {
+			q.date, err = ParseDate(q.value)
+		} else if n, e := strconv.ParseFloat(q.value, 64); e == nil {
+			q.num, q.isNum = n, true
+		}
+	}
+	if err != nil {
+		return nil, p.errorf("%s", err)
+	}
+	return q, nil
+}
+
+// GlobRegexp converts a shell pattern using "*", "?" and "[...]" into
+// an anchored regular expression, "[!...]" matches characters not in
+// the class. A backslash escapes the next character. It is used by the
+// like and ilike operators of a Query.
+func GlobRegexp(pattern string, caseInsensitive bool) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	if caseInsensitive {
+		sb.WriteString("(?i)")
+	}
+	sb.WriteString("^")
+	inClass := false
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case inClass:
+			if r == ']' {
+				inClass = false
+			}
+			if r == '\\' && i+1 < len(runes) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(string(runes[i])))
+				continue
+			}
+			sb.WriteRune(r)
+		case r == '\\' && i+1 < len(runes):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case r == '*':
+			sb.WriteString(".*")
+		case r == '?':
+			sb.WriteString(".")
+		case r == '[':
+			inClass = true
+			sb.WriteRune(r)
+			if i+1 < len(runes) && runes[i+1] == '!' {
+				i++
+				sb.WriteRune('^')
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if inClass {
+		return nil, fmt.Errorf("%q: missing closing ']'", pattern)
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
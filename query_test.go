@@ -0,0 +1,120 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	o, err := ReadFile("testdata/feeds.opml")
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	for src, expected := range map[string]string{
+		`type = rss and text like "Go*"`:                    "Go Blog",
+		`text ilike "go*"`:                                  "Go Blog, golang weekly",
+		`xmlUrl matches "feedburner\.com"`:                  "Letters of Note, DailyJS",
+		`@priority >= 2`:                                    "golang weekly, BBC Technology",
+		`priority < 2 or type = link`:                       "Go Blog, Scripting News",
+		`created >= 2021-01-01 and created < "2022-01-01"`:  "Go Blog, golang weekly",
+		`has-children`:                                      "Tech, News, Archive",
+		`depth = 1 and not has-children`:                    "Scripting News",
+		`children > 2`:                                      "Tech, News",
+		`has category and not (category like "*History*")`:  "Lambda the Ultimate",
+		`has htmlUrl AND NOT text = 'Go Blog'`:              "BBC Technology",
+		`type != rss and depth <= 2 and not text = Archive`: "Tech, News, Scripting News",
+	} {
+		q, err := CompileQuery(src)
+		if err != nil {
+			t.Errorf("%s", err)
+			continue
+		}
+		found := []string{}
+		o.WalkWithPath(func(ol *Outline, p OutlinePath, depth int, parent *Outline) error {
+			if q.Match(ol, p) {
+				found = append(found, ol.Text)
+			}
+			return nil
+		})
+		if s := strings.Join(found, ", "); s != expected {
+			t.Errorf("query %s, expected %q, got %q", src, expected, s)
+		}
+	}
+	for _, src := range []string{``, `text =`, `text like`, `(type = rss`, `type rss`, `text matches "("`, `created > someday`, `text = "open`} {
+		if _, err := CompileQuery(src); err == nil {
+			t.Errorf("expected an error compiling %q", src)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	o, err := ReadFile("testdata/feeds.opml")
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	result := o.Filter(MustCompileQuery(`xmlUrl like "*feedburner*"`))
+	expected := `<body><outline text="News"><outline text="Letters of Note" type="rss" category="/Letters,/History" xmlUrl="http://feeds.feedburner.com/LettersOfNote"></outline><outline text="Archive"><outline text="DailyJS" type="rss" created="Sat, 01 Oct 2011 08:00:00 GMT" xmlUrl="http://feeds.feedburner.com/dailyjs"></outline></outline></outline></body>`
+	if s := result.String(); !strings.Contains(s, expected) {
+		t.Errorf("expected\n%s\nin\n%s", expected, s)
+	}
+	if result.Head.Title != o.Head.Title {
+		t.Errorf("expected the head to be copied")
+	}
+	// The original is unchanged
+	if len(o.Body.Outline) != 3 || len(o.Body.Outline[1].Outline) != 3 {
+		t.Errorf("Filter modified the original document")
+	}
+	result = o.Filter(MustCompileQuery(`text = "Nothing here"`))
+	if len(result.Body.Outline) != 0 {
+		t.Errorf("expected an empty result, got %s", result.Body)
+	}
+}
+
+func TestGlobRegexp(t *testing.T) {
+	expected := map[string]map[string]bool{
+		"Go*":     {"Go Blog": true, "golang": false},
+		"?o*":     {"Go Blog": true, "Blog": false},
+		"[!a-m]*": {"Zeta": true, "alpha": false},
+		"[ab]eta": {"beta": true, "zeta": false},
+		`a\*b`:    {"a*b": true, "axb": false},
+		`[\]x]*`:  {"]": true, "y": false},
+		"*.opml":  {"feeds.opml": true, "feeds_opml": false},
+	}
+	for pattern, cases := range expected {
+		re, err := GlobRegexp(pattern, false)
+		if err != nil {
+			t.Errorf("%q: %s", pattern, err)
+			continue
+		}
+		for s, match := range cases {
+			if re.MatchString(s) != match {
+				t.Errorf("%q matching %q expected %t", pattern, s, match)
+			}
+		}
+	}
+	if re, err := GlobRegexp("go*", true); err != nil || !re.MatchString("GOLANG") {
+		t.Errorf("expected a case insensitive match, %v", err)
+	}
+	if _, err := GlobRegexp("[abc", false); err == nil {
+		t.Errorf("expected an error for a missing ']'")
+	}
+}
@@ -0,0 +1,172 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultPreviousPrefix starts the name of the attribute where
+// RewriteRedirects records a URL it replaces, e.g. "previousXmlUrl"
+const DefaultPreviousPrefix = "previous"
+
+// DefaultRedirectAttrs are the attributes RewriteRedirects rewrites when
+// RedirectOptions.Attrs is empty
+var DefaultRedirectAttrs = []string{"xmlUrl", "htmlUrl", "url"}
+
+// RedirectResolver finds where a URL has moved permanently, e.g. by
+// following HTTP 301 and 308 redirects. It returns ref, or "", if the
+// URL hasn't moved.
+type RedirectResolver interface {
+	ResolveRedirect(ref string) (string, error)
+}
+
+// RedirectResolverFunc lets an ordinary function be used as a
+// RedirectResolver
+type RedirectResolverFunc func(ref string) (string, error)
+
+// ResolveRedirect calls f(ref)
+func (f RedirectResolverFunc) ResolveRedirect(ref string) (string, error) {
+	return f(ref)
+}
+
+// RedirectOptions control RewriteRedirects
+type RedirectOptions struct {
+	// Resolver finds the permanent location of each URL, nothing is
+	// rewritten if it is nil
+	Resolver RedirectResolver
+
+	// Attrs are the attributes to rewrite, DefaultRedirectAttrs is used
+	// if it is empty
+	Attrs []string
+
+	// Prefix starts the name of the custom attribute recording the URL
+	// replaced, the attribute's name follows with its first letter in
+	// upper case. DefaultPreviousPrefix is used if it is empty.
+	Prefix string
+
+	// DryRun lists the changes without making them
+	DryRun bool
+}
+
+// URLChange is a URL rewritten, or that couldn't be resolved, by
+// RewriteRedirects
+type URLChange struct {
+	Path OutlinePath `json:"path"`
+	Attr string      `json:"attr"`
+	Old  string      `json:"old"`
+	// New is the permanent location, it is empty if Err is set
+	New string `json:"new,omitempty"`
+	// Err is the resolver's error, the URL is left unchanged
+	Err error `json:"-"`
+}
+
+func (c URLChange) String() string {
+	if c.Err != nil {
+		return fmt.Sprintf("%s %s %s: %s", c.Path, c.Attr, c.Old, c.Err)
+	}
+	return fmt.Sprintf("%s %s %s -> %s", c.Path, c.Attr, c.Old, c.New)
+}
+
+// URLChanges is the list of changes returned by RewriteRedirects
+type URLChanges []URLChange
+
+// String returns the changes as text, one per line
+func (changes URLChanges) String() string {
+	var b strings.Builder
+	for _, c := range changes {
+		b.WriteString(c.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Errors returns the changes where the resolver failed
+func (changes URLChanges) Errors() URLChanges {
+	failed := URLChanges{}
+	for _, c := range changes {
+		if c.Err != nil {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// previousAttr returns the name of the attribute recording the previous
+// value of attr, e.g. "previousXmlUrl"
+func previousAttr(prefix string, attr string) string {
+	if attr == "" {
+		return prefix
+	}
+	return prefix + strings.ToUpper(attr[0:1]) + attr[1:]
+}
+
+// RewriteRedirects replaces the URLs in the attributes named by
+// opts.Attrs with the location returned by opts.Resolver. The value
+// replaced is recorded in a custom attribute, e.g. "previousXmlUrl",
+// overwriting a value recorded earlier. Each distinct URL is resolved
+// once. A URL the resolver fails on is left unchanged and listed with
+// its error. The changes are returned in outline order, if
+// opts.DryRun is set the outline is not changed.
+func (o *OPML) RewriteRedirects(opts RedirectOptions) URLChanges {
+	changes := URLChanges{}
+	if opts.Resolver == nil {
+		return changes
+	}
+	attrs := opts.Attrs
+	if len(attrs) == 0 {
+		attrs = DefaultRedirectAttrs
+	}
+	prefix := opts.Prefix
+	if prefix == "" {
+		prefix = DefaultPreviousPrefix
+	}
+	type resolved struct {
+		loc string
+		err error
+	}
+	seen := map[string]resolved{}
+	o.WalkWithPath(func(ol *Outline, p OutlinePath, depth int, parent *Outline) error {
+		for _, attr := range attrs {
+			old, _ := ol.Attr(attr)
+			if old == "" {
+				continue
+			}
+			r, ok := seen[old]
+			if !ok {
+				r.loc, r.err = opts.Resolver.ResolveRedirect(old)
+				seen[old] = r
+			}
+			if r.err != nil {
+				changes = append(changes, URLChange{Path: p, Attr: attr, Old: old, Err: r.err})
+				continue
+			}
+			if r.loc == "" || r.loc == old {
+				continue
+			}
+			changes = append(changes, URLChange{Path: p, Attr: attr, Old: old, New: r.loc})
+			if !opts.DryRun {
+				ol.SetAttr(attr, r.loc)
+				ol.SetAttr(previousAttr(prefix, attr), old)
+			}
+		}
+		return nil
+	})
+	return changes
+}
@@ -0,0 +1,97 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRewriteRedirects(t *testing.T) {
+	src := []byte(`<opml version="2.0"><head><title>Feeds</title></head><body>
+<outline text="Folder">
+  <outline text="A" type="rss" xmlUrl="http://feeds.feedburner.com/a" htmlUrl="http://a.example.org/"/>
+  <outline text="B" type="rss" xmlUrl="https://b.example.org/feed" previousXmlUrl="http://b.example.org/rss"/>
+</outline>
+<outline text="C" type="rss" xmlUrl="http://c.example.org/feed"/>
+<outline text="A again" type="rss" xmlUrl="http://feeds.feedburner.com/a"/>
+</body></opml>`)
+	moved := map[string]string{
+		"http://feeds.feedburner.com/a": "https://a.example.org/feed",
+		"http://a.example.org/":         "https://a.example.org/",
+		"https://b.example.org/feed":    "https://b.example.org/feed.xml",
+	}
+	calls := map[string]int{}
+	resolver := RedirectResolverFunc(func(ref string) (string, error) {
+		calls[ref]++
+		if ref == "http://c.example.org/feed" {
+			return "", fmt.Errorf("timeout")
+		}
+		return moved[ref], nil
+	})
+
+	o, err := Parse(src)
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	changes := o.RewriteRedirects(RedirectOptions{Resolver: resolver, DryRun: true})
+	expected := `/1/1 xmlUrl http://feeds.feedburner.com/a -> https://a.example.org/feed
+/1/1 htmlUrl http://a.example.org/ -> https://a.example.org/
+/1/2 xmlUrl https://b.example.org/feed -> https://b.example.org/feed.xml
+/2 xmlUrl http://c.example.org/feed: timeout
+/3 xmlUrl http://feeds.feedburner.com/a -> https://a.example.org/feed
+`
+	if s := changes.String(); s != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, s)
+	}
+	if calls["http://feeds.feedburner.com/a"] != 1 {
+		t.Errorf("expected each URL to be resolved once, got %v", calls)
+	}
+	if errs := changes.Errors(); len(errs) != 1 || errs[0].Path.String() != "/2" {
+		t.Errorf("expected one error at /2, got %v", errs)
+	}
+	if o.Body.Outline[0].Outline[0].XMLURL != "http://feeds.feedburner.com/a" {
+		t.Errorf("a dry run changed the outline")
+	}
+
+	changes = o.RewriteRedirects(RedirectOptions{Resolver: resolver, Attrs: []string{"xmlUrl"}})
+	if len(changes) != 4 {
+		t.Errorf("expected 4 changes, got %d", len(changes))
+	}
+	a := o.Body.Outline[0].Outline[0]
+	if a.XMLURL != "https://a.example.org/feed" || a.HTMLURL != "http://a.example.org/" {
+		t.Errorf("unexpected urls %q %q", a.XMLURL, a.HTMLURL)
+	}
+	if s, _ := a.Attr("previousXmlUrl"); s != "http://feeds.feedburner.com/a" {
+		t.Errorf("expected previousXmlUrl to be recorded, got %q", s)
+	}
+	// A second move overwrites the earlier previous URL
+	b := o.Body.Outline[0].Outline[1]
+	if s, _ := b.Attr("previousXmlUrl"); s != "https://b.example.org/feed" || len(b.OtherAttr) != 1 {
+		t.Errorf("expected previousXmlUrl to be replaced, got %q %v", s, b.OtherAttr)
+	}
+	if c := o.Body.Outline[1]; c.XMLURL != "http://c.example.org/feed" || len(c.OtherAttr) != 0 {
+		t.Errorf("expected C to be unchanged, got %+v", c)
+	}
+
+	changes = o.RewriteRedirects(RedirectOptions{Resolver: resolver, Prefix: "old"})
+	if s, _ := a.Attr("oldHtmlUrl"); s != "http://a.example.org/" || len(changes) != 2 {
+		t.Errorf("expected the htmlUrl to be rewritten with oldHtmlUrl, got %q %v", s, changes)
+	}
+}
@@ -0,0 +1,232 @@
+/*
+river is a Go package for aggregating the feeds of an OPML outline
+into a river of news.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package river
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultCallback is the function River.js pages expect river.js
+	// to call
+	DefaultCallback = "onGetRiverStream"
+
+	// DefaultRiverItems is the number of items in a river when
+	// RiverOptions.MaxItems isn't set
+	DefaultRiverItems = 250
+
+	// MaxBodyLength is the number of characters of an item's body
+	// kept in the river
+	MaxBodyLength = 280
+)
+
+// River is a river of news in the River.js format, see
+// http://riverjs.org/
+type River struct {
+	UpdatedFeeds UpdatedFeeds `json:"updatedFeeds"`
+	Metadata     Metadata     `json:"metadata"`
+}
+
+// UpdatedFeeds holds the feeds of a river
+type UpdatedFeeds struct {
+	UpdatedFeed []*UpdatedFeed `json:"updatedFeed"`
+}
+
+// UpdatedFeed is a feed and its items in a river, dates are in
+// RFC 1123 format in GMT
+type UpdatedFeed struct {
+	FeedURL         string       `json:"feedUrl"`
+	WebsiteURL      string       `json:"websiteUrl"`
+	FeedTitle       string       `json:"feedTitle"`
+	FeedDescription string       `json:"feedDescription"`
+	WhenLastUpdate  string       `json:"whenLastUpdate"`
+	Item            []*RiverItem `json:"item"`
+}
+
+// RiverItem is an item in a river
+type RiverItem struct {
+	Body      string `json:"body"`
+	PermaLink string `json:"permaLink"`
+	PubDate   string `json:"pubDate"`
+	Title     string `json:"title"`
+	Link      string `json:"link"`
+	ID        string `json:"id"`
+}
+
+// Metadata describes a river
+type Metadata struct {
+	Name       string `json:"name"`
+	Docs       string `json:"docs"`
+	WhenGMT    string `json:"whenGMT"`
+	WhenLocal  string `json:"whenLocal"`
+	Version    string `json:"version"`
+	Aggregator string `json:"aggregator,omitempty"`
+}
+
+// RiverOptions control the items in a river, the zero value uses the
+// defaults
+type RiverOptions struct {
+	// MaxItems is the number of items in the river
+	MaxItems int
+
+	// Since leaves out items older than it, if it is zero items of
+	// any age are included
+	Since time.Time
+
+	// Aggregator names the program that built the river
+	Aggregator string
+}
+
+// formatDate returns t in the format used by River.js
+func formatDate(t time.Time) string {
+	return t.UTC().Format(http.TimeFormat)
+}
+
+// truncate returns s cut to n characters
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[0:n]) + "…"
+}
+
+// NewRiver returns the river of the newest items in feeds. The items
+// are grouped by feed, the feeds ordered by their newest item and the
+// items of a feed newest first.
+func NewRiver(feeds []*Feed, opts RiverOptions) *River {
+	if opts.MaxItems <= 0 {
+		opts.MaxItems = DefaultRiverItems
+	}
+	type entry struct {
+		feed *Feed
+		item *Item
+	}
+	entries := []entry{}
+	for _, f := range feeds {
+		for _, item := range f.Items {
+			if opts.Since.IsZero() || !item.When().Before(opts.Since) {
+				entries = append(entries, entry{feed: f, item: item})
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].item.When().After(entries[j].item.When())
+	})
+	if len(entries) > opts.MaxItems {
+		entries = entries[0:opts.MaxItems]
+	}
+
+	now := time.Now()
+	r := &River{
+		UpdatedFeeds: UpdatedFeeds{UpdatedFeed: []*UpdatedFeed{}},
+		Metadata: Metadata{
+			Name:       "river.js",
+			Docs:       "http://riverjs.org/",
+			WhenGMT:    formatDate(now),
+			WhenLocal:  now.Format("1/2/2006; 3:04:05 PM"),
+			Version:    "3",
+			Aggregator: opts.Aggregator,
+		},
+	}
+	groups := map[*Feed]*UpdatedFeed{}
+	for _, e := range entries {
+		uf, ok := groups[e.feed]
+		if !ok {
+			uf = &UpdatedFeed{
+				FeedURL:         e.feed.URL,
+				WebsiteURL:      e.feed.Link,
+				FeedTitle:       e.feed.Title,
+				FeedDescription: e.feed.Description,
+				WhenLastUpdate:  formatDate(e.item.When()),
+				Item:            []*RiverItem{},
+			}
+			groups[e.feed] = uf
+			r.UpdatedFeeds.UpdatedFeed = append(r.UpdatedFeeds.UpdatedFeed, uf)
+		}
+		uf.Item = append(uf.Item, &RiverItem{
+			Body:      truncate(e.item.Body, MaxBodyLength),
+			PermaLink: e.item.Link,
+			PubDate:   formatDate(e.item.When()),
+			Title:     e.item.Title,
+			Link:      e.item.Link,
+			ID:        e.item.ID,
+		})
+	}
+	return r
+}
+
+// WriteJSON writes the river as JSON. If callback isn't empty the JSON
+// is wrapped in a call to it, as River.js expects of river.js files.
+func (r *River) WriteJSON(w io.Writer, callback string) error {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(r); err != nil {
+		return err
+	}
+	src := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	if callback != "" {
+		src = []byte(fmt.Sprintf("%s (%s)", callback, src))
+	}
+	_, err := fmt.Fprintf(w, "%s\n", src)
+	return err
+}
+
+var htmlTemplate = template.Must(template.New("river").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 48em; margin: 0 auto; padding: 1em; }
+section { border-top: 1px solid #ccc; }
+.when { color: #666; font-size: smaller; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="when">Updated {{.River.Metadata.WhenGMT}}</p>
+{{range .River.UpdatedFeeds.UpdatedFeed}}<section>
+<h2>{{if .WebsiteURL}}<a href="{{.WebsiteURL}}">{{.FeedTitle}}</a>{{else}}{{.FeedTitle}}{{end}}</h2>
+<p class="when">{{.WhenLastUpdate}} <a href="{{.FeedURL}}">feed</a></p>
+<ul>
+{{range .Item}}<li>{{if .Link}}<a href="{{.Link}}">{{or .Title .Link}}</a>{{else}}{{.Title}}{{end}}
+{{if .Body}}<p>{{.Body}}</p>{{end}}<span class="when">{{.PubDate}}</span></li>
+{{end}}</ul>
+</section>
+{{end}}</body>
+</html>
+`))
+
+// WriteHTML writes the river as a static HTML page with title
+func (r *River) WriteHTML(w io.Writer, title string) error {
+	return htmlTemplate.Execute(w, struct {
+		Title string
+		River *River
+	}{Title: title, River: r})
+}
@@ -0,0 +1,293 @@
+/*
+river is a Go package for aggregating the feeds of an OPML outline
+into a river of news.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package river polls the feeds of an OPML subscription list, keeps
+// their items in a Store and renders them as a river of news, the
+// newest items first grouped by feed, in the River.js JSON format and
+// as a static HTML page.
+//
+//	store, err := river.NewFileStore("river-data")
+//	...
+//	a := river.New(store, river.Options{})
+//	updates, err := a.Poll(ctx, o)
+//	...
+//	feeds, err := store.Feeds()
+//	...
+//	r := river.NewRiver(feeds, river.RiverOptions{})
+//	err = r.WriteJSON(out, river.DefaultCallback)
+package river
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	// My Packages
+	"github.com/rsdoiel/opml"
+	"github.com/rsdoiel/opml/feed"
+	"github.com/rsdoiel/opml/fetch"
+)
+
+// DefaultMaxItems is the number of items kept for each feed when
+// Options.MaxItems isn't set
+const DefaultMaxItems = 100
+
+// Item is a feed item kept in the Store
+type Item struct {
+	// ID identifies the item in its feed, it is the item's id (guid),
+	// its link or a hash of its title and date
+	ID        string    `json:"id"`
+	Title     string    `json:"title,omitempty"`
+	Link      string    `json:"link,omitempty"`
+	Body      string    `json:"body,omitempty"`
+	Author    string    `json:"author,omitempty"`
+	PubDate   time.Time `json:"pub_date,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+}
+
+// When returns the time the item is placed in the river, its
+// publication date or when it was first seen if it has no date or is
+// dated in the future
+func (item *Item) When() time.Time {
+	if item.PubDate.IsZero() || item.PubDate.After(item.FirstSeen) {
+		return item.FirstSeen
+	}
+	return item.PubDate
+}
+
+// Feed is a feed kept in the Store with its newest items
+type Feed struct {
+	// URL is the feed's xmlUrl and Path the outline element it came
+	// from when it was last polled
+	URL  string           `json:"url"`
+	Path opml.OutlinePath `json:"path,omitempty"`
+
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Link        string `json:"link,omitempty"`
+
+	// LastChecked is when the feed was last polled and LastUpdated
+	// when new items were last found
+	LastChecked time.Time `json:"last_checked,omitempty"`
+	LastUpdated time.Time `json:"last_updated,omitempty"`
+
+	// Error is why the last poll failed, it is empty if it succeeded
+	Error string `json:"error,omitempty"`
+
+	// Items are newest first
+	Items []*Item `json:"items,omitempty"`
+}
+
+// Subscribed returns the feeds that are the xmlUrl of an outline
+// element in o, e.g. to leave the feeds removed from a subscription
+// list out of the river while keeping them in the store
+func Subscribed(feeds []*Feed, o *opml.OPML) []*Feed {
+	urls := map[string]bool{}
+	o.Walk(func(ol *opml.Outline) bool {
+		if ol.XMLURL != "" {
+			urls[ol.XMLURL] = true
+		}
+		return true
+	})
+	l := []*Feed{}
+	for _, f := range feeds {
+		if urls[f.URL] {
+			l = append(l, f)
+		}
+	}
+	return l
+}
+
+// Options control an Aggregator, the zero value uses the defaults
+type Options struct {
+	// Fetch configures the Fetcher used to poll the feeds, set
+	// Fetch.Cache so unchanged feeds are revalidated with a
+	// conditional GET
+	Fetch fetch.Options
+
+	// MaxItems is the number of items kept for each feed
+	MaxItems int
+}
+
+// Update is the outcome of polling one feed
+type Update struct {
+	URL  string           `json:"url"`
+	Path opml.OutlinePath `json:"path"`
+	// New is the number of items added
+	New int `json:"new"`
+	// Err is set if the feed couldn't be fetched or parsed
+	Err error `json:"-"`
+}
+
+func (u Update) String() string {
+	if u.Err != nil {
+		return fmt.Sprintf("%s %s: %s", u.Path, u.URL, u.Err)
+	}
+	return fmt.Sprintf("%s %s: %d new", u.Path, u.URL, u.New)
+}
+
+// Aggregator polls the feeds of an outline keeping their items in a
+// Store
+type Aggregator struct {
+	store   Store
+	fetcher *fetch.Fetcher
+	opts    Options
+}
+
+// New returns an Aggregator keeping feeds in store
+func New(store Store, opts Options) *Aggregator {
+	if opts.MaxItems <= 0 {
+		opts.MaxItems = DefaultMaxItems
+	}
+	return &Aggregator{store: store, fetcher: fetch.New(opts.Fetch), opts: opts}
+}
+
+// itemID returns the ID identifying item in its feed
+func itemID(item *feed.Item) string {
+	if item.ID != "" {
+		return item.ID
+	}
+	if item.Link != "" {
+		return item.Link
+	}
+	sum := sha256.Sum256([]byte(item.Title + "\n" + item.Date().Format(time.RFC3339)))
+	return hex.EncodeToString(sum[:])
+}
+
+// update adds the new items of a fetched feed to its record, it
+// returns the number of items added
+func (a *Aggregator) update(rec *Feed, res *fetch.Result, now time.Time) (int, error) {
+	rec.LastChecked = now
+	if res.Err != nil {
+		return 0, res.Err
+	}
+	if res.NotModified && len(rec.Items) > 0 {
+		return 0, nil
+	}
+	f, err := feed.Parse(res.Body)
+	if err != nil {
+		return 0, err
+	}
+	if f.Title != "" {
+		rec.Title = feed.PlainText(f.Title)
+	}
+	if rec.Title == "" && res.Outline != nil {
+		rec.Title = res.Outline.Text
+	}
+	rec.Description = feed.PlainText(f.Description)
+	if f.Link != "" {
+		rec.Link = f.Link
+	} else if res.Outline != nil {
+		rec.Link = res.Outline.HTMLURL
+	}
+
+	seen := map[string]bool{}
+	for _, item := range rec.Items {
+		seen[item.ID] = true
+	}
+	added := 0
+	for _, item := range f.Items {
+		id := itemID(item)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		body := item.Description
+		if body == "" {
+			body = item.Content
+		}
+		rec.Items = append(rec.Items, &Item{
+			ID:        id,
+			Title:     feed.PlainText(item.Title),
+			Link:      item.Link,
+			Body:      feed.PlainText(body),
+			Author:    item.Author,
+			PubDate:   item.Date(),
+			FirstSeen: now,
+		})
+		added++
+	}
+	if added > 0 {
+		rec.LastUpdated = now
+	}
+	sort.SliceStable(rec.Items, func(i, j int) bool {
+		return rec.Items[i].When().After(rec.Items[j].When())
+	})
+	if len(rec.Items) > a.opts.MaxItems {
+		rec.Items = rec.Items[0:a.opts.MaxItems]
+	}
+	return added, nil
+}
+
+// Poll fetches the feed of each outline element with an xmlUrl and
+// adds its new items to the store. The updates are returned in outline
+// order, a feed that can't be fetched or parsed is recorded with its
+// error. An error is returned if the store fails.
+func (a *Aggregator) Poll(ctx context.Context, o *opml.OPML) ([]Update, error) {
+	updates := []Update{}
+	for _, res := range a.fetcher.FetchAll(ctx, o) {
+		rec, err := a.store.Get(res.URL)
+		if err != nil {
+			return updates, err
+		}
+		if rec == nil {
+			rec = &Feed{URL: res.URL}
+		}
+		rec.Path = res.Path
+		added, err := a.update(rec, res, time.Now().UTC())
+		rec.Error = ""
+		if err != nil {
+			rec.Error = err.Error()
+		}
+		if err := a.store.Put(rec); err != nil {
+			return updates, err
+		}
+		updates = append(updates, Update{URL: res.URL, Path: res.Path, New: added, Err: err})
+	}
+	return updates, nil
+}
+
+// Run polls the feeds of o every interval until ctx is done, calling fn
+// with the updates after each poll, e.g. to render the river. If
+// interval isn't positive the feeds are polled once. Run returns ctx's
+// error when it is done or the first error from Poll or fn.
+func (a *Aggregator) Run(ctx context.Context, o *opml.OPML, interval time.Duration, fn func([]Update) error) error {
+	for {
+		updates, err := a.Poll(ctx, o)
+		if err != nil {
+			return err
+		}
+		if fn != nil {
+			if err := fn(updates); err != nil {
+				return err
+			}
+		}
+		if interval <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,188 @@
+/*
+river is a Go package for aggregating the feeds of an OPML outline
+into a river of news.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package river
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	// My Packages
+	"github.com/rsdoiel/opml"
+)
+
+// feedServer serves an RSS feed at /a with the items set and an Atom
+// feed at /b
+type feedServer struct {
+	mu    sync.Mutex
+	items []string
+}
+
+func (s *feedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.URL.Path {
+	case "/a":
+		fmt.Fprint(w, `<rss version="2.0"><channel><title>Feed &amp; A</title><link>https://a.example.org/</link>`)
+		for _, item := range s.items {
+			fmt.Fprint(w, item)
+		}
+		fmt.Fprint(w, `</channel></rss>`)
+	case "/b":
+		fmt.Fprint(w, `<feed xmlns="http://www.w3.org/2005/Atom"><title>B</title>
+<entry><id>b1</id><title>B one</title><link href="https://b.example.org/1"/><updated>2021-03-02T10:00:00Z</updated><summary>&lt;p&gt;Hello &lt;b&gt;world&lt;/b&gt;&lt;/p&gt;</summary></entry>
+</feed>`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	srv := &feedServer{items: []string{
+		`<item><guid>a1</guid><title>A one</title><link>https://a.example.org/1</link><pubDate>Mon, 01 Mar 2021 10:00:00 GMT</pubDate></item>`,
+		`<item><title>A two</title><link>https://a.example.org/2</link><pubDate>Wed, 03 Mar 2021 10:00:00 GMT</pubDate></item>`,
+	}}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	o := opml.New()
+	o.Body.Outline = []*opml.Outline{
+		{Text: "A", XMLURL: ts.URL + "/a"},
+		{Text: "News", Outline: []*opml.Outline{
+			{Text: "B", XMLURL: ts.URL + "/b"},
+			{Text: "Missing", XMLURL: ts.URL + "/missing"},
+		}},
+	}
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	a := New(store, Options{MaxItems: 2})
+	ctx := context.Background()
+
+	polls := 0
+	err = a.Run(ctx, o, 0, func(updates []Update) error {
+		polls++
+		if len(updates) != 3 {
+			t.Errorf("expected 3 updates, got %v", updates)
+			return nil
+		}
+		if updates[0].New != 2 || updates[1].New != 1 || updates[1].Path.String() != "/2/1" || updates[2].Err == nil {
+			t.Errorf("unexpected updates %v", updates)
+		}
+		return nil
+	})
+	if err != nil || polls != 1 {
+		t.Errorf("expected one poll, got %d, %v", polls, err)
+	}
+
+	// Only new items are added and the oldest are dropped
+	srv.mu.Lock()
+	srv.items = append(srv.items, `<item><guid>a3</guid><title>A three</title><pubDate>Fri, 05 Mar 2021 10:00:00 GMT</pubDate></item>`)
+	srv.mu.Unlock()
+	updates, err := a.Poll(ctx, o)
+	if err != nil || updates[0].New != 1 || updates[1].New != 0 {
+		t.Errorf("expected one new item, got %v, %v", updates, err)
+	}
+	rec, err := store.Get(ts.URL + "/a")
+	if err != nil || rec == nil {
+		t.Errorf("expected feed A to be stored, got %v", err)
+		t.FailNow()
+	}
+	if rec.Title != "Feed & A" || len(rec.Items) != 2 || rec.Items[0].ID != "a3" || rec.Items[1].ID != "https://a.example.org/2" {
+		t.Errorf("unexpected feed %+v", rec)
+	}
+	if rec, _ := store.Get(ts.URL + "/missing"); rec == nil || rec.Error == "" || !strings.Contains(rec.Error, "404") {
+		t.Errorf("expected the missing feed's error to be stored, got %+v", rec)
+	}
+
+	feeds, err := store.Feeds()
+	if err != nil || len(feeds) != 3 {
+		t.Errorf("expected 3 stored feeds, got %d, %v", len(feeds), err)
+		t.FailNow()
+	}
+	// Feeds removed from the outline are left out
+	o2 := opml.New()
+	o2.Body.Outline = []*opml.Outline{{Text: "B", XMLURL: ts.URL + "/b"}}
+	if l := Subscribed(feeds, o2); len(l) != 1 || l[0].URL != ts.URL+"/b" {
+		t.Errorf("expected feed B, got %+v", l)
+	}
+	feeds = Subscribed(feeds, o)
+
+	r := NewRiver(feeds, RiverOptions{})
+	groups := []string{}
+	for _, uf := range r.UpdatedFeeds.UpdatedFeed {
+		titles := []string{}
+		for _, item := range uf.Item {
+			titles = append(titles, item.Title)
+		}
+		groups = append(groups, uf.FeedTitle+": "+strings.Join(titles, ", "))
+	}
+	expected := "Feed & A: A three, A two; B: B one"
+	if s := strings.Join(groups, "; "); s != expected {
+		t.Errorf("expected river %q, got %q", expected, s)
+	}
+	b := r.UpdatedFeeds.UpdatedFeed[1]
+	if b.Item[0].Body != "Hello world" || b.Item[0].PubDate != "Tue, 02 Mar 2021 10:00:00 GMT" || b.WhenLastUpdate != b.Item[0].PubDate {
+		t.Errorf("unexpected item %+v", b.Item[0])
+	}
+
+	r = NewRiver(feeds, RiverOptions{MaxItems: 2, Since: time.Date(2021, 3, 2, 12, 0, 0, 0, time.UTC)})
+	if len(r.UpdatedFeeds.UpdatedFeed) != 1 || len(r.UpdatedFeeds.UpdatedFeed[0].Item) != 2 {
+		t.Errorf("expected the items since March 2nd, got %+v", r.UpdatedFeeds.UpdatedFeed)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := r.WriteJSON(buf, DefaultCallback); err != nil {
+		t.Errorf("%s", err)
+	}
+	if s := buf.String(); !strings.HasPrefix(s, "onGetRiverStream ({") || !strings.HasSuffix(s, "})\n") || !strings.Contains(s, `"updatedFeed": [`) || !strings.Contains(s, `"feedTitle": "Feed & A"`) {
+		t.Errorf("unexpected river.js %s", s)
+	}
+	buf.Reset()
+	if err := r.WriteHTML(buf, "News & Views"); err != nil {
+		t.Errorf("%s", err)
+	}
+	if s := buf.String(); !strings.Contains(s, "<title>News &amp; Views</title>") || !strings.Contains(s, `<a href="https://a.example.org/">Feed &amp; A</a>`) {
+		t.Errorf("unexpected HTML %s", s)
+	}
+}
+
+func TestItemWhen(t *testing.T) {
+	seen := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, item := range []*Item{
+		{FirstSeen: seen},
+		{FirstSeen: seen, PubDate: seen.Add(time.Hour)},
+	} {
+		if !item.When().Equal(seen) {
+			t.Errorf("expected %s, got %s", seen, item.When())
+		}
+	}
+	item := &Item{FirstSeen: seen, PubDate: seen.Add(-time.Hour)}
+	if !item.When().Equal(item.PubDate) {
+		t.Errorf("expected the publication date, got %s", item.When())
+	}
+}
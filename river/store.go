@@ -0,0 +1,134 @@
+/*
+river is a Go package for aggregating the feeds of an OPML outline
+into a river of news.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package river
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Store keeps the feeds polled by an Aggregator and their items. Get
+// returns nil and no error for a feed that isn't stored.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	Get(url string) (*Feed, error)
+	Put(feed *Feed) error
+	Feeds() ([]*Feed, error)
+}
+
+// FileStore keeps each feed as a JSON file in a directory, the files
+// are named by the SHA-256 hash of the feed's URL
+type FileStore struct {
+	Dir string
+}
+
+// NewFileStore returns a FileStore in dir creating it if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0775); err != nil {
+		return nil, err
+	}
+	return &FileStore{Dir: dir}, nil
+}
+
+// fname returns the file name of a feed
+func (s *FileStore) fname(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// read decodes the feed in fname
+func (s *FileStore) read(fname string) (*Feed, error) {
+	src, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	feed := new(Feed)
+	if err := json.Unmarshal(src, feed); err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
+
+// Get reads the feed with url
+func (s *FileStore) Get(url string) (*Feed, error) {
+	feed, err := s.read(s.fname(url))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if feed.URL != url {
+		return nil, nil
+	}
+	return feed, nil
+}
+
+// Put writes feed, the file is replaced atomically so concurrent
+// readers never see a partial feed
+func (s *FileStore) Put(feed *Feed) error {
+	src, err := json.Marshal(feed)
+	if err != nil {
+		return err
+	}
+	fp, err := ioutil.TempFile(s.Dir, ".feed-*")
+	if err != nil {
+		return err
+	}
+	if _, err := fp.Write(src); err != nil {
+		fp.Close()
+		os.Remove(fp.Name())
+		return err
+	}
+	if err := fp.Close(); err != nil {
+		os.Remove(fp.Name())
+		return err
+	}
+	return os.Rename(fp.Name(), s.fname(feed.URL))
+}
+
+// Feeds reads all the stored feeds sorted by URL
+func (s *FileStore) Feeds() ([]*Feed, error) {
+	infos, err := ioutil.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	feeds := []*Feed{}
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		feed, err := s.read(filepath.Join(s.Dir, name))
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, feed)
+	}
+	sort.Slice(feeds, func(i, j int) bool {
+		return feeds[i].URL < feeds[j].URL
+	})
+	return feeds, nil
+}
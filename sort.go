@@ -0,0 +1,375 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// SortKind says how the values of a SortKey are compared
+type SortKind string
+
+const (
+	// SortString compares values as strings
+	SortString SortKind = ""
+	// SortDate compares values as dates, see ParseDate. Missing and
+	// invalid dates sort before valid ones.
+	SortDate SortKind = "date"
+	// SortNumber compares values as numbers, missing and invalid
+	// numbers sort before valid ones
+	SortNumber SortKind = "number"
+	// SortHost compares the host of URL values ignoring case
+	SortHost SortKind = "host"
+)
+
+// SortChildren is the name of the SortKey comparing the number of
+// children of outline elements
+const SortChildren = "children"
+
+// SortKey is an outline attribute to sort by, see SortBy
+type SortKey struct {
+	// Name is the attribute, e.g. "text", "title", "type" or a name
+	// in OtherAttr ("local" or "space:local"), or SortChildren
+	Name string
+	// Kind says how values are compared, "created" is always compared
+	// as a date and SortChildren as a number
+	Kind SortKind
+	// Descending reverses the order
+	Descending bool
+	// CaseInsensitive ignores case
+	CaseInsensitive bool
+	// Natural compares runs of digits as numbers so "Episode 9" sorts
+	// before "Episode 10"
+	Natural bool
+}
+
+// String returns the key as accepted by ParseSortKeys
+func (k SortKey) String() string {
+	s := k.Name
+	if k.Kind != SortString {
+		s += "/" + string(k.Kind)
+	}
+	if k.Descending {
+		return "-" + s
+	}
+	return s
+}
+
+// ParseSortKeys parses a comma separated list of attribute names, a
+// name starting with "-" sorts in descending order and a name may end
+// with "/date", "/number" or "/host" to set the kind of the key, e.g.
+// "type,-title", "lastRead/date", "dc:date/date" or "xmlUrl/host,text".
+// The kind is separated by "/" as it can't appear in an attribute name.
+func ParseSortKeys(s string) ([]SortKey, error) {
+	keys := []SortKey{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		k := SortKey{Name: name}
+		if strings.HasPrefix(name, "-") {
+			k.Name, k.Descending = name[1:], true
+		}
+		if i := strings.Index(k.Name, "/"); i >= 0 {
+			switch kind := SortKind(k.Name[i+1:]); kind {
+			case SortDate, SortNumber, SortHost:
+				k.Name, k.Kind = k.Name[:i], kind
+			default:
+				return nil, fmt.Errorf("unknown kind %q in sort key %q, expected date, number or host", kind, name)
+			}
+		}
+		if k.Name == "" {
+			return nil, fmt.Errorf("missing attribute name in sort key %q", name)
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no sort keys in %q", s)
+	}
+	return keys, nil
+}
+
+// SortOptions control SortBy
+type SortOptions struct {
+	// FoldersFirst sorts outline elements with children before those
+	// without
+	FoldersFirst bool
+
+	// Depth is the number of levels sorted, the top level is 1. All
+	// levels are sorted if it is zero.
+	Depth int
+
+	// Language is a BCP 47 language tag, e.g. "de" or "sv", if set
+	// values are compared with the language's Unicode collation
+	// instead of byte by byte
+	Language string
+}
+
+// sorter compares outline elements by a list of keys
+type sorter struct {
+	opts SortOptions
+	keys []SortKey
+	// collators holds a collator for each key if opts.Language is set
+	collators []*collate.Collator
+}
+
+func newSorter(opts SortOptions, keys []SortKey) (*sorter, error) {
+	s := &sorter{opts: opts, keys: keys}
+	if opts.Language != "" {
+		tag, err := language.Parse(opts.Language)
+		if err != nil {
+			return nil, fmt.Errorf("bad sort language %q, %s", opts.Language, err)
+		}
+		for _, k := range keys {
+			options := []collate.Option{}
+			if k.CaseInsensitive {
+				options = append(options, collate.IgnoreCase)
+			}
+			if k.Natural {
+				options = append(options, collate.Numeric)
+			}
+			s.collators = append(s.collators, collate.New(tag, options...))
+		}
+	}
+	return s, nil
+}
+
+// isDigit reports if c is an ASCII digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// compareNatural compares a and b treating runs of digits as numbers
+func compareNatural(a string, b string) int {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			// Compare the numbers without leading zeros by length then
+			// digit by digit
+			si, sj := i, j
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			x, y := strings.TrimLeft(a[si:i], "0"), strings.TrimLeft(b[sj:j], "0")
+			if len(x) != len(y) {
+				if len(x) < len(y) {
+					return -1
+				}
+				return 1
+			}
+			if c := strings.Compare(x, y); c != 0 {
+				return c
+			}
+			continue
+		}
+		if a[i] != b[j] {
+			if a[i] < b[j] {
+				return -1
+			}
+			return 1
+		}
+		i++
+		j++
+	}
+	switch {
+	case len(a)-i < len(b)-j:
+		return -1
+	case len(a)-i > len(b)-j:
+		return 1
+	}
+	return 0
+}
+
+// compareKey compares the values of the i-th key
+func (s *sorter) compareKey(i int, a string, b string) int {
+	k := s.keys[i]
+	if s.collators != nil {
+		return s.collators[i].CompareString(a, b)
+	}
+	if k.CaseInsensitive {
+		a, b = strings.ToLower(a), strings.ToLower(b)
+	}
+	if k.Natural {
+		return compareNatural(a, b)
+	}
+	return strings.Compare(a, b)
+}
+
+// kind returns the kind of the i-th key
+func (s *sorter) kind(i int) SortKind {
+	k := s.keys[i]
+	switch {
+	case k.Name == SortChildren:
+		return SortNumber
+	case k.Name == "created" && k.Kind == SortString:
+		return SortDate
+	}
+	return k.Kind
+}
+
+// sortValue returns the value of the named key for ol
+func sortValue(ol *Outline, name string) string {
+	if name == SortChildren {
+		return strconv.Itoa(len(ol.Outline))
+	}
+	s, _ := ol.Attr(name)
+	return s
+}
+
+// compareOK orders missing or invalid values before valid ones, it
+// returns false if both are valid
+func compareOK(okA bool, okB bool) (int, bool) {
+	switch {
+	case okA && okB:
+		return 0, false
+	case okA:
+		return 1, true
+	case okB:
+		return -1, true
+	}
+	return 0, true
+}
+
+// compareValues compares the values of the i-th key by its kind
+func (s *sorter) compareValues(i int, a string, b string) int {
+	switch s.kind(i) {
+	case SortDate:
+		x, errA := ParseDate(a)
+		y, errB := ParseDate(b)
+		if c, done := compareOK(a != "" && errA == nil, b != "" && errB == nil); done {
+			return c
+		}
+		return compareTime(x, y)
+	case SortNumber:
+		x, errA := strconv.ParseFloat(strings.TrimSpace(a), 64)
+		y, errB := strconv.ParseFloat(strings.TrimSpace(b), 64)
+		if c, done := compareOK(errA == nil, errB == nil); done {
+			return c
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case SortHost:
+		return s.compareKey(i, urlHost(a), urlHost(b))
+	}
+	return s.compareKey(i, a, b)
+}
+
+// compareTime returns -1, 0 or 1 as x is before, equal to or after y
+func compareTime(x time.Time, y time.Time) int {
+	switch {
+	case x.Before(y):
+		return -1
+	case x.After(y):
+		return 1
+	}
+	return 0
+}
+
+// urlHost returns the lower cased host of a URL, or s if it isn't one
+func urlHost(s string) string {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil || u.Host == "" {
+		return strings.ToLower(s)
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// compare returns -1, 0 or 1 as a sorts before, with or after b
+func (s *sorter) compare(a *Outline, b *Outline) int {
+	if s.opts.FoldersFirst {
+		if fa, fb := a.HasChildren(), b.HasChildren(); fa != fb {
+			if fa {
+				return -1
+			}
+			return 1
+		}
+	}
+	for i, k := range s.keys {
+		c := s.compareValues(i, sortValue(a, k.Name), sortValue(b, k.Name))
+		if k.Descending {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// sort does a stable sort of list and the children of its elements
+// down to the sorter's depth, depth is the level of list
+func (s *sorter) sort(list []*Outline, depth int) {
+	if s.opts.Depth > 0 && depth > s.opts.Depth {
+		return
+	}
+	for _, ol := range list {
+		if ol != nil && len(ol.Outline) > 0 {
+			s.sort(ol.Outline, depth+1)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i] == nil || list[j] == nil {
+			return list[j] == nil && list[i] != nil
+		}
+		return s.compare(list[i], list[j]) < 0
+	})
+}
+
+// SortOutlines sorts a list of outline elements and their children by
+// keys, see SortBy
+func SortOutlines(list []*Outline, opts SortOptions, keys ...SortKey) error {
+	s, err := newSorter(opts, keys)
+	if err != nil {
+		return err
+	}
+	s.sort(list, 1)
+	return nil
+}
+
+// SortBy sorts the outline by one or more keys, elements with the same
+// value for the first key are sorted by the second and so on. The sort
+// is stable so elements that compare equal keep their order. An error
+// is returned if opts.Language isn't a valid language tag.
+//
+//	err := o.SortBy(opml.SortOptions{FoldersFirst: true},
+//	    opml.SortKey{Name: "type"},
+//	    opml.SortKey{Name: "priority", Kind: opml.SortNumber, Descending: true},
+//	    opml.SortKey{Name: "title", CaseInsensitive: true})
+func (o *OPML) SortBy(opts SortOptions, keys ...SortKey) error {
+	if o.Body == nil {
+		return nil
+	}
+	return SortOutlines(o.Body.Outline, opts, keys...)
+}
@@ -0,0 +1,160 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSortBy(t *testing.T) {
+	src := []byte(`<opml version="2.0"><head></head><body>
+<outline text="Episode 10" type="rss" />
+<outline text="episode 9" type="rss" />
+<outline text="Zebra" type="link" />
+<outline text="Folder">
+  <outline text="b" />
+  <outline text="a" />
+</outline>
+<outline text="Äpfel" type="link" />
+<outline text="Episode 9" type="rss" />
+</body></opml>`)
+	sorted := func(opts SortOptions, keys ...SortKey) string {
+		o, err := Parse(src)
+		if err != nil {
+			t.Errorf("%s", err)
+			t.FailNow()
+		}
+		if err := o.SortBy(opts, keys...); err != nil {
+			t.Errorf("%s", err)
+			t.FailNow()
+		}
+		return outlineText(o)
+	}
+
+	expected := `/1 Episode 10
+/2 Episode 9
+/3 Folder
+/3/1 a
+/3/2 b
+/4 Zebra
+/5 episode 9
+/6 Äpfel`
+	if s := sorted(SortOptions{}, SortKey{Name: "text"}); s != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, s)
+	}
+
+	// Natural, case insensitive and stable
+	expected = `/1 Äpfel
+/2 episode 9
+/3 Episode 9
+/4 Episode 10
+/5 Folder
+/5/1 a
+/5/2 b
+/6 Zebra`
+	if s := sorted(SortOptions{Language: "de"}, SortKey{Name: "text", CaseInsensitive: true, Natural: true}); s != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, s)
+	}
+	if s := sorted(SortOptions{}, SortKey{Name: "text", CaseInsensitive: true, Natural: true}); !strings.HasSuffix(s, "/6 Äpfel") {
+		t.Errorf("expected Äpfel last without collation, got\n%s", s)
+	}
+
+	// Multiple keys, descending, folders first and depth
+	expected = `/1 Folder
+/1/1 b
+/1/2 a
+/2 Episode 9
+/3 Episode 10
+/4 episode 9
+/5 Zebra
+/6 Äpfel`
+	if s := sorted(SortOptions{FoldersFirst: true, Depth: 1}, SortKey{Name: "type", Descending: true}, SortKey{Name: "text", Natural: true}); s != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, s)
+	}
+
+	keys, err := ParseSortKeys("type, -title")
+	if err != nil || len(keys) != 2 || keys[0].Name != "type" || !keys[1].Descending || keys[1].Name != "title" {
+		t.Errorf("unexpected keys %+v, %v", keys, err)
+	}
+	keys, err = ParseSortKeys("-lastRead/date,xmlUrl/host,team:owner")
+	if err != nil || len(keys) != 3 || keys[0].Kind != SortDate || !keys[0].Descending || keys[1].Kind != SortHost || keys[2].Name != "team:owner" {
+		t.Errorf("unexpected keys %+v, %v", keys, err)
+	}
+	// A namespaced attribute whose local name is a kind
+	keys, err = ParseSortKeys("dc:date,-dc:date/date")
+	if err != nil || len(keys) != 2 || keys[0].Name != "dc:date" || keys[0].Kind != SortString || keys[1].Name != "dc:date" || keys[1].Kind != SortDate {
+		t.Errorf("unexpected keys %+v, %v", keys, err)
+	}
+	if s := keys[1].String(); s != "-dc:date/date" {
+		t.Errorf("expected -dc:date/date, got %q", s)
+	}
+	if _, err := ParseSortKeys("lastRead/time"); err == nil {
+		t.Errorf("expected an error for an unknown kind")
+	}
+	if _, err := ParseSortKeys("-"); err == nil {
+		t.Errorf("expected an error for a key without a name")
+	}
+	o, _ := Parse(src)
+	if err := o.SortBy(SortOptions{Language: "not a language"}, SortKey{Name: "text"}); err == nil {
+		t.Errorf("expected an error for a bad language")
+	}
+}
+
+func TestSortByKinds(t *testing.T) {
+	src := []byte(`<opml version="2.0"><head></head><body>
+<outline text="A" priority="10" created="Mon, 02 Jan 2006 15:04:05 GMT" xmlUrl="http://www.example.org/a" />
+<outline text="B" priority="9" created="Sun, 01 Jan 2006 15:04:05 +0000" xmlUrl="https://Alpha.example.org/b" />
+<outline text="C" created="Tue, 03 Jan 2006 08:00:00 -0800">
+  <outline text="C1" />
+  <outline text="C2" />
+</outline>
+<outline text="D" priority="2" xmlUrl="http://beta.example.org/d">
+  <outline text="D1" />
+</outline>
+</body></opml>`)
+	order := func(keys ...SortKey) string {
+		o, err := Parse(src)
+		if err != nil {
+			t.Errorf("%s", err)
+			t.FailNow()
+		}
+		if err := o.SortBy(SortOptions{Depth: 1}, keys...); err != nil {
+			t.Errorf("%s", err)
+			t.FailNow()
+		}
+		s := ""
+		for _, ol := range o.Body.Outline {
+			s += ol.Text
+		}
+		return s
+	}
+	for expected, keys := range map[string][]SortKey{
+		// Lexically "10" < "2" < "9"
+		"CADB": {{Name: "priority"}},
+		"CDBA": {{Name: "priority", Kind: SortNumber}},
+		"DBAC": {{Name: "created"}},
+		"CABD": {{Name: "created", Descending: true}},
+		"CBDA": {{Name: "xmlUrl", Kind: SortHost}},
+		"CDAB": {{Name: SortChildren, Descending: true}},
+	} {
+		if s := order(keys...); s != expected {
+			t.Errorf("%v expected %s, got %s", keys, expected, s)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// SetAttr sets the named outline attribute. Names are the OPML
+// attribute names (e.g. "text", "xmlUrl"), any other name is set in
+// OtherAttr. An error is returned if a boolean attribute's value isn't
+// "true" or "false".
+func (ol *Outline) SetAttr(name string, value string) error {
+	switch name {
+	case "text":
+		ol.Text = value
+	case "type":
+		ol.Type = value
+	case "title":
+		ol.Title = value
+	case "isComment", "isBreakpoint":
+		b := false
+		if value != "" {
+			var err error
+			if b, err = strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("%s must be true or false, got %q", name, value)
+			}
+		}
+		if name == "isComment" {
+			ol.IsComment = b
+		} else {
+			ol.IsBreakpoint = b
+		}
+	case "created":
+		ol.Created = value
+	case "category":
+		ol.Category = value
+	case "xmlUrl":
+		ol.XMLURL = value
+	case "htmlUrl":
+		ol.HTMLURL = value
+	case "language":
+		ol.Language = value
+	case "description":
+		ol.Description = value
+	case "version":
+		ol.Version = value
+	case "url":
+		ol.URL = value
+	default:
+		for i, attr := range ol.OtherAttr {
+			if attr.Name.Local == name && attr.Name.Space == "" {
+				ol.OtherAttr[i].Value = value
+				return nil
+			}
+		}
+		ol.OtherAttr = append(ol.OtherAttr, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+	}
+	return nil
+}
+
+// Decoder reads an OPML document from an io.Reader one outline element
+// at a time. Unlike Parse it doesn't hold the document in memory so it
+// can be used on outlines of any size.
+//
+//	dec := opml.NewDecoder(r)
+//	for {
+//	    ol, p, err := dec.Next()
+//	    if err == io.EOF {
+//	        break
+//	    }
+//	    ...
+//	}
+type Decoder struct {
+	d *xml.Decoder
+
+	version   string
+	otherAttr CustomAttrs
+	head      *Head
+	inBody    bool
+
+	// names is the stack of open element names
+	names []string
+	// path is the path of the open outline element, counts[i] is the
+	// number of outline elements seen so far at depth i+1
+	path   OutlinePath
+	counts []int
+}
+
+// NewDecoder returns a Decoder reading from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		d:      xml.NewDecoder(r),
+		counts: []int{0},
+	}
+}
+
+// Version returns the opml element's version attribute, it is empty
+// until the opml element has been read.
+func (dec *Decoder) Version() string {
+	return dec.version
+}
+
+// OtherAttr returns the opml element's other attributes, they are
+// empty until the opml element has been read.
+func (dec *Decoder) OtherAttr() CustomAttrs {
+	return dec.otherAttr
+}
+
+// Head returns the document's head element, reading the document up to
+// the head if needed. It returns nil if there is no head before the
+// body.
+func (dec *Decoder) Head() (*Head, error) {
+	for dec.head == nil && !dec.inBody {
+		ol, err := dec.token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ol != nil {
+			// Can't happen, outline elements are only read in the body
+			return nil, fmt.Errorf("outline element before body")
+		}
+	}
+	return dec.head, nil
+}
+
+// Next returns the next outline element in document order and its path,
+// the depth of the element is len(p). The returned element doesn't hold
+// its children, they are returned by the following calls to Next. At
+// the end of the document Next returns io.EOF.
+func (dec *Decoder) Next() (*Outline, OutlinePath, error) {
+	for {
+		ol, err := dec.token()
+		if err != nil {
+			return nil, nil, err
+		}
+		if ol != nil {
+			return ol, append(OutlinePath{}, dec.path...), nil
+		}
+	}
+}
+
+// parent returns the name of the innermost open element
+func (dec *Decoder) parent() string {
+	if len(dec.names) == 0 {
+		return ""
+	}
+	return dec.names[len(dec.names)-1]
+}
+
+// token reads the next token returning an outline element if the token
+// started one.
+func (dec *Decoder) token() (*Outline, error) {
+	tok, err := dec.d.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case xml.StartElement:
+		parent := dec.parent()
+		switch {
+		case t.Name.Local == "opml" && parent == "":
+			for _, attr := range t.Attr {
+				if attr.Name.Local == "version" {
+					dec.version = attr.Value
+				} else {
+					dec.otherAttr = append(dec.otherAttr, attr)
+				}
+			}
+		case t.Name.Local == "head" && parent == "opml":
+			h := new(Head)
+			if err := dec.d.DecodeElement(h, &t); err != nil {
+				return nil, err
+			}
+			dec.head = h
+			return nil, nil
+		case t.Name.Local == "body" && parent == "opml":
+			dec.inBody = true
+		case t.Name.Local == "outline" && (parent == "body" || parent == "outline"):
+			ol := &Outline{XMLName: t.Name}
+			// Attributes are mapped as Parse and ParseWith do
+			for _, attr := range t.Attr {
+				if err := setOutlineAttr(ol, attr); err != nil {
+					return nil, err
+				}
+			}
+			dec.counts[len(dec.counts)-1]++
+			dec.path = append(dec.path, dec.counts[len(dec.counts)-1])
+			dec.counts = append(dec.counts, 0)
+			dec.names = append(dec.names, t.Name.Local)
+			return ol, nil
+		case parent == "body" || parent == "outline":
+			// Skip unknown elements in the outline
+			return nil, dec.d.Skip()
+		}
+		dec.names = append(dec.names, t.Name.Local)
+	case xml.EndElement:
+		if len(dec.names) == 0 {
+			return nil, fmt.Errorf("unexpected end element %s", t.Name.Local)
+		}
+		name := dec.parent()
+		dec.names = dec.names[:len(dec.names)-1]
+		switch name {
+		case "outline":
+			dec.path = dec.path[:len(dec.path)-1]
+			dec.counts = dec.counts[:len(dec.counts)-1]
+		case "body":
+			dec.inBody = false
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,229 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	for _, fname := range []string{"testdata/example2.opml", "testdata/example4.opml", "testdata/feeds.opml"} {
+		o, err := ReadFile(fname)
+		if err != nil {
+			t.Errorf("%s", err)
+			continue
+		}
+		expected := []string{}
+		o.WalkWithPath(func(ol *Outline, p OutlinePath, depth int, parent *Outline) error {
+			expected = append(expected, p.String()+" "+ol.Text+" "+ol.XMLURL)
+			return nil
+		})
+
+		fp, err := os.Open(fname)
+		if err != nil {
+			t.Errorf("%s", err)
+			continue
+		}
+		dec := NewDecoder(fp)
+		h, err := dec.Head()
+		if err != nil {
+			t.Errorf("%s: %s", fname, err)
+		} else if h == nil || h.Title != o.Head.Title {
+			t.Errorf("%s: expected head title %q, got %+v", fname, o.Head.Title, h)
+		}
+		if dec.Version() != o.Version {
+			t.Errorf("%s: expected version %q, got %q", fname, o.Version, dec.Version())
+		}
+		got := []string{}
+		for {
+			ol, p, err := dec.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("%s: %s", fname, err)
+				break
+			}
+			if len(ol.Outline) != 0 {
+				t.Errorf("%s %s: expected no children, got %d", fname, p, len(ol.Outline))
+			}
+			got = append(got, p.String()+" "+ol.Text+" "+ol.XMLURL)
+		}
+		fp.Close()
+		if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("%s: expected\n%s\ngot\n%s", fname, strings.Join(expected, "\n"), strings.Join(got, "\n"))
+		}
+	}
+
+	// Syntax errors are reported by Next
+	dec := NewDecoder(strings.NewReader(`<opml version="2.0"><body><outline text="a & b"/></body></opml>`))
+	if _, _, err := dec.Next(); err == nil || err == io.EOF {
+		t.Errorf("expected a syntax error, got %v", err)
+	}
+}
+
+func TestDecoderMatchesParse(t *testing.T) {
+	fnames, err := filepath.Glob("testdata/*.opml")
+	if err != nil || len(fnames) == 0 {
+		t.Errorf("can't find testdata, %v", err)
+		t.FailNow()
+	}
+	sources := map[string][]byte{
+		"namespaced": []byte(`<opml version="2.0" xmlns:t="http://example.org/t"><body>
+<outline t:text="spaced" t:owner="alice" isComment=" true " t:isBreakpoint="false" custom="1">
+  <outline text="child" t:xmlUrl="https://example.org/feed" custom="2"/>
+</outline>
+</body></opml>`),
+	}
+	for _, fname := range fnames {
+		src, err := ioutil.ReadFile(fname)
+		if err != nil {
+			t.Errorf("%s", err)
+			continue
+		}
+		sources[fname] = src
+	}
+	for name, src := range sources {
+		o, err := ParseWith(src, ParseOptions{})
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		expected := []*Outline{}
+		o.Walk(func(ol *Outline) bool {
+			cp := *ol
+			cp.Outline = nil
+			expected = append(expected, &cp)
+			return true
+		})
+		got := []*Outline{}
+		dec := NewDecoder(bytes.NewReader(src))
+		for {
+			ol, _, err := dec.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("%s: %s", name, err)
+				break
+			}
+			got = append(got, ol)
+		}
+		if len(got) != len(expected) {
+			t.Errorf("%s: expected %d outline elements, got %d", name, len(expected), len(got))
+			continue
+		}
+		for i := range expected {
+			if !reflect.DeepEqual(expected[i], got[i]) {
+				t.Errorf("%s: expected\n%+v\ngot\n%+v", name, expected[i], got[i])
+			}
+		}
+		if dec.Version() != o.Version {
+			t.Errorf("%s: expected version %q, got %q", name, o.Version, dec.Version())
+		}
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	for _, fname := range []string{"testdata/example2.opml", "testdata/example4.opml", "testdata/feeds.opml"} {
+		o, err := ReadFile(fname)
+		if err != nil {
+			t.Errorf("%s", err)
+			continue
+		}
+		src, err := os.ReadFile(fname)
+		if err != nil {
+			t.Errorf("%s", err)
+			continue
+		}
+
+		// Copy the file element by element from a Decoder to an Encoder
+		buf := new(bytes.Buffer)
+		dec := NewDecoder(bytes.NewReader(src))
+		h, err := dec.Head()
+		if err != nil {
+			t.Errorf("%s: %s", fname, err)
+			continue
+		}
+		enc := NewEncoder(buf)
+		enc.Indent("", "  ")
+		if err := enc.Start(&OPML{Version: dec.Version(), Head: h}); err != nil {
+			t.Errorf("%s: %s", fname, err)
+			continue
+		}
+		for {
+			ol, p, err := dec.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("%s: %s", fname, err)
+				break
+			}
+			if err := enc.WriteOutline(ol, len(p)); err != nil {
+				t.Errorf("%s %s: %s", fname, p, err)
+				break
+			}
+		}
+		if err := enc.Close(); err != nil {
+			t.Errorf("%s: %s", fname, err)
+			continue
+		}
+
+		copied, err := Parse(buf.Bytes())
+		if err != nil {
+			t.Errorf("%s: %s\n%s", fname, err, buf.Bytes())
+			continue
+		}
+		if copied.String() != o.String() {
+			t.Errorf("%s: expected\n%s\ngot\n%s", fname, o, copied)
+		}
+	}
+
+	// Whole subtrees can be written and depth is checked
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+	if err := enc.Start(New()); err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if err := enc.WriteOutline(&Outline{Text: "a"}, 2); err == nil {
+		t.Errorf("expected an error writing at depth 2 with no open outline")
+	}
+	tree := &Outline{Text: "a", Outline: []*Outline{{Text: "b"}, {Text: "c", IsComment: true}}}
+	if err := enc.WriteOutline(tree, 1); err != nil {
+		t.Errorf("%s", err)
+	}
+	if err := enc.WriteOutline(&Outline{Text: "d"}, 1); err != nil {
+		t.Errorf("%s", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Errorf("%s", err)
+	}
+	expected := `<opml version="2.0"><head></head><body><outline text="a"><outline text="b"></outline><outline text="c" isComment="true"></outline></outline><outline text="d"></outline></body></opml>`
+	if buf.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, buf.String())
+	}
+}
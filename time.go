@@ -0,0 +1,198 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DateFormat is the RFC 822 date and time layout, with a four digit
+// year, used by the OPML 2.0 spec, e.g. "Mon, 23 May 2016 08:33:00 GMT"
+const DateFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+// rfc822Layouts are RFC 822 and RFC 1123 dates with and without the
+// day of the week and seconds
+var rfc822Layouts = []string{
+	time.RFC1123,
+	time.RFC1123Z,
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"Mon, 2 Jan 06 15:04:05 MST",
+	"Mon, 2 Jan 06 15:04:05 -0700",
+	"Mon, 2 Jan 06 15:04 MST",
+	"Mon, 2 Jan 06 15:04 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04 MST",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 06 15:04:05 MST",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04 MST",
+	"2 Jan 06 15:04 -0700",
+}
+
+// dateLayouts are tried in order by ParseDate
+var dateLayouts = append(rfc822Layouts,
+	// ISO 8601 variations
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	// Go's default time.Time String() and Unix date(1)
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	time.UnixDate,
+)
+
+// zoneOffsets are the time zones named in RFC 822, time.Parse doesn't
+// know their offsets unless they happen to be the local zone.
+var zoneOffsets = map[string]int{
+	"EST": -5 * 60 * 60,
+	"EDT": -4 * 60 * 60,
+	"CST": -6 * 60 * 60,
+	"CDT": -5 * 60 * 60,
+	"MST": -7 * 60 * 60,
+	"MDT": -6 * 60 * 60,
+	"PST": -8 * 60 * 60,
+	"PDT": -7 * 60 * 60,
+}
+
+// ParseDate parses a date and time leniently. It accepts RFC 822 and
+// RFC 1123 dates (two or four digit years, optional day of the week and
+// seconds, named or numeric zones) as well as common ISO 8601 forms.
+// Dates without a time zone are treated as UTC.
+func ParseDate(s string) (time.Time, error) {
+	s = strings.Join(strings.Fields(s), " ")
+	// Drop a trailing comment, e.g. "... -0800 (PST)"
+	if i := strings.Index(s, " ("); i > 0 && strings.HasSuffix(s, ")") {
+		s = s[0:i]
+	}
+	if s == "" {
+		return time.Time{}, fmt.Errorf("empty date")
+	}
+	// time.Parse wants three or more letters in a zone name
+	for _, zone := range []string{" UT", " Z"} {
+		if strings.HasSuffix(s, zone) {
+			s = strings.TrimSuffix(s, zone) + " GMT"
+		}
+	}
+	for _, layout := range dateLayouts {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			continue
+		}
+		if name, offset := t.Zone(); offset == 0 {
+			if off, ok := zoneOffsets[strings.ToUpper(name)]; ok && off != 0 {
+				t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.FixedZone(name, off))
+			}
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("can't parse date %q", s)
+}
+
+// IsRFC822 returns true if s is an RFC 822 (or RFC 1123) date and time
+// as required by the OPML 2.0 spec.
+func IsRFC822(s string) bool {
+	s = strings.Join(strings.Fields(s), " ")
+	for _, zone := range []string{" UT", " Z"} {
+		if strings.HasSuffix(s, zone) {
+			s = strings.TrimSuffix(s, zone) + " GMT"
+		}
+	}
+	for _, layout := range rfc822Layouts {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatDate returns t as an RFC 822 date in GMT, see DateFormat
+func FormatDate(t time.Time) string {
+	return t.UTC().Format(DateFormat)
+}
+
+// CreatedTime returns the head's dateCreated as a time.Time
+func (h *Head) CreatedTime() (time.Time, error) {
+	return ParseDate(h.Created)
+}
+
+// SetCreated sets the head's dateCreated in RFC 822 format
+func (h *Head) SetCreated(t time.Time) {
+	h.Created = FormatDate(t)
+}
+
+// ModifiedTime returns the head's dateModified as a time.Time
+func (h *Head) ModifiedTime() (time.Time, error) {
+	return ParseDate(h.Modified)
+}
+
+// SetModified sets the head's dateModified in RFC 822 format
+func (h *Head) SetModified(t time.Time) {
+	h.Modified = FormatDate(t)
+}
+
+// CreatedTime returns the outline's created attribute as a time.Time
+func (ol *Outline) CreatedTime() (time.Time, error) {
+	return ParseDate(ol.Created)
+}
+
+// SetCreated sets the outline's created attribute in RFC 822 format
+func (ol *Outline) SetCreated(t time.Time) {
+	ol.Created = FormatDate(t)
+}
+
+// NormalizeDates rewrites the dates in the head and the created
+// attribute of each outline element in RFC 822 format. Dates that
+// can't be parsed are left as is and reported in the returned error.
+func (o *OPML) NormalizeDates() error {
+	bad := []string{}
+	normalize := func(label string, s *string) {
+		if *s == "" {
+			return
+		}
+		t, err := ParseDate(*s)
+		if err != nil {
+			bad = append(bad, fmt.Sprintf("%s %q", label, *s))
+			return
+		}
+		*s = FormatDate(t)
+	}
+	if o.Head != nil {
+		normalize("dateCreated", &o.Head.Created)
+		normalize("dateModified", &o.Head.Modified)
+	}
+	o.WalkWithPath(func(ol *Outline, p OutlinePath, depth int, parent *Outline) error {
+		normalize(p.String(), &ol.Created)
+		return nil
+	})
+	if len(bad) > 0 {
+		return fmt.Errorf("can't parse dates, %s", strings.Join(bad, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	expected := time.Date(2016, time.May, 23, 8, 33, 0, 0, time.UTC)
+	for _, s := range []string{
+		"Mon, 23 May 2016 08:33:00 GMT",
+		"Mon, 23 May 2016 08:33:00 +0000",
+		"Mon, 23 May 16 08:33:00 GMT",
+		"Mon, 23 May 16 08:33 GMT",
+		"23 May 2016 08:33:00 UT",
+		"Mon, 23 May 2016 01:33:00 PDT",
+		"Mon, 23 May 2016 04:33:00 EDT",
+		"Mon, 23 May 2016 01:33:00 -0700 (PDT)",
+		"  Mon,  23 May 2016   08:33:00 GMT ",
+		"2016-05-23T08:33:00Z",
+		"2016-05-23T10:33:00+02:00",
+		"2016-05-23T08:33:00.000Z",
+		"2016-05-23T08:33:00",
+		"2016-05-23 08:33:00",
+		"2016-05-23T08:33Z",
+	} {
+		got, err := ParseDate(s)
+		if err != nil {
+			t.Errorf("%s", err)
+			continue
+		}
+		if !got.Equal(expected) {
+			t.Errorf("ParseDate(%q) expected %s, got %s", s, expected, got)
+		}
+	}
+	if got, err := ParseDate("2016-05-23"); err != nil || !got.Equal(time.Date(2016, time.May, 23, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected a date only value to parse, got %s, %v", got, err)
+	}
+	for _, s := range []string{"", "yesterday", "32 May 2016 08:33:00 GMT"} {
+		if _, err := ParseDate(s); err == nil {
+			t.Errorf("expected an error parsing %q", s)
+		}
+	}
+}
+
+func TestSetDates(t *testing.T) {
+	created := time.Date(2021, time.March, 2, 10, 0, 0, 0, time.FixedZone("PST", -8*60*60))
+	h := new(Head)
+	h.SetCreated(created)
+	if h.Created != "Tue, 02 Mar 2021 18:00:00 GMT" {
+		t.Errorf("unexpected dateCreated %q", h.Created)
+	}
+	h.SetModified(created.Add(time.Hour))
+	if tm, err := h.ModifiedTime(); err != nil || !tm.Equal(created.Add(time.Hour)) {
+		t.Errorf("expected %s, got %s, %v", created.Add(time.Hour), tm, err)
+	}
+	ol := new(Outline)
+	ol.SetCreated(created)
+	if tm, err := ol.CreatedTime(); err != nil || !tm.Equal(created) {
+		t.Errorf("expected %s, got %s, %v", created, tm, err)
+	}
+}
+
+func TestNormalizeDates(t *testing.T) {
+	o, err := ReadFile("testdata/feeds.opml")
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	if err := o.NormalizeDates(); err != nil {
+		t.Errorf("%s", err)
+	}
+	elem, _ := o.Get(OutlinePath{1, 2})
+	if elem.Created != "Tue, 15 Jun 2021 12:00:00 GMT" {
+		t.Errorf("unexpected created %q", elem.Created)
+	}
+	elem.Created = "not a date"
+	if err := o.NormalizeDates(); err == nil {
+		t.Errorf("expected an error for an unparsable date")
+	}
+	if elem.Created != "not a date" {
+		t.Errorf("expected an unparsable date to be left as is, got %q", elem.Created)
+	}
+}
@@ -0,0 +1,227 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Severity of a validation Diagnostic
+type Severity int
+
+const (
+	// SeverityWarning is a problem that doesn't violate the spec but is likely
+	// to cause trouble, e.g. a date that isn't in RFC 822 format
+	SeverityWarning Severity = iota
+	// SeverityError is a violation of the OPML 2.0 spec
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// MarshalJSON writes the severity as "error" or "warning"
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// Diagnostic describes a problem found by Validate
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	// Path is the outline element with the problem, it is nil for
+	// problems in the head or the document itself
+	Path OutlinePath `json:"path,omitempty"`
+	// Attr is the attribute or head element with the problem
+	Attr    string `json:"attr,omitempty"`
+	Message string `json:"message"`
+	// Pos is the source position of the outline element, it is set
+	// when the document was parsed with ParseOptions.Positions
+	Pos *Position `json:"pos,omitempty"`
+}
+
+func (d Diagnostic) String() string {
+	where := "head"
+	if d.Path != nil {
+		where = d.Path.String()
+	} else if d.Attr == "version" || d.Attr == "body" {
+		where = "opml"
+	}
+	if d.Attr != "" {
+		where += " " + d.Attr
+	}
+	if d.Pos != nil {
+		where = d.Pos.String() + ": " + where
+	}
+	return fmt.Sprintf("%s: %s: %s", where, d.Severity, d.Message)
+}
+
+// HasErrors returns true if any of the diagnostics is a SeverityError
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+type validator struct {
+	diagnostics []Diagnostic
+	// pos is the position of the outline element being checked
+	pos *Position
+}
+
+func (v *validator) add(severity Severity, p OutlinePath, attr string, format string, args ...interface{}) {
+	v.diagnostics = append(v.diagnostics, Diagnostic{
+		Severity: severity,
+		Path:     p,
+		Attr:     attr,
+		Message:  fmt.Sprintf(format, args...),
+		Pos:      v.pos,
+	})
+}
+
+func (v *validator) date(p OutlinePath, attr string, s string) {
+	if s == "" {
+		return
+	}
+	if _, err := ParseDate(s); err != nil {
+		v.add(SeverityError, p, attr, "%q is not a date", s)
+	} else if !IsRFC822(s) {
+		v.add(SeverityWarning, p, attr, "%q is not an RFC 822 date", s)
+	}
+}
+
+func (v *validator) url(p OutlinePath, attr string, s string) {
+	if s == "" {
+		return
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || (u.Host == "" && u.Opaque == "" && u.Scheme != "file") {
+		v.add(SeverityWarning, p, attr, "%q is not an absolute URL", s)
+	}
+}
+
+// Validate checks an OPML document against the OPML 2.0 spec and
+// returns a list of diagnostics in document order. An empty list means
+// no problems were found.
+func Validate(o *OPML) []Diagnostic {
+	v := new(validator)
+	switch o.Version {
+	case "1.0", "2.0":
+	case "":
+		v.add(SeverityError, nil, "version", "the version attribute is required")
+	default:
+		v.add(SeverityError, nil, "version", "unknown version %q, expected 1.0 or 2.0", o.Version)
+	}
+
+	// Count the outline elements, expansionState refers to them by line number
+	lines := 0
+	o.WalkWithPath(func(ol *Outline, p OutlinePath, depth int, parent *Outline) error {
+		lines++
+		return nil
+	})
+
+	if o.Head == nil {
+		v.add(SeverityError, nil, "head", "the head element is required")
+	} else {
+		h := o.Head
+		v.date(nil, "dateCreated", h.Created)
+		v.date(nil, "dateModified", h.Modified)
+		if h.OwnerEmail != "" && !strings.Contains(h.OwnerEmail, "@") {
+			v.add(SeverityWarning, nil, "ownerEmail", "%q is not an email address", h.OwnerEmail)
+		}
+		v.url(nil, "ownerId", h.OwnerID)
+		v.url(nil, "docs", h.Docs)
+		if h.ExpansionState != "" {
+			for _, s := range strings.Split(h.ExpansionState, ",") {
+				s = strings.TrimSpace(s)
+				n, err := strconv.Atoi(s)
+				switch {
+				case err != nil:
+					v.add(SeverityError, nil, "expansionState", "%q is not a line number", s)
+				case n < 1 || n > lines:
+					v.add(SeverityError, nil, "expansionState", "line %d is out of range, the outline has %d lines", n, lines)
+				}
+			}
+		}
+		for _, n := range []struct {
+			attr string
+			val  int
+		}{
+			{"vertScrollState", h.VertScrollState},
+			{"windowTop", h.WindowTop},
+			{"windowLeft", h.WindowLeft},
+			{"windowBottom", h.WindowBottom},
+			{"windowRight", h.WindowRight},
+		} {
+			if n.val < 0 {
+				v.add(SeverityWarning, nil, n.attr, "%d is negative", n.val)
+			}
+		}
+	}
+
+	if o.Body == nil || len(o.Body.Outline) == 0 {
+		v.add(SeverityError, nil, "body", "the body must contain one or more outline elements")
+	}
+
+	o.WalkWithPath(func(ol *Outline, p OutlinePath, depth int, parent *Outline) error {
+		v.pos = ol.Pos
+		if ol.Text == "" {
+			if o.Version == "1.0" {
+				v.add(SeverityWarning, p, "text", "the text attribute is missing")
+			} else {
+				v.add(SeverityError, p, "text", "the text attribute is required")
+			}
+		}
+		switch ol.Type {
+		case "rss":
+			if ol.XMLURL == "" {
+				v.add(SeverityError, p, "xmlUrl", "the xmlUrl attribute is required for type \"rss\"")
+			}
+		case "link", "include":
+			if ol.URL == "" {
+				v.add(SeverityError, p, "url", "the url attribute is required for type %q", ol.Type)
+			}
+		}
+		if ol.XMLURL != "" && ol.Type == "" {
+			v.add(SeverityWarning, p, "type", "outline has an xmlUrl but no type, expected \"rss\"")
+		}
+		v.date(p, "created", ol.Created)
+		v.url(p, "xmlUrl", ol.XMLURL)
+		v.url(p, "htmlUrl", ol.HTMLURL)
+		v.url(p, "url", ol.URL)
+		if ol.Category != "" {
+			for _, c := range strings.Split(ol.Category, ",") {
+				if c = strings.TrimSpace(c); strings.Contains(c, "/") && !strings.HasPrefix(c, "/") {
+					v.add(SeverityWarning, p, "category", "%q should start with a slash", c)
+				}
+			}
+		}
+		return nil
+	})
+	return v.diagnostics
+}
@@ -0,0 +1,82 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, fname := range []string{"testdata/example2.opml", "testdata/example4.opml", "testdata/feeds.opml"} {
+		o, err := ReadFile(fname)
+		if err != nil {
+			t.Errorf("%s", err)
+			continue
+		}
+		if diagnostics := Validate(o); HasErrors(diagnostics) {
+			t.Errorf("%s: expected no errors, got %s", fname, diagnostics)
+		}
+	}
+
+	o, err := ReadFile("testdata/invalid.opml")
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	diagnostics := Validate(o)
+	if !HasErrors(diagnostics) {
+		t.Errorf("expected errors validating invalid.opml")
+	}
+	found := []string{}
+	for _, d := range diagnostics {
+		found = append(found, d.String())
+	}
+	expected := []string{
+		`opml version: error: unknown version "2.1", expected 1.0 or 2.0`,
+		`head dateCreated: warning: "2021-03-02T10:00:00Z" is not an RFC 822 date`,
+		`head dateModified: error: "last tuesday" is not a date`,
+		`head expansionState: error: line 9 is out of range, the outline has 5 lines`,
+		`head expansionState: error: "x" is not a line number`,
+		`/1/1 text: error: the text attribute is required`,
+		`/1/2 xmlUrl: error: the xmlUrl attribute is required for type "rss"`,
+		`/1/2 htmlUrl: warning: "example.org" is not an absolute URL`,
+		`/2 url: error: the url attribute is required for type "link"`,
+		`/3 created: error: "yesterday" is not a date`,
+	}
+	if s, e := strings.Join(found, "\n"), strings.Join(expected, "\n"); s != e {
+		t.Errorf("expected\n%s\ngot\n%s", e, s)
+	}
+
+	// A document without a version attribute
+	src := []byte(`<opml><head><title>x</title></head><body></body></opml>`)
+	for _, parse := range []func([]byte) (*OPML, error){
+		Parse,
+		func(src []byte) (*OPML, error) { return ParseWith(src, ParseOptions{}) },
+	} {
+		o, err := parse(src)
+		if err != nil {
+			t.Errorf("%s", err)
+			continue
+		}
+		diagnostics = Validate(o)
+		if len(diagnostics) != 2 || diagnostics[0].Attr != "version" || diagnostics[1].Attr != "body" {
+			t.Errorf("expected missing version and empty body errors, got %s", diagnostics)
+		}
+	}
+}
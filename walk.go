@@ -0,0 +1,150 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// SkipChildren is returned by a WalkPathFunc to skip the children
+	// of the current outline element. The walk continues with its
+	// next sibling. It is ignored in a post-order walk.
+	SkipChildren = errors.New("skip children")
+
+	// StopWalk is returned by a WalkPathFunc to end the walk without
+	// an error.
+	StopWalk = errors.New("stop walk")
+)
+
+// WalkPathFunc is called for each outline element visited by
+// WalkWithPath and WalkPostOrderWithPath. It is passed the element,
+// its path, its depth (one for elements in Body.Outline) and its
+// parent (nil for elements in Body.Outline).
+type WalkPathFunc func(ol *Outline, p OutlinePath, depth int, parent *Outline) error
+
+func walk(ol *Outline, fn func(*Outline) bool) bool {
+	if ol == nil {
+		return false
+	}
+	if ok := fn(ol); !ok {
+		return false
+	}
+	for _, elem := range ol.Outline {
+		if ok := walk(elem, fn); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func walkPostOrder(ol *Outline, fn func(*Outline) bool) bool {
+	if ol == nil {
+		return false
+	}
+	for _, elem := range ol.Outline {
+		if ok := walkPostOrder(elem, fn); !ok {
+			return false
+		}
+	}
+	return fn(ol)
+}
+
+// walkWithPath visits the list of outline elements belonging to parent.
+func walkWithPath(l []*Outline, p OutlinePath, parent *Outline, postOrder bool, fn WalkPathFunc) error {
+	for i, elem := range l {
+		if elem == nil {
+			continue
+		}
+		cur := p.Child(i + 1)
+		if !postOrder {
+			if err := fn(elem, cur, len(cur), parent); err != nil {
+				if err == SkipChildren {
+					continue
+				}
+				return err
+			}
+		}
+		if err := walkWithPath(elem.Outline, cur, elem, postOrder, fn); err != nil {
+			return err
+		}
+		if postOrder {
+			if err := fn(elem, cur, len(cur), parent); err != nil && err != SkipChildren {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Walk does a depth first, pre-order, walk of an outline, stops if
+// function return false.
+func (o *OPML) Walk(fn func(*Outline) bool) error {
+	if o.Body == nil || len(o.Body.Outline) == 0 {
+		return fmt.Errorf("outline is empty")
+	}
+	for _, elem := range o.Body.Outline {
+		if ok := walk(elem, fn); !ok {
+			break
+		}
+	}
+	return nil
+}
+
+// WalkPostOrder does a depth first walk of an outline visiting the
+// children of an element before the element itself, stops if function
+// return false.
+func (o *OPML) WalkPostOrder(fn func(*Outline) bool) error {
+	if o.Body == nil || len(o.Body.Outline) == 0 {
+		return fmt.Errorf("outline is empty")
+	}
+	for _, elem := range o.Body.Outline {
+		if ok := walkPostOrder(elem, fn); !ok {
+			break
+		}
+	}
+	return nil
+}
+
+// WalkWithPath does a depth first, pre-order, walk of an outline
+// passing each element's path, depth and parent to fn. If fn returns
+// SkipChildren the element's children are not visited, if it returns
+// StopWalk the walk ends and WalkWithPath returns nil. Any other error
+// ends the walk and is returned.
+func (o *OPML) WalkWithPath(fn WalkPathFunc) error {
+	if o.Body == nil {
+		return nil
+	}
+	if err := walkWithPath(o.Body.Outline, OutlinePath{}, nil, false, fn); err != nil && err != StopWalk {
+		return err
+	}
+	return nil
+}
+
+// WalkPostOrderWithPath is like WalkWithPath but visits the children
+// of an element before the element itself.
+func (o *OPML) WalkPostOrderWithPath(fn WalkPathFunc) error {
+	if o.Body == nil {
+		return nil
+	}
+	if err := walkWithPath(o.Body.Outline, OutlinePath{}, nil, true, fn); err != nil && err != StopWalk {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+/*
+opml is a Go package for working with OPML XML files.
+Copyright (C) 2021 R. S. Doiel
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package opml
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	o, err := ReadFile("testdata/example4.opml")
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	visited := []string{}
+	if err := o.Walk(func(ol *Outline) bool {
+		visited = append(visited, ol.Text)
+		return true
+	}); err != nil {
+		t.Errorf("%s", err)
+	}
+	// One root, five places and eleven neighborhoods
+	if len(visited) != 17 {
+		t.Errorf("expected 17 outlines visited, got %d, %s", len(visited), strings.Join(visited, ", "))
+	}
+	expected := "Places of interest, Victoria, BC, New York, Upper Eastside, Midtown, Boston"
+	if s := strings.Join(visited[0:6], ", "); s != expected {
+		t.Errorf("expected pre-order %q, got %q", expected, s)
+	}
+
+	// Returning false stops the walk entirely
+	visited = []string{}
+	o.Walk(func(ol *Outline) bool {
+		visited = append(visited, ol.Text)
+		return ol.Text != "Upper Eastside"
+	})
+	if len(visited) != 4 {
+		t.Errorf("expected the walk to stop after 4 outlines, got %d", len(visited))
+	}
+
+	visited = []string{}
+	o.WalkPostOrder(func(ol *Outline) bool {
+		visited = append(visited, ol.Text)
+		return true
+	})
+	if len(visited) != 17 {
+		t.Errorf("expected 17 outlines visited, got %d", len(visited))
+	}
+	expected = "Victoria, BC, Upper Eastside, Midtown, New York, Cambridge"
+	if s := strings.Join(visited[0:5], ", "); s != expected {
+		t.Errorf("expected post-order %q, got %q", expected, s)
+	}
+	if visited[16] != "Places of interest" {
+		t.Errorf("expected root visited last, got %q", visited[16])
+	}
+
+	if err := New().Walk(func(ol *Outline) bool { return true }); err == nil {
+		t.Errorf("expected an error walking an empty outline")
+	}
+}
+
+func TestWalkWithPath(t *testing.T) {
+	o, err := ReadFile("testdata/example4.opml")
+	if err != nil {
+		t.Errorf("%s", err)
+		t.FailNow()
+	}
+	visited := []string{}
+	err = o.WalkWithPath(func(ol *Outline, p OutlinePath, depth int, parent *Outline) error {
+		if depth != len(p) {
+			t.Errorf("depth %d doesn't match path %s", depth, p)
+		}
+		if depth == 1 && parent != nil {
+			t.Errorf("expected a nil parent for %s", p)
+		}
+		if depth > 1 && parent == nil {
+			t.Errorf("expected a parent for %s", p)
+		}
+		visited = append(visited, fmt.Sprintf("%s %s", p, ol.Text))
+		if ol.Text == "Boston" || ol.Text == "Bay Area" {
+			return SkipChildren
+		}
+		if ol.Text == "Uptown" {
+			return StopWalk
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+	expected := "/1 Places of interest|/1/1 Victoria, BC|/1/2 New York|/1/2/1 Upper Eastside|/1/2/2 Midtown|/1/3 Boston|/1/4 Bay Area|/1/5 New Orleans|/1/5/1 Uptown"
+	if s := strings.Join(visited, "|"); s != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, s)
+	}
+
+	visited = []string{}
+	o.WalkPostOrderWithPath(func(ol *Outline, p OutlinePath, depth int, parent *Outline) error {
+		if depth == 2 {
+			visited = append(visited, p.String())
+		}
+		return nil
+	})
+	if s := strings.Join(visited, " "); s != "/1/1 /1/2 /1/3 /1/4 /1/5" {
+		t.Errorf("unexpected post-order paths %s", s)
+	}
+
+	stop := fmt.Errorf("custom error")
+	if err := o.WalkWithPath(func(ol *Outline, p OutlinePath, depth int, parent *Outline) error {
+		return stop
+	}); err != stop {
+		t.Errorf("expected custom error returned, got %v", err)
+	}
+}